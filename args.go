@@ -0,0 +1,539 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type ArgKind int32
+
+const (
+	ArgUser     ArgKind = 0  // @user mention or raw user ID
+	ArgID       ArgKind = 1  // raw snowflake ID
+	ArgChannel  ArgKind = 2  // #channel mention or raw channel ID
+	ArgRole     ArgKind = 3  // @role mention or raw role ID
+	ArgInt      ArgKind = 4  // whole number, optionally within a range
+	ArgWord     ArgKind = 5  // a single word, or a "quoted string"
+	ArgRest     ArgKind = 6  // everything left on the line
+	ArgChoice   ArgKind = 7  // one of a fixed set of words
+	ArgEmoji    ArgKind = 8  // custom server emoji
+	ArgFlag     ArgKind = 9  // -name value, anywhere after the other arguments
+	ArgTarget   ArgKind = 10 // @user or @role mention, or a raw ID of either
+	ArgDuration ArgKind = 11 // a length of time like 30m, 2d or 1w12h
+)
+
+var userMentionRegex = regexp.MustCompile(`^<@!?([0-9]+)>$`)
+var channelMentionRegex = regexp.MustCompile(`^<#([0-9]+)>$`)
+var roleMentionRegex = regexp.MustCompile(`^<@&([0-9]+)>$`)
+var emojiRegex = regexp.MustCompile(`^<a?:\w+:([0-9]+)>$`)
+var snowflakeRegex = regexp.MustCompile(`^[0-9]{15,21}$`)
+var durationPartRegex = regexp.MustCompile(`([0-9]+)([smhdw])`)
+
+/**
+Declares one argument of a command. The dispatcher uses these to parse and
+validate the invocation before the handler is called.
+*/
+type argSpec struct {
+	name     string
+	kind     ArgKind
+	optional bool
+	min      int
+	max      int // 0 means there is no upper bound
+	choices  []string
+}
+
+/**
+The validated arguments of an invocation, keyed by argument name. Mentions are
+reduced to their IDs, numbers are stored in Ints and lengths of time in Durations.
+*/
+type Args struct {
+	Subcommand string
+	Values     map[string]string
+	Ints       map[string]int
+	Durations  map[string]time.Duration
+}
+
+/**
+Returns empty arguments, ready to be filled in.
+*/
+func newArgs() *Args {
+	return &Args{Values: make(map[string]string), Ints: make(map[string]int), Durations: make(map[string]time.Duration)}
+}
+
+/**
+Helpers for declaring argument specs in commandList.
+*/
+func userArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgUser}
+}
+
+func idArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgID}
+}
+
+func channelArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgChannel}
+}
+
+func roleArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgRole}
+}
+
+func intArg(name string, min int, max int) argSpec {
+	return argSpec{name: name, kind: ArgInt, min: min, max: max}
+}
+
+func wordArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgWord}
+}
+
+func restArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgRest}
+}
+
+func choiceArg(name string, choices ...string) argSpec {
+	return argSpec{name: name, kind: ArgChoice, choices: choices}
+}
+
+func emojiArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgEmoji}
+}
+
+func targetArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgTarget}
+}
+
+func durationArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgDuration}
+}
+
+func flagArg(name string) argSpec {
+	return argSpec{name: name, kind: ArgFlag, optional: true}
+}
+
+func optional(spec argSpec) argSpec {
+	spec.optional = true
+	return spec
+}
+
+type argToken struct {
+	text  string
+	start int // byte offset of the token in the raw message
+}
+
+/**
+Splits a message on whitespace, keeping "quoted strings" together as a single
+token. Offsets are kept so rest-of-line arguments can preserve the original text.
+*/
+func tokenize(content string) []argToken {
+	var tokens []argToken
+	i := 0
+	for i < len(content) {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		if content[i] == '"' {
+			closing := strings.IndexByte(content[i+1:], '"')
+			if closing != -1 {
+				tokens = append(tokens, argToken{content[i+1 : i+1+closing], start})
+				i += closing + 2
+				continue
+			}
+		}
+
+		for i < len(content) {
+			r, size = utf8.DecodeRuneInString(content[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		tokens = append(tokens, argToken{content[start:i], start})
+	}
+	return tokens
+}
+
+/**
+Validates a single value against its spec and stores it in args.
+*/
+func parseValue(spec argSpec, text string, args *Args) error {
+	var match []string
+	switch spec.kind {
+	case ArgUser:
+		match = userMentionRegex.FindStringSubmatch(text)
+	case ArgChannel:
+		match = channelMentionRegex.FindStringSubmatch(text)
+	case ArgRole:
+		match = roleMentionRegex.FindStringSubmatch(text)
+	case ArgTarget:
+		match = userMentionRegex.FindStringSubmatch(text)
+		if match == nil {
+			match = roleMentionRegex.FindStringSubmatch(text)
+		}
+	case ArgID:
+		if !snowflakeRegex.MatchString(text) {
+			return fmt.Errorf("%s is not a valid ID", spec.name)
+		}
+		args.Values[spec.name] = text
+		return nil
+	case ArgEmoji:
+		match = emojiRegex.FindStringSubmatch(text)
+		if match == nil {
+			return fmt.Errorf("%s must be a custom server emoji", spec.name)
+		}
+		args.Values[spec.name] = match[1]
+		return nil
+	case ArgInt:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", spec.name)
+		}
+		if number < spec.min || (spec.max != 0 && number > spec.max) {
+			return fmt.Errorf("%s is out of range", spec.name)
+		}
+		args.Ints[spec.name] = number
+		args.Values[spec.name] = text
+		return nil
+	case ArgChoice:
+		text = strings.ToLower(text)
+		for _, choice := range spec.choices {
+			if text == choice {
+				args.Values[spec.name] = text
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s", spec.name, strings.Join(spec.choices, ", "))
+	case ArgDuration:
+		duration, err := parseDuration(text)
+		if err != nil {
+			return fmt.Errorf("%s %s", spec.name, err.Error())
+		}
+		args.Durations[spec.name] = duration
+		args.Values[spec.name] = text
+		return nil
+	default:
+		args.Values[spec.name] = text
+		return nil
+	}
+
+	// mentions and IDs
+	if match != nil {
+		args.Values[spec.name] = match[1]
+		return nil
+	}
+	if snowflakeRegex.MatchString(text) {
+		args.Values[spec.name] = text
+		return nil
+	}
+	return fmt.Errorf("%s is not a valid mention or ID", spec.name)
+}
+
+/**
+Parses a length of time made of numbers followed by s, m, h, d or w, like 30m,
+2d or 1w12h.
+*/
+func parseDuration(text string) (time.Duration, error) {
+	units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	text = strings.ToLower(text)
+	parts := durationPartRegex.FindAllStringSubmatch(text, -1)
+	if len(parts) == 0 || strings.Join(durationPartRegex.FindAllString(text, -1), "") != text {
+		return 0, errors.New("must be a length of time like 30m, 2d or 1w")
+	}
+	// anything over ten years is surely a mistake; checking each step keeps the sum from overflowing
+	const maxDuration = 10 * 365 * 24 * time.Hour
+	var duration time.Duration
+	for _, part := range parts {
+		number, err := strconv.ParseInt(part[1], 10, 64)
+		unit := units[part[2]]
+		if err != nil || time.Duration(number) > maxDuration/unit {
+			return 0, errors.New("is too long")
+		}
+		partDuration := time.Duration(number) * unit
+		if partDuration > maxDuration-duration {
+			return 0, errors.New("is too long")
+		}
+		duration += partDuration
+	}
+	if duration <= 0 || duration > maxDuration {
+		return 0, errors.New("must be between 1s and 10 years")
+	}
+	return duration, nil
+}
+
+/**
+Describes a length of time in its largest units, e.g. "1 day 12 hours".
+*/
+func describeDuration(duration time.Duration) string {
+	units := []struct {
+		name   string
+		length time.Duration
+	}{{"week", 7 * 24 * time.Hour}, {"day", 24 * time.Hour}, {"hour", time.Hour}, {"minute", time.Minute}, {"second", time.Second}}
+	var parts []string
+	for _, unit := range units {
+		count := duration / unit.length
+		if count == 0 {
+			continue
+		}
+		duration -= count * unit.length
+		part := fmt.Sprintf("%d %s", count, unit.name)
+		if count != 1 {
+			part += "s"
+		}
+		parts = append(parts, part)
+		if len(parts) == 2 {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, " ")
+}
+
+/**
+Picks the argument specs to use for a command, taking the subcommand into
+account if the command has any.
+*/
+func specsFor(cmd command, subcommand string) ([]argSpec, error) {
+	if cmd.subcommands == nil {
+		return cmd.args, nil
+	}
+	specs, ok := cmd.subcommands[subcommand]
+	if !ok {
+		if subcommand == "" {
+			return nil, errors.New("missing subcommand")
+		}
+		return nil, fmt.Errorf("unknown subcommand '%s'", subcommand)
+	}
+	return specs, nil
+}
+
+/**
+Parses a prefixed message into validated arguments for the command. The first
+word of the message is the invoke word and is skipped.
+*/
+func parseArgs(cmd command, content string) (*Args, error) {
+	args := newArgs()
+	tokens := tokenize(content)
+	if len(tokens) > 0 {
+		tokens = tokens[1:]
+	}
+
+	if cmd.subcommands != nil && len(tokens) > 0 {
+		args.Subcommand = strings.ToLower(tokens[0].text)
+		tokens = tokens[1:]
+	}
+	specs, err := specsFor(cmd, args.Subcommand)
+	if err != nil {
+		return nil, err
+	}
+
+	// pull out flags first so they don't get mistaken for positional arguments
+	var positional []argToken
+	var flagStarts []int
+	for i := 0; i < len(tokens); i++ {
+		isFlag := false
+		for _, spec := range specs {
+			if spec.kind == ArgFlag && tokens[i].text == "-"+spec.name {
+				if i+1 >= len(tokens) {
+					return nil, fmt.Errorf("-%s needs a value", spec.name)
+				}
+				args.Values[spec.name] = tokens[i+1].text
+				flagStarts = append(flagStarts, tokens[i].start)
+				isFlag = true
+				i++
+				break
+			}
+		}
+		if !isFlag {
+			positional = append(positional, tokens[i])
+		}
+	}
+
+	next := 0
+	for _, spec := range specs {
+		if spec.kind == ArgFlag {
+			continue
+		}
+		if next >= len(positional) {
+			if spec.optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing %s", spec.name)
+		}
+
+		if spec.kind == ArgRest {
+			// take the raw text up to the first flag so spacing and newlines survive
+			end := len(content)
+			for _, flagStart := range flagStarts {
+				if flagStart > positional[next].start && flagStart < end {
+					end = flagStart
+				}
+			}
+			args.Values[spec.name] = strings.TrimSpace(content[positional[next].start:end])
+			next = len(positional)
+			continue
+		}
+
+		err = parseValue(spec, positional[next].text, args)
+		if err != nil {
+			return nil, err
+		}
+		next++
+	}
+
+	if next < len(positional) {
+		return nil, errors.New("too many arguments")
+	}
+	return args, nil
+}
+
+/**
+Builds validated arguments for the command out of the options of a slash command.
+*/
+func argsFromOptions(cmd command, options []*discordgo.ApplicationCommandInteractionDataOption) (*Args, error) {
+	args := newArgs()
+
+	if cmd.subcommands != nil && len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		args.Subcommand = options[0].Name
+		options = options[0].Options
+	}
+	specs, err := specsFor(cmd, args.Subcommand)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range options {
+		received[option.Name] = option
+	}
+
+	for _, spec := range specs {
+		option, ok := received[spec.name]
+		if !ok {
+			if spec.optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing %s", spec.name)
+		}
+
+		var text string
+		switch option.Type {
+		case discordgo.ApplicationCommandOptionInteger:
+			text = strconv.FormatInt(option.IntValue(), 10)
+		case discordgo.ApplicationCommandOptionString:
+			text = option.StringValue()
+		default:
+			// users, channels, roles and mentionables all arrive as IDs
+			text = fmt.Sprintf("%v", option.Value)
+		}
+		err = parseValue(spec, text, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+/**
+Converts argument specs into typed slash command options.
+*/
+func specsToOptions(specs []argSpec) []*discordgo.ApplicationCommandOption {
+	var options []*discordgo.ApplicationCommandOption
+	for _, spec := range specs {
+		option := &discordgo.ApplicationCommandOption{
+			Name:     spec.name,
+			Required: !spec.optional,
+		}
+		switch spec.kind {
+		case ArgUser:
+			option.Type = discordgo.ApplicationCommandOptionUser
+			option.Description = "A member of this server"
+		case ArgChannel:
+			option.Type = discordgo.ApplicationCommandOptionChannel
+			option.Description = "A channel in this server"
+		case ArgRole:
+			option.Type = discordgo.ApplicationCommandOptionRole
+			option.Description = "A role in this server"
+		case ArgTarget:
+			option.Type = discordgo.ApplicationCommandOptionMentionable
+			option.Description = "A member or role in this server"
+		case ArgInt:
+			option.Type = discordgo.ApplicationCommandOptionInteger
+			option.Description = "A whole number"
+			minValue := float64(spec.min)
+			option.MinValue = &minValue
+			if spec.max != 0 {
+				option.MaxValue = float64(spec.max)
+			}
+		case ArgChoice:
+			option.Type = discordgo.ApplicationCommandOptionString
+			option.Description = "One of: " + strings.Join(spec.choices, ", ")
+			for _, choice := range spec.choices {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+			}
+		case ArgEmoji:
+			option.Type = discordgo.ApplicationCommandOptionString
+			option.Description = "A custom emoji from this server"
+		case ArgDuration:
+			option.Type = discordgo.ApplicationCommandOptionString
+			option.Description = "A length of time, like 30m, 2d or 1w"
+		case ArgID:
+			option.Type = discordgo.ApplicationCommandOptionString
+			option.Description = "An ID"
+		default:
+			option.Type = discordgo.ApplicationCommandOptionString
+			option.Description = "Text"
+		}
+		options = append(options, option)
+	}
+
+	// discord requires every required option to come before the optional ones
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Required && !options[j].Required
+	})
+	return options
+}
+
+/**
+Returns the slash command options for a command, including its subcommands.
+*/
+func applicationCommandOptions(cmd command) []*discordgo.ApplicationCommandOption {
+	if cmd.subcommands == nil {
+		return specsToOptions(cmd.args)
+	}
+
+	var names []string
+	for name := range cmd.subcommands {
+		// slash commands always need a subcommand picked
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var options []*discordgo.ApplicationCommandOption
+	for _, name := range names {
+		first, size := utf8.DecodeRuneInString(name)
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        name,
+			Description: string(unicode.ToUpper(first)) + name[size:],
+			Options:     specsToOptions(cmd.subcommands[name]),
+		})
+	}
+	return options
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that commands are parsed and validated against their argument specs
+the same way whether they come from a prefixed message or a slash command.
+**/
+func TestArgs(t *testing.T) {
+	t.Run("tokenize keeps quoted strings together", func(t *testing.T) {
+		tokens := tokenize(`~greeter set join "hello there" world`)
+		expected := []string{"~greeter", "set", "join", "hello there", "world"}
+		if len(tokens) != len(expected) {
+			t.Logf("Expected %d tokens, got %d: %+v", len(expected), len(tokens), tokens)
+			t.FailNow()
+		}
+		for i := range expected {
+			if tokens[i].text != expected[i] {
+				t.Logf("Token %d: expected '%s', got '%s'", i, expected[i], tokens[i].text)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("mentions and raw IDs are reduced to IDs", func(t *testing.T) {
+		cmd := command{args: []argSpec{userArg("user"), channelArg("channel")}}
+		args, err := parseArgs(cmd, "~vcmove <@!123456789012345678> 234567890123456789")
+		if err != nil {
+			t.Logf("Failed to parse valid arguments: %s", err.Error())
+			t.FailNow()
+		}
+		if args.Values["user"] != "123456789012345678" || args.Values["channel"] != "234567890123456789" {
+			t.Logf("Mentions were not reduced to IDs: %+v", args.Values)
+			t.Fail()
+		}
+
+		_, err = parseArgs(cmd, "~vcmove someone #general")
+		if err == nil {
+			t.Logf("Accepted an invalid mention")
+			t.Fail()
+		}
+	})
+
+	t.Run("numbers are range checked", func(t *testing.T) {
+		cmd := command{args: []argSpec{intArg("number", 1, 100), channelArg("channel")}}
+		args, err := parseArgs(cmd, "~mv 25 <#234567890123456789>")
+		if err != nil || args.Ints["number"] != 25 {
+			t.Logf("Failed to parse a number in range: %+v %v", args, err)
+			t.Fail()
+		}
+		for _, number := range []string{"0", "101", "ten"} {
+			_, err = parseArgs(cmd, "~mv "+number+" <#234567890123456789>")
+			if err == nil {
+				t.Logf("Accepted invalid number %s", number)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("IDs must be snowflakes", func(t *testing.T) {
+		cmd := command{subcommands: map[string][]argSpec{"leave": {idArg("guild")}}}
+		args, err := parseArgs(cmd, "~owner leave 123456789012345678")
+		if err != nil || args.Values["guild"] != "123456789012345678" {
+			t.Logf("Failed to parse a valid ID: %+v %v", args, err)
+			t.Fail()
+		}
+		for _, text := range []string{"abc", "<@123456789012345678>", "12345"} {
+			if _, err := parseArgs(cmd, "~owner leave "+text); err == nil {
+				t.Logf("Accepted invalid ID %s", text)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("durations are read in friendly units", func(t *testing.T) {
+		cmd := command{args: []argSpec{userArg("user"), durationArg("duration")}}
+		expected := map[string]time.Duration{"30m": 30 * time.Minute, "2d": 48 * time.Hour, "1w": 7 * 24 * time.Hour, "1h30m": 90 * time.Minute, "45S": 45 * time.Second}
+		for text, duration := range expected {
+			args, err := parseArgs(cmd, "~timeout 123456789012345678 "+text)
+			if err != nil || args.Durations["duration"] != duration {
+				t.Logf("Expected %s to be %s, got %+v %v", text, duration, args, err)
+				t.Fail()
+			}
+		}
+		for _, text := range []string{"30", "m", "1h30", "2 days", "0m", "99999w", "30501w", "500w500w", "99999999999999999999s"} {
+			if _, err := parseArgs(cmd, "~timeout 123456789012345678 "+text); err == nil {
+				t.Logf("Accepted invalid duration %s", text)
+				t.Fail()
+			}
+		}
+		if described := describeDuration(36*time.Hour + 5*time.Minute); described != "1 day 12 hours" {
+			t.Logf("Unexpected description %s", described)
+			t.Fail()
+		}
+	})
+
+	t.Run("rest arguments keep the original text and stop at flags", func(t *testing.T) {
+		cmd := command{subcommands: map[string][]argSpec{
+			"set": {choiceArg("type", "join", "leave"), channelArg("channel"), restArg("message"), flagArg("img")},
+		}}
+		args, err := parseArgs(cmd, "~greeter SET join <#234567890123456789> Welcome,  <<ping>>!\nEnjoy -img https://example.com/a.png")
+		if err != nil {
+			t.Logf("Failed to parse valid arguments: %s", err.Error())
+			t.FailNow()
+		}
+		if args.Subcommand != "set" || args.Values["type"] != "join" {
+			t.Logf("Subcommand or choice parsed incorrectly: %+v", args)
+			t.Fail()
+		}
+		if args.Values["message"] != "Welcome,  <<ping>>!\nEnjoy" {
+			t.Logf("Rest argument lost its formatting: %q", args.Values["message"])
+			t.Fail()
+		}
+		if args.Values["img"] != "https://example.com/a.png" {
+			t.Logf("Flag parsed incorrectly: %q", args.Values["img"])
+			t.Fail()
+		}
+	})
+
+	t.Run("missing, extra and unknown arguments are rejected", func(t *testing.T) {
+		cmd := command{args: []argSpec{userArg("user"), optional(restArg("reason"))}}
+		_, err := parseArgs(cmd, "~kick")
+		if err == nil {
+			t.Logf("Accepted a missing required argument")
+			t.Fail()
+		}
+		args, err := parseArgs(cmd, "~kick <@123456789012345678>")
+		if err != nil || args.Values["reason"] != "" {
+			t.Logf("Failed to skip an optional argument: %+v %v", args, err)
+			t.Fail()
+		}
+
+		cmd = command{args: []argSpec{userArg("user")}}
+		_, err = parseArgs(cmd, "~profile <@123456789012345678> extra")
+		if err == nil {
+			t.Logf("Accepted too many arguments")
+			t.Fail()
+		}
+
+		cmd = command{subcommands: map[string][]argSpec{"reset": {}}}
+		_, err = parseArgs(cmd, "~modlog delete")
+		if err == nil {
+			t.Logf("Accepted an unknown subcommand")
+			t.Fail()
+		}
+	})
+
+	t.Run("slash command options are validated like prefix arguments", func(t *testing.T) {
+		cmd := command{subcommands: map[string][]argSpec{
+			"whitelist": {userArg("user"), choiceArg("state", "true", "false")},
+		}}
+		options := []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "whitelist",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "123456789012345678"},
+				{Name: "state", Type: discordgo.ApplicationCommandOptionString, Value: "true"},
+			},
+		}}
+		args, err := argsFromOptions(cmd, options)
+		if err != nil {
+			t.Logf("Failed to read valid options: %s", err.Error())
+			t.FailNow()
+		}
+		if args.Subcommand != "whitelist" || args.Values["user"] != "123456789012345678" || args.Values["state"] != "true" {
+			t.Logf("Options read incorrectly: %+v", args)
+			t.Fail()
+		}
+	})
+
+	t.Run("specs become typed slash command options", func(t *testing.T) {
+		options := specsToOptions([]argSpec{optional(restArg("reason")), userArg("user"), intArg("number", 1, 100)})
+		if len(options) != 3 || options[0].Name != "user" || options[1].Name != "number" {
+			t.Logf("Required options were not moved first: %+v", options)
+			t.FailNow()
+		}
+		if options[1].Type != discordgo.ApplicationCommandOptionInteger || *options[1].MinValue != 1 || options[1].MaxValue != 100 {
+			t.Logf("Number option built incorrectly: %+v", options[1])
+			t.Fail()
+		}
+
+		options = applicationCommandOptions(command{subcommands: map[string][]argSpec{"whitelist": {}, "ändern": {}}})
+		if len(options) != 2 || options[0].Description != "Whitelist" || options[1].Description != "Ändern" {
+			t.Logf("Subcommands described incorrectly: %+v", options)
+			t.Fail()
+		}
+	})
+}
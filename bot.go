@@ -1,13 +1,12 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"os/signal"
 	"regexp"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -16,184 +15,304 @@ import (
 )
 
 var start time.Time
-var prodMode bool
-var globalImageSet []*ImageSet
-var globalInactiveSet []*InactiveSet
-var prefix string
 var commandList map[string]command
-var debug bool
+var commandAliases map[string]string
 
-type handler func(*discordgo.Session, *discordgo.MessageCreate, []string)
+type handler func(*discordgo.Session, *Invocation, *Args)
 
 type command struct {
-	handle handler
-	usage  string
+	handle      handler
+	usage       string
+	args        []argSpec
+	subcommands map[string][]argSpec
+
+	// shown by ~help and used as the slash command description
+	category    string
+	description string
+	examples    []string
+	permission  int64 // required to use the command; 0 if anyone can
+	// required instead for these subcommands
+	subcommandPermissions map[string]int64
+	// required instead when the command's user argument is someone else
+	othersPermission int64
+
+	aliases   []string
+	cooldowns []cooldown
+	ownerOnly bool // only the bot's owners can use it, regardless of permissions
 }
 
-func appendToGlobalImageSet(s *discordgo.Session, newset ImageSet) {
-	globalImageSet = append(globalImageSet, &newset)
-	logInfo("Global Image Set:")
-	logInfo(fmt.Sprintf("%+v\n", globalImageSet))
-
-	time.Sleep(30 * time.Minute)
-
-	for i, set := range globalImageSet {
-		if &newset == set {
-			tmpSet := globalImageSet[0]
-			globalImageSet[0] = globalImageSet[i]
-			globalImageSet[i] = tmpSet
-			globalImageSet = globalImageSet[1:]
-			err := s.MessageReactionsRemoveAll(newset.Message.ChannelID, newset.Message.ID)
-			if err != nil {
-				logError("Failed to remove reactions from the image embed! " + err.Error())
-			}
-		}
-	}
-}
-
-func appendToGlobalInactiveSet(s *discordgo.Session, newset InactiveSet) {
-	globalInactiveSet = append(globalInactiveSet, &newset)
-	logInfo("Global Image Set:")
-	logInfo(fmt.Sprintf("%+v\n", globalInactiveSet))
-
-	time.Sleep(30 * time.Minute)
-
-	for i, set := range globalInactiveSet {
-		if &newset == set {
-			tmpSet := globalInactiveSet[0]
-			globalInactiveSet[0] = globalInactiveSet[i]
-			globalInactiveSet[i] = tmpSet
-			globalInactiveSet = globalInactiveSet[1:]
-			err := s.MessageReactionsRemoveAll(newset.Message.ChannelID, newset.Message.ID)
-			if err != nil {
-				logError("Failed to remove reactions from the inactivity list! " + err.Error())
-			}
+/**
+Initialize command information
+*/
+func initCommandInfo() {
+	commandList = map[string]command{
+		"uptime": {handle: handleUptime, usage: "~uptime",
+			category: "Utility", description: "Shows how long the bot has been running."},
+		"invite": {handle: handleInvite, usage: "~invite",
+			category: "Utility", description: "Creates an invite to this channel that lasts 6 hours.",
+			permission: discordgo.PermissionCreateInstantInvite},
+		"profile": {handle: attemptProfile, usage: "~profile @user", args: []argSpec{userArg("user")},
+			category: "Utility", description: "Shows a user's profile picture in full size.",
+			examples: []string{"~profile @sage"}},
+		"about": {handle: attemptAbout, usage: "~about @user", args: []argSpec{userArg("user")},
+			category: "Utility", description: "Shows when a user joined the server, their nickname and their roles.",
+			examples: []string{"~about @sage"}},
+		"convert": {handle: handleConvert, usage: "~convert <time> <IANA timezone>\nhttps://en.wikipedia.org/wiki/List_of_tz_database_time_zones", args: []argSpec{wordArg("time"), wordArg("timezone")},
+			category: "Utility", description: "Converts a time in a timezone to everyone's local time.",
+			examples: []string{"~convert 3:00PM America/New_York", "~convert 15:00 Europe/London"}},
+		"help": {handle: handleHelp, usage: "~help (command: optional)", args: []argSpec{optional(wordArg("command"))},
+			category: "Utility", description: "Lists the commands you can use, or explains a single command.",
+			examples: []string{"~help", "~help greeter"}, aliases: []string{"commands"}},
+
+		"define": {handle: handleDefine, usage: "~define <word / phrase>", args: []argSpec{restArg("query")},
+			category: "Lookup", description: "Looks up the dictionary definitions of a word or phrase.",
+			examples: []string{"~define serendipity"},
+			aliases: []string{"def"}, cooldowns: []cooldown{perUser(3, time.Minute)}},
+		"urban": {handle: handleUrban, usage: "~urban <word / phrase>", args: []argSpec{restArg("query")},
+			category: "Lookup", description: "Looks up the Urban Dictionary definitions of a word or phrase.",
+			examples: []string{"~urban yeet"},
+			aliases: []string{"ud"}, cooldowns: []cooldown{perUser(3, time.Minute)}},
+		"google": {handle: handleGoogle, usage: "~google <word / phrase>", args: []argSpec{restArg("query")},
+			category: "Lookup", description: "Shows the top five Google results for a search.",
+			examples: []string{"~google blacksburg restaurants"},
+			aliases: []string{"g"}, cooldowns: []cooldown{perUser(3, time.Minute), perChannel(10, time.Minute)}},
+		"image": {handle: handleImage, usage: "~image <word / phrase>", args: []argSpec{restArg("query")},
+			category: "Lookup", description: "Shows Google image results that you can scroll through.",
+			examples: []string{"~image gecko"},
+			// the custom search API only allows 100 queries a day across every server
+			aliases: []string{"img"}, cooldowns: []cooldown{perUser(2, time.Minute), perGuild(25, 24*time.Hour), perBot(100, 24*time.Hour)}},
+		"wiki": {handle: handleWiki, usage: "~wiki <word / phrase>", args: []argSpec{restArg("query")},
+			category: "Lookup", description: "Shows the summary of a Wikipedia article.",
+			examples: []string{"~wiki pandora's box"},
+			aliases: []string{"w"}, cooldowns: []cooldown{perUser(3, time.Minute)}},
+
+		"nick": {handle: handleNickname, usage: "~nick @user <nickname>", args: []argSpec{userArg("user"), restArg("nickname")},
+			category: "Moderation", description: "Changes your own nickname, or anyone's if you can manage nicknames.",
+			examples: []string{"~nick @sage the sage"}, permission: discordgo.PermissionChangeNickname, othersPermission: discordgo.PermissionManageNicknames},
+		"kick": {handle: handleKick, usage: "~kick @user (reason: optional)", args: []argSpec{userArg("user"), optional(restArg("reason"))},
+			category: "Moderation", description: "Kicks a user from the server and DMs them the reason.",
+			examples: []string{"~kick @sage", "~kick @sage spamming in #general"}, permission: discordgo.PermissionKickMembers},
+		"ban": {handle: handleBan, usage: "~ban @user (reason: optional)", args: []argSpec{userArg("user"), optional(restArg("reason"))},
+			category: "Moderation", description: "Bans a user from the server and DMs them the reason.",
+			examples: []string{"~ban @sage", "~ban @sage posting scam links"}, permission: discordgo.PermissionBanMembers},
+		"timeout": {handle: handleTimeout, usage: "~timeout @user <duration> (reason: optional)", args: []argSpec{userArg("user"), durationArg("duration"), optional(restArg("reason"))},
+			category: "Moderation", description: "Stops a user from talking, reacting or joining voice for up to 28 days and DMs them the reason.",
+			examples: []string{"~timeout @sage 30m", "~timeout @sage 2d arguing in #general"}, permission: discordgo.PermissionModerateMembers},
+		"tempban": {handle: handleTempBan, usage: "~tempban @user <duration> (reason: optional)", args: []argSpec{userArg("user"), durationArg("duration"), optional(restArg("reason"))},
+			category: "Moderation", description: "Bans a user, DMs them the reason and unbans them once the time is up.",
+			examples: []string{"~tempban @sage 1w", "~tempban @sage 3d posting scam links"}, permission: discordgo.PermissionBanMembers},
+		"warn": {handle: handleWarn, usage: "~warn @user <reason>", args: []argSpec{userArg("user"), restArg("reason")},
+			category: "Moderation", description: "Warns a user, DMs them the reason and punishes them if they reach a step of the escalation ladder.",
+			examples: []string{"~warn @sage spamming in #general"}, permission: discordgo.PermissionModerateMembers},
+		"warnings": {handle: handleWarnings, usage: "~warnings @user", args: []argSpec{userArg("user")},
+			category: "Moderation", description: "Lists a user's warnings.",
+			examples: []string{"~warnings @sage"}, permission: discordgo.PermissionModerateMembers},
+		"unwarn": {handle: handleUnwarn, usage: "~unwarn <warning number>", args: []argSpec{intArg("id", 1, 0)},
+			category: "Moderation", description: "Removes a single warning.",
+			examples: []string{"~unwarn 12"}, permission: discordgo.PermissionModerateMembers},
+		"clearwarns": {handle: handleClearWarns, usage: "~clearwarns @user", args: []argSpec{userArg("user")},
+			category: "Moderation", description: "Removes all of a user's warnings.",
+			examples: []string{"~clearwarns @sage"}, permission: discordgo.PermissionModerateMembers},
+		"escalation": {handle: handleEscalation, usage: "~escalation list / set <warnings> <timeout/kick/ban> (duration: timeouts only) / remove <warnings>", subcommands: map[string][]argSpec{
+			"list":   {},
+			"set":    {intArg("warnings", 1, 100), choiceArg("action", "timeout", "kick", "ban"), optional(durationArg("duration"))},
+			"remove": {intArg("warnings", 1, 100)},
+		}, category: "Moderation", description: "Picks what happens to members once they reach a number of warnings.",
+			examples: []string{"~escalation set 3 timeout 1h", "~escalation set 5 kick", "~escalation set 7 ban", "~escalation list"},
+			permission: discordgo.PermissionManageServer},
+		"case": {handle: handleCase, usage: "~case <case number>", args: []argSpec{intArg("number", 1, 0)},
+			category: "Moderation", description: "Shows a moderation case and links to its mod log entry.",
+			examples: []string{"~case 42"}, permission: discordgo.PermissionModerateMembers},
+		"reason": {handle: handleReason, usage: "~reason <case number> <new reason>", args: []argSpec{intArg("number", 1, 0), restArg("reason")},
+			category: "Moderation", description: "Changes the reason of a moderation case and edits its mod log entry to match.",
+			examples: []string{"~reason 42 posting scam links"}, permission: discordgo.PermissionModerateMembers},
+		"history": {handle: handleHistory, usage: "~history @user", args: []argSpec{userArg("user")},
+			category: "Moderation", description: "Lists every moderation case against a user.",
+			examples: []string{"~history @sage"}, permission: discordgo.PermissionModerateMembers},
+		"snipe": {handle: handleSnipe, usage: "~snipe", category: "Moderation", description: "Shows the last message deleted in this channel.",
+			examples: []string{"~snipe"}, permission: discordgo.PermissionManageMessages},
+		"editsnipe": {handle: handleEditSnipe, usage: "~editsnipe", category: "Moderation", description: "Shows the last message edited in this channel, before and after.",
+			examples: []string{"~editsnipe"}, permission: discordgo.PermissionManageMessages},
+		"purge": {handle: handlePurge, usage: "~purge <number>", args: []argSpec{intArg("number", 1, 0)},
+			category: "Moderation", description: "Deletes the most recent messages in this channel.",
+			examples: []string{"~purge 20"}, permission: discordgo.PermissionManageMessages},
+		"cp": {handle: handleCopy, usage: "~cp <number <= 100> #channel", args: []argSpec{intArg("number", 1, 100), channelArg("channel")},
+			category: "Moderation", description: "Copies the most recent messages in this channel to another channel.",
+			examples: []string{"~cp 10 #archive"}, permission: discordgo.PermissionManageMessages},
+		"mv": {handle: handleMove, usage: "~mv <number <= 100> #channel", args: []argSpec{intArg("number", 1, 100), channelArg("channel")},
+			category: "Moderation", description: "Moves the most recent messages in this channel to another channel.",
+			examples: []string{"~mv 10 #off-topic"}, permission: discordgo.PermissionManageMessages},
+		"emoji": {handle: emoji, usage: "~emoji help", subcommands: map[string][]argSpec{
+			"help":   {},
+			"create": {wordArg("name"), wordArg("url")},
+			"rename": {emojiArg("emoji"), wordArg("name")},
+			"delete": {emojiArg("emoji")},
+		}, category: "Moderation", description: "Creates, renames and deletes the server's custom emojis.",
+			examples: []string{"~emoji create pog https://example.com/pog.png", "~emoji delete :pog:"}, permission: discordgo.PermissionManageEmojis},
+
+		"vcdeaf": {handle: vcDeaf, usage: "~vcdeaf @user", args: []argSpec{userArg("user")},
+			category: "Voice", description: "Toggles whether a user is deafened in voice chat.",
+			permission: discordgo.PermissionVoiceDeafenMembers},
+		"vcmute": {handle: vcMute, usage: "~vcmute @user", args: []argSpec{userArg("user")},
+			category: "Voice", description: "Toggles whether a user is muted in voice chat.",
+			permission: discordgo.PermissionVoiceMuteMembers},
+		"vcmove": {handle: vcMove, usage: "~vcmove @user #!channel", args: []argSpec{userArg("user"), channelArg("channel")},
+			category: "Voice", description: "Moves a user to another voice channel.",
+			permission: discordgo.PermissionVoiceDeafenMembers},
+		"vckick": {handle: vcKick, usage: "~vckick @user", args: []argSpec{userArg("user")},
+			category: "Voice", description: "Disconnects a user from voice chat.",
+			permission: discordgo.PermissionVoiceMuteMembers},
+
+		"activity": {handle: activity, usage: "~activity help", subcommands: map[string][]argSpec{
+			"help":      {},
+			"rescan":    {},
+			"user":      {userArg("user")},
+			"list":      {intArg("days", 0, 0)},
+			"autokick":  {optional(intArg("days", 0, 0))},
+			"whitelist": {userArg("user"), choiceArg("state", "true", "false")},
+		}, category: "Server", description: "Tracks when members were last active and kicks inactive members.",
+			examples: []string{"~activity list 30", "~activity autokick 90", "~activity whitelist @sage true"},
+			subcommandPermissions: map[string]int64{"autokick": discordgo.PermissionManageServer, "whitelist": discordgo.PermissionKickMembers}},
+		"leaderboard": {handle: leaderboard, usage: "~leaderboard",
+			category: "Server", description: "Shows the members with the most points on this server.",
+			aliases: []string{"lb"}},
+		"greeter": {handle: greeter, usage: "~greeter help", subcommands: map[string][]argSpec{
+			"help":   {},
+			"status": {},
+			"set":    {choiceArg("type", "join", "leave"), channelArg("channel"), restArg("message"), flagArg("img")},
+			"reset":  {choiceArg("type", "join", "leave")},
+		}, category: "Server", description: "Posts a message when members join or leave the server.",
+			examples: []string{"~greeter set join #welcome Welcome, <<ping>>!", "~greeter reset leave"}, permission: discordgo.PermissionManageServer},
+		"modlog": {handle: setModLogChannel, usage: "~modlog set #channel / ~modlog reset / ~modlog events / ~modlog enable <event> / ~modlog disable <event>", subcommands: map[string][]argSpec{
+			"set":     {channelArg("channel")},
+			"reset":   {},
+			"events":  {},
+			"enable":  {modLogEventArg()},
+			"disable": {modLogEventArg()},
+		}, category: "Server", description: "Picks where moderator actions and server changes are logged, and which of them are.",
+			examples: []string{"~modlog set #mod-log", "~modlog events", "~modlog disable edits"}, permission: discordgo.PermissionManageServer},
+		"prefix": {handle: handlePrefix, usage: "~prefix set <prefix> / ~prefix reset", subcommands: map[string][]argSpec{
+			"":      {},
+			"set":   {wordArg("prefix")},
+			"reset": {},
+		}, category: "Server", description: "Shows or changes the prefix the bot listens for on this server.",
+			examples: []string{"~prefix", "~prefix set !"}, permission: discordgo.PermissionManageServer},
+		"config": {handle: handleConfig, usage: "~config command <name / all> <enable/disable/reset> (#channel: optional) / ~config status", subcommands: map[string][]argSpec{
+			"status":  {},
+			"command": {wordArg("name"), choiceArg("state", "enable", "disable", "reset"), optional(channelArg("channel"))},
+		}, category: "Server", description: "Turns commands on or off for the whole server or for single channels.",
+			examples: []string{"~config command image disable #general", "~config command perk enable #dbd", "~config command all disable #testing", "~config status"},
+			permission: discordgo.PermissionManageServer},
+		"perms": {handle: handlePerms, usage: "~perms allow/deny <command> <@role / @user> / ~perms reset <command> (@role / @user: optional) / ~perms list (command: optional)", subcommands: map[string][]argSpec{
+			"allow": {wordArg("command"), targetArg("target")},
+			"deny":  {wordArg("command"), targetArg("target")},
+			"reset": {wordArg("command"), optional(targetArg("target"))},
+			"list":  {optional(wordArg("command"))},
+		}, category: "Server", description: "Lets roles or members use commands without their usual permissions, or blocks them.",
+			examples: []string{"~perms allow purge @Helpers", "~perms deny image @sage", "~perms reset purge", "~perms list"},
+			permission: discordgo.PermissionManageServer},
+
+		"perk": {handle: handlePerk, usage: "~perk <perk name>", args: []argSpec{restArg("name")},
+			category: "Dead by Daylight", description: "Looks up a perk on the Dead by Daylight wiki.",
+			examples: []string{"~perk decisive strike"}},
+		"addon": {handle: handleAddon, usage: "~addon <addon name>", args: []argSpec{restArg("name")},
+			category: "Dead by Daylight", description: "Looks up an add-on on the Dead by Daylight wiki.",
+			examples: []string{"~addon mew's guts"}},
+		"killer": {handle: handleKiller, usage: "~killer <killer name>", args: []argSpec{restArg("name")},
+			category: "Dead by Daylight", description: "Looks up a killer on the Dead by Daylight wiki.",
+			examples: []string{"~killer the trapper"}},
+		"survivor": {handle: handleSurvivor, usage: "~survivor <survivor name>", args: []argSpec{restArg("name")},
+			category: "Dead by Daylight", description: "Looks up a survivor on the Dead by Daylight wiki.",
+			examples: []string{"~survivor dwight fairfield"}},
+		"shrine": {handle: handleShrine, usage: "~shrine",
+			category: "Dead by Daylight", description: "Shows the perks in this week's Shrine of Secrets."},
+		"autoshrine": {handle: handleAutoshrine, usage: "~autoshrine set #channel / ~autoshrine reset", subcommands: map[string][]argSpec{
+			"set":   {channelArg("channel")},
+			"reset": {},
+		}, category: "Dead by Daylight", description: "Posts the new Shrine of Secrets in a channel every week.",
+			examples: []string{"~autoshrine set #dbd"}, permission: discordgo.PermissionManageServer},
+
+		"owner": {handle: handleOwner, usage: "~owner shutdown / restart / reload / guilds / leave <guild ID> / broadcast <message>", subcommands: map[string][]argSpec{
+			"shutdown":  {},
+			"restart":   {},
+			"reload":    {},
+			"guilds":    {},
+			"leave":     {idArg("guild")},
+			"broadcast": {restArg("message")},
+		}, category: "Owner", description: "Controls the bot itself. Only the bot's owners can use this.",
+			examples: []string{"~owner restart", "~owner leave 739852388264968243", "~owner broadcast The bot will be down for maintenance tonight."},
+			ownerOnly: true},
+		"jobs": {handle: handleJobs, usage: "~jobs list / run <job> / pause <job> / resume <job>", subcommands: map[string][]argSpec{
+			"list":   {},
+			"run":    {wordArg("job")},
+			"pause":  {wordArg("job")},
+			"resume": {wordArg("job")},
+		}, category: "Owner", description: "Lists the bot's background jobs and how they last went, runs one now, or pauses it.",
+			examples: []string{"~jobs list", "~jobs run shrine", "~jobs pause autokick"}, ownerOnly: true},
+		"loglevel": {handle: handleLogLevel, usage: "~loglevel (debug/info/warn/error: optional)", args: []argSpec{optional(choiceArg("level", "debug", "info", "warn", "error"))},
+			category: "Owner", description: "Shows or changes how much the bot logs.",
+			examples: []string{"~loglevel", "~loglevel debug"}, ownerOnly: true},
+	}
+
+	commandAliases = make(map[string]string)
+	for name, cmd := range commandList {
+		for _, alias := range cmd.aliases {
+			commandAliases[alias] = name
 		}
 	}
 }
 
 /**
-Initialize command information and prefix
+Returns the name of the command the invoke word refers to, following aliases.
 */
-func initCommandInfo() {
-	prefix = "~"
-	commandList = map[string]command{
-		"uptime":      {handleUptime, "~uptime"},
-		"shutdown":    {handleShutdown, "~shutdown"},
-		"invite":      {handleInvite, "~invite"},
-		"profile":     {attemptProfile, "~profile"},
-		"nick":        {handleNickname, "~nick @user (reason: optional)"},
-		"kick":        {handleKick, "~kick @user (reason: optional)"},
-		"ban":         {handleBan, "~ban @user (reason: optional)"},
-		"mv":          {handleMove, "~mv <number> #channel"},
-		"cp":          {handleCopy, "~cp <number> #channel"},
-		"purge":       {handlePurge, "~purge <number>"},
-		"define":      {handleDefine, "~define <word / phrase>"},
-		"urban":       {handleUrban, "~urban <word / phrase>"},
-		"google":      {handleGoogle, "~google <word / phrase>"},
-		"image":       {handleImage, "~image <word / phrase>"},
-		"convert":     {handleConvert, "~convert <time> <IANA timezone>\nhttps://en.wikipedia.org/wiki/List_of_tz_database_time_zones"},
-		"perk":        {handlePerk, "~perk <perk name>"},
-		"shrine":      {handleShrine, "~shrine"},
-		"autoshrine":  {handleAutoshrine, "~autoshrine set #channel / ~autoshrine reset"},
-		"help":        {handleHelp, "~help"},
-		"wiki":        {handleWiki, "~wiki <word / phrase>"},
-		"about":       {attemptAbout, "~about @user"},
-		"activity":    {activity, "~activity help"},
-		"leaderboard": {leaderboard, "~leaderboard"},
-		"greeter":     {greeter, "~greeter help"},
-		"modlog":      {setModLogChannel, "~modlog set #channel / ~modlog reset"},
-		"addon":       {handleAddon, "~addon <addon name>"},
-		"killer":      {handleKiller, "~killer <killer name>"},
-		"survivor":    {handleSurvivor, "~survivor <survivor name>"},
-		"emoji":       {emoji, "~emoji help"},
-		"vcdeaf":      {vcDeaf, "~vcdeaf @user"},
-		"vcmute":      {vcMute, "~vcmute @user"},
-		"vcmove":      {vcMove, "~vcmove @user #!channel"},
-		"vckick":      {vcKick, "~vckick @user"},
+func resolveCommand(invokeWord string) string {
+	invokeWord = strings.ToLower(invokeWord)
+	if name, ok := commandAliases[invokeWord]; ok {
+		return name
 	}
+	return invokeWord
 }
 
 func runBot(token string) {
-	// FOR DEBUG INFO!
-	debug = true
-
-	fmt.Println(discordgo.VERSION)
-
-	logInfo("Starting the application")
-
-	// Playing...
-	statuses := [...]string{
-		"VSCode",
-		"with print statements instead of debugging properly",
-		"video games instead of doing my classwork",
-		"with scissors",
-		"Hentai Killer VR",
-		"with people who unironically use Ripcord",
-		"with unsafe syscalls",
-		"PuTTY",
-		"Human Simulator 2",
+	// read the config file and environment before anything else uses them
+	err := initConfig()
+	if err != nil {
+		logError("Could not load the configuration. Shutting down. " + err.Error())
+		return
 	}
-
-	dbUsername = os.Getenv("DB_USERNAME")
-	dbPassword = os.Getenv("DB_PASSWORD")
-	dbName = os.Getenv("DB")
-	dbHost = os.Getenv("DB_HOST")
-	activityTable = os.Getenv("ACTIVITY_TABLE")
-	leaderboardTable = os.Getenv("LEADERBOARD_TABLE")
-	joinLeaveTable = os.Getenv("JOIN_LEAVE_TABLE")
-	autokickTable = os.Getenv("AUTOKICK_TABLE")
-	modLogTable = os.Getenv("MODLOG_TABLE")
-	autoshrineTable = os.Getenv("AUTOSHRINE_TABLE")
-
-	// open connection to database
-	retry := 90
-	var db *sql.DB
-	var err error
-	dbConnectStr := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s", dbUsername, dbPassword, dbHost, dbName)
-	logInfo(dbConnectStr)
-	for db == nil && retry > 0 {
-		logInfo("Calling sql.open")
-		db, err = sql.Open("mysql", dbConnectStr)
-		if err != nil {
-			logWarning("Unable to open DB connection! " + err.Error())
-		}
-		time.Sleep(1 * time.Second)
-		retry--
+	if token == "" {
+		logError("BOT_TOKEN is not set. Shutting down.")
+		return
 	}
-	if db == nil {
-		logError("Could not open DB connection after many retries. Shutting down.")
+	configureLogging()
+
+	logInfo("Starting the application with discordgo " + discordgo.VERSION)
+
+	loadTableNames()
+	loadHTTPConfig()
+	loadOwners()
+
+	// open the database, creating any missing tables
+	storage, err = openStorage()
+	if err != nil {
+		logError("Could not open the database. Shutting down. " + err.Error())
 		return
-	} else {
-		logInfo("Connected to database.")
 	}
-	defer db.Close()
-	connection_pool = db
-
-	// create tables if they don't exist
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+activityTable+" (entry int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY, guild_id char(20), member_id char(20), member_name char(40), last_active char(70), description char(80), whitelist boolean);",
-		"Created activity table",
-		"Unable to create activity table")
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+leaderboardTable+" (entry int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY, guild_id char(20), member_id char(20), member_name char(40), points int(11), last_awarded char(70));",
-		"Created leaderboard table",
-		"Failed to create leaderboard table")
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+joinLeaveTable+" (entry int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY, guild_id char(20), channel_id char(20), message_type char(5), image_link varchar(1000), message varchar(2000));",
-		"Created join/leave table",
-		"Failed to create join/leave table")
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+autokickTable+" (guild_id char(20) PRIMARY KEY, days_until_kick int(11));",
-		"Created autokick table",
-		"Failed to create autokick table")
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+modLogTable+" (guild_id char(20) PRIMARY KEY, channel_id char(20));",
-		"Created mod log table",
-		"Failed to create mod log table")
-	attemptQuery("CREATE TABLE IF NOT EXISTS "+autoshrineTable+" (guild_id char(20) PRIMARY KEY, channel_id char(20));",
-		"Created auto shrine table",
-		"Failed to create auto shrine table")
+	defer storage.Close()
+
+	// pick up the paginators that were active before a restart
+	err = loadPaginators()
+	if err != nil {
+		logError("Unable to load paginators! " + err.Error())
+	}
+	err = loadMessageCache()
+	if err != nil {
+		logError("Unable to load the message cache! " + err.Error())
+	}
 
 	/** Open Connection to Discord **/
-	if os.Getenv("PROD_MODE") == "true" {
+	if currentConfig().ProdMode {
 		logWarning("Production mode is active")
-		prodMode = true
 	}
 	start = time.Now()
 
@@ -204,6 +323,9 @@ func runBot(token string) {
 		return
 	}
 
+	// serve /healthz, /readyz and /metrics
+	startStatusServer(dg)
+
 	// add listeners
 	dg.AddHandler(messageCreate)
 	dg.AddHandler(messageReactionAdd)
@@ -214,7 +336,19 @@ func runBot(token string) {
 	dg.AddHandler(guildBanAdd)
 	dg.AddHandler(guildBanRemove)
 	dg.AddHandler(guildEmojisUpdate)
+	dg.AddHandler(messageUpdate)
+	dg.AddHandler(messageDelete)
+	dg.AddHandler(messageDeleteBulk)
+	dg.AddHandler(channelCreate)
+	dg.AddHandler(channelUpdate)
+	dg.AddHandler(channelDelete)
+	dg.AddHandler(guildRoleCreate)
+	dg.AddHandler(guildRoleUpdate)
+	dg.AddHandler(guildRoleDelete)
+	dg.AddHandler(guildMemberUpdate)
+	dg.AddHandler(guildUpdate)
 	dg.AddHandler(voiceStateUpdate)
+	dg.AddHandler(interactionCreate)
 
 	dg.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentsGuildMembers | discordgo.IntentsGuilds
 
@@ -226,153 +360,131 @@ func runBot(token string) {
 	}
 
 	initCommandInfo()
-
-	// start rotating statuses
-	go rotateStatuses(dg, statuses[:])
-
-	// start auto-kick listener
-	go runAutoKicker(dg)
-
-	// start waiting for the new shrine
-	go runNewShrineDetection(dg)
+	registerApplicationCommands(dg)
+
+	// start the background jobs; ~jobs lists them
+	rand.Seed(time.Now().UnixNano())
+	jobSettings := currentConfig().Jobs
+	scheduleJob(&job{name: "status", schedule: every(jobSettings.StatusInterval), runAtStart: true,
+		run: func() error { return rotateStatus(dg, currentConfig().Statuses) }})
+	scheduleJob(&job{name: "cooldowns", schedule: every(10 * time.Minute),
+		run: func() error { pruneCooldowns(time.Now()); return nil }})
+	scheduleJob(&job{name: "autokick", schedule: mustParseCron(jobSettings.Autokick), jitter: 5 * time.Minute, retries: 3, backoff: time.Minute,
+		run: func() error { return autoKick(dg) }})
+	scheduleJob(&job{name: "shrine", schedule: every(jobSettings.ShrineInterval), runAtStart: true, retries: 2, backoff: 30 * time.Second,
+		run: newShrineDetector(dg)})
+	scheduleJob(&job{name: "paginator_expiry", schedule: every(time.Minute), runAtStart: true,
+		run: func() error { expirePaginators(dg, time.Now()); return nil }})
+	scheduleJob(&job{name: "tempbans", schedule: every(time.Minute), runAtStart: true, retries: 2, backoff: 10 * time.Second,
+		run: func() error { return liftExpiredTempBans(dg, time.Now()) }})
+	scheduleJob(&job{name: "message_cache", schedule: every(5 * time.Minute), retries: 2, backoff: 10 * time.Second,
+		run: func() error { return pruneMessageCache(time.Now()) }})
+	scheduleJob(&job{name: "config", schedule: every(30 * time.Second),
+		run: reloadChangedConfig})
 
 	// Wait here until CTRL-C or other term signal is received.
-	fmt.Println("Bot is now running.  Press CTRL-C to exit.")
+	setReady(true)
+	logSuccess("Bot is now running.  Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
+	setReady(false)
 
 	// Cleanly close down the Discord session
 	dg.Close()
+	flushMessageCache()
 }
 
-func rotateStatuses(dg *discordgo.Session, statuses []string) {
-	for {
-		rand.Seed(time.Now().Unix())
-		n := rand.Intn(len(statuses))
-		err := dg.UpdateGameStatus(0, statuses[n])
-		if err != nil {
-			logError("Failed to select a new game status! " + err.Error())
-		} else {
-			logSuccess("Updated game status to: " + statuses[n])
-		}
-
-		time.Sleep(2 * time.Hour)
+/**
+Picks a random game status.
+*/
+func rotateStatus(dg *discordgo.Session, statuses []string) error {
+	n := rand.Intn(len(statuses))
+	err := dg.UpdateGameStatus(0, statuses[n])
+	if err != nil {
+		return fmt.Errorf("failed to select a new game status: %w", err)
 	}
+	logSuccess("Updated game status to: " + statuses[n])
+	return nil
 }
 
-func runAutoKicker(dg *discordgo.Session) {
-	for {
-		logWarning("Performing auto-kick")
-		// 1. get days_until_kick for each guild
-		query, err := connection_pool.Query(fmt.Sprintf("SELECT * FROM %s;", autokickTable))
+/**
+Kicks members who have been inactive for longer than their guild allows.
+*/
+func autoKick(dg *discordgo.Session) error {
+	logWarning("Performing auto-kick")
+	// 1. get days_until_kick for each guild
+	autokicks, err := storage.GetAutoKicks()
+	if err != nil {
+		return fmt.Errorf("unable to read the auto-kick delays: %w", err)
+	}
+
+	// a guild that can't be read shouldn't stop the others from being checked
+	var problems []string
+	for _, autokickData := range autokicks {
+		// 2. get all users from member activity table that are not whitelisted and are in the given guild
+		memberActivities, err := storage.GetKickableActivity(autokickData.GuildID)
 		if err != nil {
-			logError("SELECT query error: " + err.Error())
-			return
+			logError("Unable to read member activity of guild " + autokickData.GuildID + "! " + err.Error())
+			problems = append(problems, autokickData.GuildID+": "+err.Error())
+			continue
 		}
 
-		for query.Next() {
-			var autokickData AutoKickData
-			err = query.Scan(&autokickData.GuildID, &autokickData.DaysUntilKick)
-			if err != nil {
-				logError("Unable to parse database information! Aborting. " + err.Error())
-				return
+		for _, memberActivity := range memberActivities {
+			// never kick someone whose last activity is unknown
+			if memberActivity.LastActive.IsZero() {
+				continue
 			}
-
-			// 2. get all users from member activity table that are not whitelisted and are in the given guild
-			memberQuery, err := connection_pool.Query(fmt.Sprintf("SELECT * FROM %s WHERE (guild_id = ? AND whitelist = false);", activityTable), autokickData.GuildID)
-			if err != nil {
-				logError("SELECT query error: " + err.Error())
-				return
-			}
-
-			for memberQuery.Next() {
-				var memberActivity MemberActivity
-				err = memberQuery.Scan(&memberActivity.ID, &memberActivity.GuildID, &memberActivity.MemberID, &memberActivity.MemberName, &memberActivity.LastActive, &memberActivity.Description, &memberActivity.Whitelisted)
+			lastActive := memberActivity.LastActive.AddDate(0, 0, autokickData.DaysUntilKick)
+			if lastActive.Before(time.Now()) {
+				// kick user
+				reason := fmt.Sprintf("Bot detected %d or more days of inactivity.", autokickData.DaysUntilKick)
+				err = dg.GuildMemberDeleteWithReason(autokickData.GuildID, memberActivity.MemberID, reason)
 				if err != nil {
-					logError("Unable to parse database information! Aborting. " + err.Error())
+					logError("Unable to kick user! " + err.Error())
 					continue
 				}
-				dateFormat := "2006-01-02 15:04:05.999999999 -0700 MST"
-				// calculate difference between time.Now() and the provided timestamp
-				lastActive, err := time.Parse(dateFormat, strings.Split(memberActivity.LastActive, " m=")[0])
+				recordCase(dg, ModCase{GuildID: autokickData.GuildID, Action: "kick", UserID: memberActivity.MemberID, ModeratorID: dg.State.User.ID, Reason: reason})
+				guild, err := dg.Guild(autokickData.GuildID)
 				if err != nil {
-					logError("Unable to parse database timestamps! Aborting. " + err.Error())
-					return
+					logError("Unable to load guild! " + err.Error())
 				}
-
-				lastActive = lastActive.AddDate(0, 0, autokickData.DaysUntilKick)
-				if lastActive.Before(time.Now()) {
-					// kick user
-					err = dg.GuildMemberDeleteWithReason(autokickData.GuildID, memberActivity.MemberID, fmt.Sprintf("Bot detected %d or more days of inactivity.", autokickData.DaysUntilKick))
-					if err != nil {
-						logError("Unable to kick user! " + err.Error())
-					}
-					guild, err := dg.Guild(autokickData.GuildID)
-					if err != nil {
-						logError("Unable to load guild! " + err.Error())
-					}
-					guildName := "error: could not retrieve"
-					if guild != nil {
-						guildName = guild.Name
-					}
-					dmUser(dg, memberActivity.MemberID, fmt.Sprintf("You have been automatically kicked from **%s** due to %d or more days of inactivity.", guildName, autokickData.DaysUntilKick))
+				guildName := "error: could not retrieve"
+				if guild != nil {
+					guildName = guild.Name
 				}
+				dmUser(dg, memberActivity.MemberID, fmt.Sprintf("You have been automatically kicked from **%s** due to %d or more days of inactivity.", guildName, autokickData.DaysUntilKick))
 			}
 		}
-		query.Close()
-
-		time.Sleep(6 * time.Hour)
 	}
-}
-
-/**
-Gets the end date of the existing shrine, waits until a few minutes after that, then posts the new shrine in all channels where ~autoshrine was configured.
-*/
-func runNewShrineDetection(dg *discordgo.Session) {
-	old_shrine := scrapeShrine()
-	end_time_unix := old_shrine.End + 600 // add 10 minute buffer period
-	for {
-		if end_time_unix < time.Now().Unix() {
-			new_shrine := scrapeShrine()
-			if new_shrine.End > end_time_unix {
-				handleShrineUpdate(dg)
-				end_time_unix = new_shrine.End
-				end_time_unix += 600 // add 10 minute buffer period
-			}
-		}
-		time.Sleep(15 * time.Minute)
+	if len(problems) > 0 {
+		return fmt.Errorf("unable to read member activity: %s", strings.Join(problems, "; "))
 	}
+	return nil
 }
 
 /**
-Creates an embed displaying all the potential commands and their functions.
+Returns the shrine job: it remembers when the current shrine ends, and after
+that posts the new shrine in all channels where ~autoshrine was configured.
 */
-func handleHelp(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	// return all current commands and what they do
-	var embed discordgo.MessageEmbed
-	embed.Type = "rich"
-
-	embed.Title = "❓ How to Use AiO Bot ❓"
-
-	// add a cute thumbnail
-	var thumbnail discordgo.MessageEmbedThumbnail
-	thumbnail.URL = "https://img.pngio.com/robot-icon-of-flat-style-available-in-svg-png-eps-ai-icon-robot-icon-png-256_256.png"
-	embed.Thumbnail = &thumbnail
-
-	// add all commands to the embed as a set of fields that are not inline
-	embed.Description = "The [Command List](https://cazwacki.github.io/bot-commands.html) is now hosted on Github.IO"
-
-	// self-credit + github profile picture
-	var footer discordgo.MessageEmbedFooter
-	footer.Text = "Created by Charles Zawacki; Written in Go"
-	footer.IconURL = "https://avatars0.githubusercontent.com/u/44577941?s=460&u=4eb7b9ff5410be189eea9863c33916c805dbd2b2&v=4"
-	embed.Footer = &footer
-
-	// send response
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
-	if err != nil {
-		logError("Unable to send message! " + err.Error())
+func newShrineDetector(dg *discordgo.Session) func() error {
+	var endTimeUnix int64
+	return func() error {
+		if endTimeUnix != 0 && endTimeUnix >= time.Now().Unix() {
+			return nil
+		}
+		shrine := scrapeShrine()
+		// an empty shrine means it couldn't be fetched
+		if shrine.End == 0 {
+			return errors.New("unable to fetch the shrine")
+		}
+		if endTimeUnix != 0 && shrine.End > endTimeUnix {
+			handleShrineUpdate(dg)
+		}
+		if shrine.End > endTimeUnix {
+			endTimeUnix = shrine.End + 600 // add 10 minute buffer period
+		}
+		return nil
 	}
 }
 
@@ -382,43 +494,36 @@ a channel that the bot has access to.
 */
 func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	logInfo("Message Create Event")
+	cacheMessage(m.Message)
 	go checkForMessageLink(s, m)
-	go logActivity(m.GuildID, m.Author, time.Now().String(), "Wrote a message in <#"+m.ChannelID+">", false)
-	awardPoints(m.GuildID, m.Author, time.Now().String(), m.Content)
+	go logActivity(m.GuildID, m.Author, time.Now(), "Wrote a message in <#"+m.ChannelID+">", false)
+	awardPoints(m.GuildID, m.Author, time.Now(), m.Content)
 	respondToCommands(s, m)
 }
 
 /**
-Used to handle scrolling through images given from ~image,
-but can and may be used to handle other reactions in the future
+Handler function when the discord session detects a reaction is added to a
+message. Reactions count as activity.
 */
 func messageReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
-	go navigateImages(s, m)
 	user, err := s.User(m.UserID)
 	if err != nil {
 		logError("Could not get the user from the session state! " + err.Error())
 		return
 	}
-	go logActivity(m.GuildID, user, time.Now().String(), "Reacted with :"+m.Emoji.Name+": to a message in <#"+m.ChannelID+">", false)
+	go logActivity(m.GuildID, user, time.Now(), "Reacted with :"+m.Emoji.Name+": to a message in <#"+m.ChannelID+">", false)
 }
 
 func guildMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
-	go logActivity(m.GuildID, m.User, time.Now().String(), "Joined the server", true)
+	go logActivity(m.GuildID, m.User, time.Now(), "Joined the server", true)
 	go joinLeaveMessage(s, m.GuildID, m.User, "join")
 }
 
 func guildMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
 	logInfo("Guild Member Remove Event")
 	go removeUser(m.GuildID, m.User.ID)
-	latestLog, err := s.GuildAuditLog(m.GuildID, "", "", -1, 1)
-	if err != nil {
-		logError("Could not get the guild audit log from the session state! " + err.Error())
-		return
-	}
-	// log mod activity if applicable
-	if latestLog.AuditLogEntries[0].TargetID == m.User.ID && *latestLog.AuditLogEntries[0].ActionType == discordgo.AuditLogActionMemberKick {
-		go logModActivity(s, m.GuildID, latestLog.AuditLogEntries[0])
-	}
+	// log mod activity if they were kicked rather than left
+	go logAuditedModActivity(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberKick)
 	joinLeaveMessage(s, m.GuildID, m.User, "leave")
 }
 
@@ -430,41 +535,15 @@ func guildDelete(s *discordgo.Session, m *discordgo.GuildDelete) {
 	removeGuild(m.ID)
 }
 
-func guildEmojisUpdate(s *discordgo.Session, m *discordgo.GuildEmojisUpdate) {
-	// check audit log for who did it
-	latestLog, err := s.GuildAuditLog(m.GuildID, "", "", -1, 1)
-	if err != nil {
-		logError("Could not get the guild audit log from the session state! " + err.Error())
-		return
-	}
-	fmt.Printf("%+v\n", latestLog)
-	fmt.Printf("%+v\n", latestLog.AuditLogEntries[0])
-	fmt.Printf("%+v\n", latestLog.AuditLogEntries[0].Changes[0])
-}
-
 func guildBanAdd(s *discordgo.Session, m *discordgo.GuildBanAdd) {
 	logInfo("Guild Ban Added")
-	time.Sleep(time.Millisecond * 2000)
-	latestLog, err := s.GuildAuditLog(m.GuildID, "", "", (int)(discordgo.AuditLogActionMemberBanAdd), 1)
-	if err != nil {
-		logError("Could not get the guild audit log from the session state! " + err.Error())
-		return
-	}
-	if latestLog.AuditLogEntries[0].TargetID == m.User.ID {
-		go logModActivity(s, m.GuildID, latestLog.AuditLogEntries[0])
-	}
+	logAuditedModActivity(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberBanAdd)
 }
 
 func guildBanRemove(s *discordgo.Session, m *discordgo.GuildBanRemove) {
 	logInfo("Guild Ban Removed")
-	latestLog, err := s.GuildAuditLog(m.GuildID, "", "", (int)(discordgo.AuditLogActionMemberBanRemove), 1)
-	if err != nil {
-		logError("Could not get the guild audit log from the session state! " + err.Error())
-		return
-	}
-	if latestLog.AuditLogEntries[0].TargetID == m.User.ID {
-		go logModActivity(s, m.GuildID, latestLog.AuditLogEntries[0])
-	}
+	forgetTempBan(m.GuildID, m.User.ID)
+	logAuditedModActivity(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberBanRemove)
 }
 
 func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
@@ -475,24 +554,24 @@ func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
 	}
 	if v.ChannelID == "" {
 		if v.BeforeUpdate != nil {
-			logActivity(v.GuildID, user, time.Now().String(), "Left <#"+v.BeforeUpdate.ChannelID+">", false)
+			logActivity(v.GuildID, user, time.Now(), "Left <#"+v.BeforeUpdate.ChannelID+">", false)
 		} else {
-			logActivity(v.GuildID, user, time.Now().String(), "Left a voice channel", false)
+			logActivity(v.GuildID, user, time.Now(), "Left a voice channel", false)
 		}
 	} else {
-		logActivity(v.GuildID, user, time.Now().String(), "Joined <#"+v.ChannelID+">", false)
+		logActivity(v.GuildID, user, time.Now(), "Joined <#"+v.ChannelID+">", false)
 	}
 }
 
 func checkForMessageLink(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore my testing channel
-	if prodMode && m.ChannelID == "739852388264968243" {
-		return
-	}
 	// Ignore all messages created by the bot itself as well as DMs
 	if m.Author.ID == s.State.User.ID || m.GuildID == "" {
 		return
 	}
+	// Ignore channels where the bot has been turned off
+	if !commandEnabled(m.GuildID, m.ChannelID, allCommands) {
+		return
+	}
 	regex := regexp.MustCompile(`https:\/\/discord.com\/channels\/[0-9]*\/[0-9]*\/[0-9]*`)
 	match := regex.FindAllStringSubmatch(m.Content, -1)
 	logInfo(fmt.Sprintf("Number of matches is %d", len(match)))
@@ -500,7 +579,6 @@ func checkForMessageLink(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// verify the message came from within the guild
 	for _, link := range match {
 		linkData := strings.Split(link[0], "/")
-		fmt.Printf("%s\n", linkData[5])
 		if linkData[4] == m.GuildID {
 			var embed discordgo.MessageEmbed
 			embed.Type = "rich"
@@ -560,7 +638,7 @@ func checkForMessageLink(s *discordgo.Session, m *discordgo.MessageCreate) {
 			embed.Footer = &footer
 
 			// send response
-			_, err = s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+			_, err = sendEmbedToChannel(s, m.ChannelID, &embed)
 			if err != nil {
 				logError("Failed to send message link embed! " + err.Error())
 				return
@@ -571,155 +649,17 @@ func checkForMessageLink(s *discordgo.Session, m *discordgo.MessageCreate) {
 }
 
 func respondToCommands(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore my testing channel
-	if prodMode && m.ChannelID == "739852388264968243" {
-		return
-	}
 	// Ignore all messages created by the bot itself as well as DMs
 	if m.Author.ID == s.State.User.ID || m.GuildID == "" {
 		return
 	}
 
+	guildPrefix := getGuildPrefix(m.GuildID)
 	parsedCommand := strings.Split(m.Content, " ")
-	if !strings.HasPrefix(parsedCommand[0], prefix) {
-		return
-	}
-	invoke_word := strings.TrimPrefix(parsedCommand[0], prefix)
-
-	// get the command information based on the invoke word
-	if validCommand, ok := commandList[invoke_word]; ok {
-		// special case: needs to pass in starting time
-		if parsedCommand[0] == prefix+"uptime" {
-			parsedCommand = []string{start.Format("2006-01-02 15:04:05.999999999 -0700 MST")}
-		}
-		go validCommand.handle(s, m, parsedCommand)
-	}
-}
-
-func navigateImages(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
-	// Ignore all messages created by the bot itself as well as DMs
-	if m.UserID == s.State.User.ID {
+	if !strings.HasPrefix(parsedCommand[0], guildPrefix) {
 		return
 	}
-	for i, set := range globalImageSet {
-		if set.Message.ID == m.MessageID {
-			if m.Emoji.Name == "⬅️" || m.Emoji.Name == "➡️" || m.Emoji.Name == "⏹️" {
-				/*
-					1. Remove the reaction the user made
-					2. Switch the image if the index allows
-					3. Update the index in the set
-				*/
-				if m.Emoji.Name == "⏹️" {
-					logInfo("Removing listener for message ID " + m.MessageID)
-					tmpSet := globalImageSet[0]
-					globalImageSet[0] = globalImageSet[i]
-					globalImageSet[i] = tmpSet
-					globalImageSet = globalImageSet[1:]
-					err := s.MessageReactionsRemoveAll(m.ChannelID, m.MessageID)
-					if err != nil {
-						logError("Failed to remove all reactions from message! " + err.Error())
-						return
-					}
-					logSuccess("Removed from image sets and removed reactions on the message")
-				} else {
-					// craft response and send
-					var embed discordgo.MessageEmbed
-					embed.Type = "rich"
-					embed.Title = "Image Results for \"" + set.Query + "\""
-
-					fmt.Printf("Index before: %d\n", set.Index)
-					if m.Emoji.Name == "⬅️" {
-						if set.Index != 0 {
-							logInfo("Previous image for message ID " + m.MessageID)
-							set.Index--
-						}
-					} else if m.Emoji.Name == "➡️" {
-						if set.Index != len(set.Images)-1 {
-							logInfo("Next image for message ID " + m.MessageID)
-							set.Index++
-						}
-					}
-					fmt.Printf("Index after: %d\n", set.Index)
-
-					var image discordgo.MessageEmbedImage
-					image.URL = set.Images[set.Index]
-					embed.Image = &image
-					var footer discordgo.MessageEmbedFooter
-					footer.Text = fmt.Sprintf("Image %d of %d", set.Index+1, len(set.Images))
-					footer.IconURL = "https://cdn4.iconfinder.com/data/icons/new-google-logo-2015/400/new-google-favicon-512.png"
-					embed.Footer = &footer
-					_, err := s.ChannelMessageEditEmbed(m.ChannelID, m.MessageID, &embed)
-					if err != nil {
-						logError("Failed to send edit image embed! " + err.Error())
-						return
-					}
-					err = s.MessageReactionRemove(m.ChannelID, m.MessageID, m.Emoji.Name, m.UserID)
-					if err != nil {
-						logError("Failed to remove user's reaction! " + err.Error())
-						return
-					}
-					logSuccess("Updated image embed")
-				}
-			}
-		}
-	}
-	for _, set := range globalInactiveSet {
-		if set.Message.ID == m.MessageID {
-			if m.Emoji.Name == "◀️" || m.Emoji.Name == "▶️" {
-				// craft response and send
-				var embed discordgo.MessageEmbed
-				embed.Type = "rich"
-				if set.DaysInactive > 0 {
-					embed.Title = "Users Inactive for " + strconv.Itoa(set.DaysInactive) + "+ Days"
-				} else {
-					embed.Title = "User Activity"
-				}
+	invoke_word := strings.TrimPrefix(parsedCommand[0], guildPrefix)
 
-				pageCount := len(set.Inactives) / 8
-				if len(set.Inactives)%8 != 0 {
-					pageCount++
-				}
-
-				if m.Emoji.Name == "◀️" {
-					if set.Index != 0 {
-						logInfo("Previous page for message ID " + m.MessageID)
-						set.Index--
-					}
-				} else if m.Emoji.Name == "▶️" {
-					if set.Index != pageCount-1 {
-						logInfo("Next page for message ID " + m.MessageID)
-						set.Index++
-					}
-				}
-
-				if set.Index >= 0 && set.Index < pageCount {
-					var contents []*discordgo.MessageEmbedField
-					for i := set.Index * 8; i < set.Index*8+8 && i < len(set.Inactives); i++ {
-						// calculate difference between time.Now() and the provided timestamp
-						dateFormat := "2006-01-02 15:04:05.999999999 -0700 MST"
-						lastActive, err := time.Parse(dateFormat, strings.Split(set.Inactives[i].LastActive, " m=")[0])
-						if err != nil {
-							logError("Unable to parse database timestamps! Aborting. " + err.Error())
-							return
-						}
-						fieldValue := "- " + lastActive.Format("01/02/2006 15:04:05") + "\n- " + set.Inactives[i].Description
-						// add whitelist state
-						if set.Inactives[i].Whitelisted == 1 {
-							fieldValue += "\n- Protected from auto-kick"
-						}
-
-						contents = append(contents, createField(set.Inactives[i].MemberName, fieldValue, false))
-					}
-					embed.Fields = contents
-
-					var footer discordgo.MessageEmbedFooter
-					footer.Text = fmt.Sprintf("Page %d of %d", set.Index+1, pageCount)
-					embed.Footer = &footer
-
-					s.ChannelMessageEditEmbed(m.ChannelID, m.MessageID, &embed)
-					s.MessageReactionRemove(m.ChannelID, m.MessageID, m.Emoji.Name, m.UserID)
-				}
-			}
-		}
-	}
+	go dispatchCommand(s, invocationFromMessage(m), invoke_word)
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var caseTable string
+
+/**
+A numbered record of a moderation action. Numbers count up from 1 in each
+guild. LogChannelID and LogMessageID point at the case's mod log entry, if it
+was posted.
+*/
+type ModCase struct {
+	GuildID      string
+	Number       int
+	Action       string
+	UserID       string
+	ModeratorID  string
+	Reason       string
+	Duration     time.Duration
+	Details      string
+	LogChannelID string
+	LogMessageID string
+	CreatedAt    time.Time
+}
+
+// how each kind of case is shown in the mod log and in ~history
+var caseActions = map[string]struct {
+	title string
+	label string
+}{
+	"warn":    {"⚠️Warned", "Warned"},
+	"timeout": {"🔇Timed Out", "Timed out"},
+	"kick":    {"👢Kicked", "Kicked"},
+	"ban":     {"🚫Banned", "Banned"},
+	"unban":   {"🤝Ban Revoked for", "Unbanned"},
+	"purge":   {"🧹Purged Messages", "Purged messages"},
+}
+
+/**
+Returns a link to the case's mod log entry, or an empty string if it wasn't posted.
+*/
+func (modCase *ModCase) link() string {
+	if modCase.LogMessageID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", modCase.GuildID, modCase.LogChannelID, modCase.LogMessageID)
+}
+
+/**
+Describes who took an action, with their nickname if they have one.
+*/
+func describeActor(s *discordgo.Session, guildID string, userID string) string {
+	member, err := s.GuildMember(guildID, userID)
+	if err != nil {
+		if user, err := s.User(userID); err == nil {
+			return user.String()
+		}
+		logWarning("Unable to find the actor of a case! " + err.Error())
+		return "<@" + userID + ">"
+	}
+	if member.Nick != "" {
+		return fmt.Sprintf("%s (%s)", member.Nick, member.User.String())
+	}
+	return member.User.String()
+}
+
+/**
+Builds the mod log entry for a case. It only depends on what is stored, so it
+can be rebuilt when the reason changes.
+*/
+func caseEmbed(s *discordgo.Session, modCase *ModCase) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Type:      "rich",
+		Title:     caseActions[modCase.Action].title,
+		Footer:    &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Case #%d", modCase.Number)},
+		Timestamp: modCase.CreatedAt.Format(time.RFC3339),
+	}
+	if modCase.UserID != "" {
+		user, err := s.User(modCase.UserID)
+		if err != nil {
+			logWarning("Unable to find the user of a case! " + err.Error())
+			embed.Title += " " + modCase.UserID
+		} else {
+			embed.Title += " " + user.String()
+			embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: user.AvatarURL("512")}
+		}
+	}
+
+	embed.Description = fmt.Sprintf("**Actor**: %s\n", describeActor(s, modCase.GuildID, modCase.ModeratorID))
+	if modCase.Duration > 0 {
+		embed.Description += fmt.Sprintf("**Duration**: %s (until %s)\n", describeDuration(modCase.Duration), discordTimestamp(modCase.CreatedAt.Add(modCase.Duration)))
+	}
+	if modCase.Details != "" {
+		embed.Description += modCase.Details + "\n"
+	}
+	if modCase.Reason != "" {
+		embed.Description += fmt.Sprintf("**Reason**: '%s'", modCase.Reason)
+	} else {
+		embed.Description += fmt.Sprintf("**Reason**: none given, set one with `%sreason %d <reason>`", getGuildPrefix(modCase.GuildID), modCase.Number)
+	}
+	return embed
+}
+
+/**
+Stores a moderation action as the guild's next case and posts it to the mod
+log. Returns the case number, or 0 if it couldn't be stored.
+*/
+func recordCase(s *discordgo.Session, modCase ModCase) int {
+	if modCase.CreatedAt.IsZero() {
+		modCase.CreatedAt = time.Now()
+	}
+	number, err := storage.AddCase(modCase)
+	if err != nil {
+		logError("Unable to save the case! " + err.Error())
+		return 0
+	}
+	modCase.Number = number
+
+	message, err := sendToModLog(s, modCase.GuildID, "moderation", caseEmbed(s, &modCase))
+	if err != nil {
+		logError(fmt.Sprintf("Failed to post case #%d to the mod log! %s", number, err.Error()))
+	} else if message != nil {
+		err = storage.SetCaseMessage(modCase.GuildID, number, message.ChannelID, message.ID)
+		if err != nil {
+			logError("Unable to link the case to its mod log entry! " + err.Error())
+		}
+	}
+	return number
+}
+
+/****
+COMMANDS
+****/
+
+/**
+Looks up a case, replying if it doesn't exist or can't be read.
+**/
+func findCase(s *discordgo.Session, m *Invocation, command string, number int) *ModCase {
+	modCase, err := storage.GetCase(m.GuildID, number)
+	if err != nil {
+		logError("Unable to read the case! " + err.Error())
+		sendError(s, m, command, Database)
+		return nil
+	}
+	if modCase == nil {
+		attemptSendMsg(s, m, fmt.Sprintf("There is no case #%d on this server.", number))
+	}
+	return modCase
+}
+
+/**
+Shows a single case, with a link to its mod log entry.
+**/
+func handleCase(s *discordgo.Session, m *Invocation, args *Args) {
+	modCase := findCase(s, m, "case", args.Ints["number"])
+	if modCase == nil {
+		return
+	}
+	embed := caseEmbed(s, modCase)
+	if link := modCase.link(); link != "" {
+		embed.Fields = append(embed.Fields, createField("Mod Log", "[Jump to the entry]("+link+")", false))
+	}
+	_, err := sendEmbed(s, m, embed)
+	if err != nil {
+		logError("Failed to send the case! " + err.Error())
+	}
+}
+
+/**
+Changes the reason of a case, and edits its mod log entry to match.
+**/
+func handleReason(s *discordgo.Session, m *Invocation, args *Args) {
+	modCase := findCase(s, m, "reason", args.Ints["number"])
+	if modCase == nil {
+		return
+	}
+	modCase.Reason = args.Values["reason"]
+	err := storage.SetCaseReason(m.GuildID, modCase.Number, modCase.Reason)
+	if err != nil {
+		logError("Unable to update the case! " + err.Error())
+		sendError(s, m, "reason", Database)
+		return
+	}
+
+	if modCase.LogMessageID != "" {
+		_, err = s.ChannelMessageEditEmbed(modCase.LogChannelID, modCase.LogMessageID, caseEmbed(s, modCase))
+		if err != nil {
+			logWarning("Unable to edit the mod log entry! " + err.Error())
+			sendSuccess(s, m, fmt.Sprintf("Updated the reason for case #%d, but I couldn't edit its mod log entry.", modCase.Number))
+			return
+		}
+	}
+	sendSuccess(s, m, fmt.Sprintf("Updated the reason for case #%d.", modCase.Number))
+}
+
+/**
+Lists every case against a user, 10 to a page.
+**/
+func handleHistory(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	cases, err := storage.GetCases(m.GuildID, userID)
+	if err != nil {
+		logError("Unable to read the cases! " + err.Error())
+		sendError(s, m, "history", Database)
+		return
+	}
+	if len(cases) == 0 {
+		attemptSendMsg(s, m, fmt.Sprintf("<@%s> has no moderation history.", userID))
+		return
+	}
+
+	title := fmt.Sprintf("%d Case(s)", len(cases))
+	if user, err := s.User(userID); err == nil {
+		title += " for " + user.String()
+	}
+	pageCount := (len(cases) + 9) / 10
+	var pages []*discordgo.MessageEmbed
+	for page := 0; page < pageCount; page++ {
+		embed := &discordgo.MessageEmbed{Type: "rich", Title: title}
+		for i := page * 10; i < page*10+10 && i < len(cases); i++ {
+			modCase := cases[i]
+			name := fmt.Sprintf("#%d • %s • %s", modCase.Number, caseActions[modCase.Action].label, modCase.CreatedAt.Format("Jan 2, 2006"))
+			if modCase.Duration > 0 {
+				name += " • " + describeDuration(modCase.Duration)
+			}
+			value := "No reason given"
+			if modCase.Reason != "" {
+				value = modCase.Reason
+			}
+			value += fmt.Sprintf("\nby <@%s>", modCase.ModeratorID)
+			if link := modCase.link(); link != "" {
+				value += " • [mod log](" + link + ")"
+			}
+			embed.Fields = append(embed.Fields, createField(name, value, false))
+		}
+		if pageCount > 1 {
+			embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d", page+1, pageCount)}
+		}
+		pages = append(pages, embed)
+	}
+
+	_, err = sendPaginated(s, m, pages)
+	if err != nil {
+		logError("Failed to send the history! " + err.Error())
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that moderation actions become numbered cases that can be looked up and amended.
+**/
+func TestCases(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	moderators := fake.AddRole("moderators", discordgo.PermissionModerateMembers|discordgo.PermissionKickMembers|discordgo.PermissionManageMessages)
+	mod := fake.AddUser("mod", moderators.ID)
+	target := fake.AddUser("target")
+	modLog := fake.AddChannel("mod-log")
+	storage.SetModLogChannel(fake.Guild.ID, modLog.ID)
+
+	t.Run("commands record numbered cases", func(t *testing.T) {
+		fake.Reply(mod, "~kick <@"+target.ID+">")
+		fake.SendMessage(mod, "~purge 5")
+		fake.WaitFor("the purge to be logged", func() bool { return len(fake.SentTo(modLog.ID)) == 2 })
+		logged := fake.SentTo(modLog.ID)
+		if len(logged) != 2 || logged[0].Embeds[0].Footer.Text != "Case #1" || logged[1].Embeds[0].Footer.Text != "Case #2" {
+			t.Fatalf("Expected two cases in the mod log, got %+v", logged)
+		}
+		if embed := logged[0].Embeds[0]; embed.Title != "👢Kicked target#1234" || !strings.Contains(embed.Description, "none given, set one with `~reason 1 <reason>`") {
+			t.Logf("Unexpected kick entry %+v", embed)
+			t.Fail()
+		}
+		if embed := logged[1].Embeds[0]; embed.Title != "🧹Purged Messages" || !strings.Contains(embed.Description, "**Messages**: ") {
+			t.Logf("Unexpected purge entry %+v", embed)
+			t.Fail()
+		}
+
+		modCase, err := storage.GetCase(fake.Guild.ID, 1)
+		if err != nil || modCase == nil || modCase.Action != "kick" || modCase.ModeratorID != mod.ID || modCase.LogMessageID != logged[0].ID {
+			t.Logf("Unexpected case %+v %v", modCase, err)
+			t.Fail()
+		}
+	})
+
+	t.Run("the audit log fills in actions taken outside the bot", func(t *testing.T) {
+		fake.AddMember(target)
+		ban := discordgo.AuditLogActionMemberBanAdd
+		logModActivity(fake.Session, fake.Guild.ID, &discordgo.AuditLogEntry{TargetID: target.ID, UserID: mod.ID, Reason: "raiding", ActionType: &ban})
+		logModActivity(fake.Session, fake.Guild.ID, &discordgo.AuditLogEntry{TargetID: target.ID, UserID: fake.Bot.ID, Reason: "raiding", ActionType: &ban})
+		cases, err := storage.GetCases(fake.Guild.ID, target.ID)
+		if err != nil || len(cases) != 2 || cases[1].Number != 3 || cases[1].Action != "ban" || cases[1].Reason != "raiding" {
+			t.Logf("Expected only the moderator's ban to be recorded, got %+v %v", cases, err)
+			t.Fail()
+		}
+	})
+
+	t.Run("audit log entries are only recorded once", func(t *testing.T) {
+		auditLogDelay = 0
+		defer func() { auditLogDelay = 2 * time.Second }()
+		leaver := fake.AddUser("leaver")
+		// an empty audit log, e.g. without permission to read it
+		fake.Inject(&discordgo.GuildBanRemove{User: leaver, GuildID: fake.Guild.ID})
+
+		kick := discordgo.AuditLogActionMemberKick
+		fake.AddAuditLogEntry(&discordgo.AuditLogEntry{TargetID: leaver.ID, UserID: mod.ID, ActionType: &kick})
+		logAuditedModActivity(fake.Session, fake.Guild.ID, leaver.ID, kick)
+		logAuditedModActivity(fake.Session, fake.Guild.ID, leaver.ID, kick)
+		cases, err := storage.GetCases(fake.Guild.ID, leaver.ID)
+		if err != nil || len(cases) != 1 || cases[0].Action != "kick" {
+			t.Logf("Expected the kick to be recorded once, got %+v %v", cases, err)
+			t.Fail()
+		}
+	})
+
+	t.Run("reasons can be changed afterwards", func(t *testing.T) {
+		if response := fake.Reply(mod, "~reason 1 spamming invites").Content; response != "Updated the reason for case #1." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		logged := fake.SentTo(modLog.ID)
+		entry := fake.Message(modLog.ID, logged[0].ID)
+		if len(entry.Embeds) != 1 || !strings.Contains(entry.Embeds[0].Description, "**Reason**: 'spamming invites'") {
+			t.Logf("The mod log entry wasn't edited: %+v", entry.Embeds)
+			t.Fail()
+		}
+		if response := fake.Reply(mod, "~reason 99 spamming").Content; response != "There is no case #99 on this server." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("cases and history can be looked up", func(t *testing.T) {
+		shown := fake.Reply(mod, "~case 1")
+		if len(shown.Embeds) != 1 || len(shown.Embeds[0].Fields) != 1 || !strings.Contains(shown.Embeds[0].Fields[0].Value, "https://discord.com/channels/"+fake.Guild.ID+"/"+modLog.ID+"/") {
+			t.Logf("Expected the case with a link, got %+v", shown.Embeds)
+			t.Fail()
+		}
+
+		history := fake.Reply(mod, "~history <@"+target.ID+">")
+		if len(history.Embeds) != 1 || len(history.Embeds[0].Fields) != 2 {
+			t.Fatalf("Expected two cases, got %+v", history.Embeds)
+		}
+		if field := history.Embeds[0].Fields[0]; !strings.HasPrefix(field.Name, "#1 • Kicked • ") || !strings.HasPrefix(field.Value, "spamming invites\nby <@"+mod.ID+">") {
+			t.Logf("Unexpected history entry %+v", field)
+			t.Fail()
+		}
+
+		other := fake.AddUser("other")
+		if response := fake.Reply(mod, "~history <@"+other.ID+">").Content; response != "<@"+other.ID+"> has no moderation history." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("each server numbers its own cases", func(t *testing.T) {
+		number, err := storage.AddCase(ModCase{GuildID: "another guild", Action: "warn", UserID: target.ID, ModeratorID: mod.ID, CreatedAt: time.Now()})
+		if err != nil || number != 1 {
+			t.Logf("Expected case #1, got %d %v", number, err)
+			t.Fail()
+		}
+	})
+
+	t.Run("inactivity kicks are recorded as the bot's", func(t *testing.T) {
+		idle := fake.AddUser("idle")
+		gone := fake.AddUser("gone")
+		fake.Session.State.MemberRemove(fake.Member(gone.ID))
+		longAgo := time.Now().AddDate(0, 0, -30)
+		storage.SetAutoKick(fake.Guild.ID, 7)
+		storage.AddMemberActivity(fake.Guild.ID, idle.ID, idle.String(), longAgo, "Wrote a message")
+		storage.AddMemberActivity(fake.Guild.ID, gone.ID, gone.String(), longAgo, "Wrote a message")
+		if err := autoKick(fake.Session); err != nil {
+			t.Fatalf("Auto-kick failed: %s", err.Error())
+		}
+
+		cases, err := storage.GetCases(fake.Guild.ID, idle.ID)
+		if err != nil || len(cases) != 1 || cases[0].Action != "kick" || cases[0].ModeratorID != fake.Bot.ID || cases[0].Reason != "Bot detected 7 or more days of inactivity." {
+			t.Logf("Expected the kick to be recorded, got %+v %v", cases, err)
+			t.Fail()
+		}
+		if cases, _ = storage.GetCases(fake.Guild.ID, gone.ID); len(cases) != 0 {
+			t.Logf("A failed kick shouldn't be recorded, got %+v", cases)
+			t.Fail()
+		}
+	})
+}
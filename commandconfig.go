@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// rules for this name apply to every command, as well as message link embeds
+const allCommands = "all"
+
+var commandConfigTable string
+
+// rules are checked on every message, so keep them in memory once loaded
+var commandRules = make(map[string][]CommandRule)
+var commandRulesLock sync.RWMutex
+
+/**
+Enables or disables a command in a guild. ChannelID is empty for rules that
+apply to the whole guild. Enabling a command in a channel restricts it to the
+channels it has been enabled in.
+*/
+type CommandRule struct {
+	GuildID   string `json:"guild_id"`
+	Command   string `json:"command"`
+	ChannelID string `json:"channel_id"`
+	Enabled   bool   `json:"enabled"`
+}
+
+/**
+Returns the command rules for the guild.
+*/
+func getCommandRules(guildID string) []CommandRule {
+	commandRulesLock.RLock()
+	rules, ok := commandRules[guildID]
+	commandRulesLock.RUnlock()
+	if ok {
+		return rules
+	}
+
+	rules, err := storage.GetCommandRules(guildID)
+	if err != nil {
+		// don't cache the failure so the next message tries again
+		logError("Unable to read command rules, allowing every command: " + err.Error())
+		return nil
+	}
+
+	commandRulesLock.Lock()
+	commandRules[guildID] = rules
+	commandRulesLock.Unlock()
+	return rules
+}
+
+/**
+Checks the rules for a single name. A command disabled for the guild or the
+channel is blocked, and a command enabled in some channels is blocked everywhere else.
+*/
+func rulesAllow(rules []CommandRule, name string, channelID string) bool {
+	restricted := false
+	allowedHere := false
+	for _, rule := range rules {
+		if rule.Command != name {
+			continue
+		}
+		switch {
+		case rule.ChannelID == "" && !rule.Enabled:
+			return false
+		case rule.ChannelID == channelID && !rule.Enabled:
+			return false
+		case rule.ChannelID == channelID:
+			allowedHere = true
+		case rule.ChannelID != "" && rule.Enabled:
+			restricted = true
+		}
+	}
+	return allowedHere || !restricted
+}
+
+/**
+Returns whether the command may be used in the channel. Rules set for "all"
+are checked as well as the command's own rules.
+*/
+func commandEnabled(guildID string, channelID string, name string) bool {
+	rules := getCommandRules(guildID)
+	if !rulesAllow(rules, allCommands, channelID) {
+		return false
+	}
+	return name == allCommands || rulesAllow(rules, name, channelID)
+}
+
+/**
+Forgets the cached rules of the guild so they are reloaded on the next message.
+*/
+func invalidateCommandRules(guildID string) {
+	commandRulesLock.Lock()
+	delete(commandRules, guildID)
+	commandRulesLock.Unlock()
+}
+
+/**
+Describes the rules for each command in the guild, for ~config status.
+*/
+func describeCommandRules(rules []CommandRule) []*discordgo.MessageEmbedField {
+	byCommand := make(map[string][]CommandRule)
+	var names []string
+	for _, rule := range rules {
+		if _, ok := byCommand[rule.Command]; !ok {
+			names = append(names, rule.Command)
+		}
+		byCommand[rule.Command] = append(byCommand[rule.Command], rule)
+	}
+	sort.Strings(names)
+
+	var contents []*discordgo.MessageEmbedField
+	for _, name := range names {
+		var lines []string
+		var enabledIn []string
+		var disabledIn []string
+		for _, rule := range byCommand[name] {
+			switch {
+			case rule.ChannelID == "":
+				lines = append(lines, "Disabled everywhere")
+			case rule.Enabled:
+				enabledIn = append(enabledIn, "<#"+rule.ChannelID+">")
+			default:
+				disabledIn = append(disabledIn, "<#"+rule.ChannelID+">")
+			}
+		}
+		if len(enabledIn) > 0 {
+			lines = append(lines, "Only in "+strings.Join(enabledIn, ", "))
+		}
+		if len(disabledIn) > 0 {
+			lines = append(lines, "Disabled in "+strings.Join(disabledIn, ", "))
+		}
+		contents = append(contents, createField(name, strings.Join(lines, "\n"), false))
+	}
+	return contents
+}
+
+/**
+Enables, disables or resets commands for the guild or for single channels.
+**/
+func handleConfig(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	switch args.Subcommand {
+	case "status":
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = "Command Configuration"
+		embed.Fields = describeCommandRules(getCommandRules(m.GuildID))
+		if len(embed.Fields) == 0 {
+			embed.Description = "Every command is enabled in every channel."
+		}
+		_, err := sendEmbed(s, m, &embed)
+		if err != nil {
+			logError("Failed to send command configuration embed! " + err.Error())
+		}
+	case "command":
+		name := resolveCommand(args.Values["name"])
+		if _, ok := commandList[name]; !ok && name != allCommands {
+			attemptSendMsg(s, m, fmt.Sprintf("There is no command called `%s`.", args.Values["name"]))
+			return
+		}
+		// don't let a server lock itself out of changing the configuration
+		if name == "config" {
+			attemptSendMsg(s, m, "The config command can't be disabled.")
+			return
+		}
+		channel := args.Values["channel"]
+
+		var err error
+		switch args.Values["state"] {
+		case "reset":
+			if channel == "" {
+				err = storage.RemoveCommandRules(m.GuildID, name)
+			} else {
+				err = storage.RemoveCommandRule(m.GuildID, name, channel)
+			}
+		case "enable":
+			if channel == "" {
+				// commands are enabled by default, so just lift the guild-wide disable
+				err = storage.RemoveCommandRule(m.GuildID, name, "")
+				break
+			}
+			fallthrough
+		default:
+			err = storage.SetCommandRule(CommandRule{GuildID: m.GuildID, Command: name, ChannelID: channel, Enabled: args.Values["state"] == "enable"})
+		}
+		invalidateCommandRules(m.GuildID)
+		if err != nil {
+			logError("Couldn't update command configuration! " + err.Error())
+			sendError(s, m, "config", Database)
+			return
+		}
+		logSuccess("Updated command configuration")
+		sendSuccess(s, m, "")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+/**
+Test how command rules combine to decide whether a command can be used
+in a channel.
+**/
+func TestCommandRules(t *testing.T) {
+	rules := []CommandRule{
+		{GuildID: "1", Command: "image", ChannelID: "general", Enabled: false},
+		{GuildID: "1", Command: "perk", ChannelID: "dbd", Enabled: true},
+		{GuildID: "1", Command: "urban", ChannelID: "", Enabled: false},
+		{GuildID: "1", Command: allCommands, ChannelID: "testing", Enabled: false},
+	}
+
+	t.Run("commands without rules are allowed everywhere", func(t *testing.T) {
+		if !rulesAllow(rules, "wiki", "general") || !rulesAllow(rules, "wiki", "dbd") {
+			t.Logf("wiki should not be restricted")
+			t.Fail()
+		}
+	})
+
+	t.Run("disabling in a channel only affects that channel", func(t *testing.T) {
+		if rulesAllow(rules, "image", "general") {
+			t.Logf("image should be disabled in general")
+			t.Fail()
+		}
+		if !rulesAllow(rules, "image", "dbd") {
+			t.Logf("image should still work in dbd")
+			t.Fail()
+		}
+	})
+
+	t.Run("enabling in a channel restricts the command to it", func(t *testing.T) {
+		if !rulesAllow(rules, "perk", "dbd") {
+			t.Logf("perk should work in dbd")
+			t.Fail()
+		}
+		if rulesAllow(rules, "perk", "general") {
+			t.Logf("perk should only work in dbd")
+			t.Fail()
+		}
+	})
+
+	t.Run("disabling for the guild affects every channel", func(t *testing.T) {
+		if rulesAllow(rules, "urban", "general") || rulesAllow(rules, "urban", "dbd") {
+			t.Logf("urban should be disabled everywhere")
+			t.Fail()
+		}
+	})
+
+	t.Run("rules for all apply to every command", func(t *testing.T) {
+		if rulesAllow(rules, allCommands, "testing") {
+			t.Logf("The bot should be disabled in testing")
+			t.Fail()
+		}
+		if !rulesAllow(rules, allCommands, "general") {
+			t.Logf("The bot should be enabled in general")
+			t.Fail()
+		}
+	})
+
+	t.Run("status lists the rules per command", func(t *testing.T) {
+		fields := describeCommandRules(rules)
+		if len(fields) != 4 || fields[0].Name != allCommands || fields[2].Value != "Only in <#dbd>" {
+			t.Logf("Unexpected status fields: %+v %+v", fields[0], fields[2])
+			t.Fail()
+		}
+	})
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"fmt"
-	"net/http"
-	"runtime"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -21,48 +19,13 @@ const (
 	Internal    ErrorType = 5
 )
 
-/**
-Prints an info log to the console if debug mode is on.
-*/
-func logInfo(message string) {
-	if debug {
-		pc, _, _, _ := runtime.Caller(1)
-		funcCalledIn := runtime.FuncForPC(pc).Name()
-		fmt.Printf("[INFO] %s: %s\n", funcCalledIn, message)
-	}
-}
+var errorTypeNames = []string{"syntax", "database", "discord", "read_parse", "permissions", "internal"}
 
-/**
-Prints an info log to the console if debug mode is on.
-*/
-func logWarning(message string) {
-	if debug {
-		pc, _, _, _ := runtime.Caller(1)
-		funcCalledIn := runtime.FuncForPC(pc).Name()
-		fmt.Printf("[\033[33mWARN\033[0m] %s: %s\n", funcCalledIn, message)
-	}
-}
-
-/**
-Prints an info log to the console if debug mode is on.
-*/
-func logError(message string) {
-	if debug {
-		pc, _, _, _ := runtime.Caller(1)
-		funcCalledIn := runtime.FuncForPC(pc).Name()
-		fmt.Printf("[\033[31mERR!\033[0m] %s: %s\n", funcCalledIn, message)
-	}
-}
-
-/**
-Prints a success log to the console if debug mode is on.
-*/
-func logSuccess(message string) {
-	if debug {
-		pc, _, _, _ := runtime.Caller(1)
-		funcCalledIn := runtime.FuncForPC(pc).Name()
-		fmt.Printf("[\033[32m OK \033[0m] %s: %s\n", funcCalledIn, message)
+func (errorType ErrorType) String() string {
+	if errorType < 0 || int(errorType) >= len(errorTypeNames) {
+		return "unknown"
 	}
+	return errorTypeNames[errorType]
 }
 
 /**
@@ -71,14 +34,16 @@ it in the form of a goquery Document, which can be
 searched more easily.
 */
 func loadPage(url string) *goquery.Document {
-	res, err := http.Get(url)
+	res, err := httpClient.Get(url)
 	if err != nil {
 		logError("Error on GET request." + err.Error())
+		scrapeFailures.WithLabelValues("request").Inc()
 		return nil
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		logError("Page did not return 200 status OK")
+		scrapeFailures.WithLabelValues("status").Inc()
 		return nil
 	}
 
@@ -86,6 +51,7 @@ func loadPage(url string) *goquery.Document {
 	doc, err := goquery.NewDocumentFromReader(res.Body)
 	if err != nil {
 		logError("Error converting response into goquery document. " + err.Error())
+		scrapeFailures.WithLabelValues("parse").Inc()
 		return nil
 	}
 
@@ -121,8 +87,8 @@ func stripUserID(raw string) string {
 /**
 Sends a message and automatically handles the error gracefully.
 */
-func attemptSendMsg(s *discordgo.Session, m *discordgo.MessageCreate, message string) {
-	_, err := s.ChannelMessageSend(m.ChannelID, message)
+func attemptSendMsg(s *discordgo.Session, m *Invocation, message string) {
+	_, err := respond(s, m, &discordgo.MessageSend{Content: message})
 	if err != nil {
 		logError("Failed to send message with contents: " + message + "\n" + err.Error())
 		return
@@ -132,18 +98,20 @@ func attemptSendMsg(s *discordgo.Session, m *discordgo.MessageCreate, message st
 /**
 Send a message indicating the error type.
 */
-func sendError(s *discordgo.Session, m *discordgo.MessageCreate, command string, errorType ErrorType) {
+func sendError(s *discordgo.Session, m *Invocation, command string, errorType ErrorType) {
+	m.setOutcome(errorType.String())
+	fields := invocationFields(m, command)
+	fields["error"] = errorType.String()
+	logWith(LevelWarn, fields, "Command failed")
+
 	// add x reaction to message
-	err := s.MessageReactionAdd(m.ChannelID, m.ID, "❌")
-	if err != nil {
-		logError("Failed to react to erroring command; " + err.Error())
-	}
+	reactToInvocation(s, m, "❌")
 
 	// generic error message
 	var messageContent string
 	switch errorType {
 	case Syntax:
-		messageContent = fmt.Sprintf("Usage: `%s`", commandList[command].usage)
+		messageContent = fmt.Sprintf("Usage: `%s`", usageFor(m.GuildID, command))
 	case Database:
 		messageContent = ":bangbang: A database error occurred."
 	case Discord:
@@ -163,11 +131,13 @@ func sendError(s *discordgo.Session, m *discordgo.MessageCreate, command string,
 /**
 Send a message indicating a simple operation success, or just add a reaction.
 */
-func sendSuccess(s *discordgo.Session, m *discordgo.MessageCreate, message string) {
+func sendSuccess(s *discordgo.Session, m *Invocation, message string) {
 	// add check reaction to message
-	err := s.MessageReactionAdd(m.ChannelID, m.ID, "✔️")
-	if err != nil {
-		logError("Failed to react to erroring command; " + err.Error())
+	reactToInvocation(s, m, "✔️")
+
+	// slash commands need some response in place of the reaction
+	if message == "" && m.Interaction != nil {
+		message = "✔️"
 	}
 
 	if message != "" {
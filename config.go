@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**
+Every setting of the bot that isn't a secret. It is read from the YAML file at
+CONFIG_FILE (config.yaml by default), and each setting with an env tag can be
+overridden by that environment variable. config.example.yaml documents every
+setting. Tokens, API keys and the database password are only read from the
+environment (see api-keys.env).
+*/
+type Config struct {
+	ProdMode   bool          `yaml:"prod_mode" env:"PROD_MODE"`
+	StatusAddr string        `yaml:"status_addr" env:"STATUS_ADDR"`
+	Logging    LoggingConfig `yaml:"logging"`
+	Storage    StorageConfig `yaml:"storage"`
+	Tables     TableConfig   `yaml:"tables"`
+	URLs       URLConfig     `yaml:"urls"`
+	Search     SearchConfig  `yaml:"search"`
+	Jobs       JobConfig     `yaml:"jobs"`
+	Cache      CacheConfig   `yaml:"message_cache"`
+	Statuses   []string      `yaml:"statuses"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+type StorageConfig struct {
+	Driver     string `yaml:"driver" env:"STORAGE_DRIVER"`
+	Host       string `yaml:"host" env:"DB_HOST"`
+	Database   string `yaml:"database" env:"DB"`
+	Username   string `yaml:"username" env:"DB_USERNAME"`
+	SQLitePath string `yaml:"sqlite_path" env:"SQLITE_PATH"`
+}
+
+type TableConfig struct {
+	Activity           string `yaml:"activity" env:"ACTIVITY_TABLE"`
+	Leaderboard        string `yaml:"leaderboard" env:"LEADERBOARD_TABLE"`
+	JoinLeave          string `yaml:"join_leave" env:"JOIN_LEAVE_TABLE"`
+	Autokick           string `yaml:"autokick" env:"AUTOKICK_TABLE"`
+	ModLog             string `yaml:"modlog" env:"MODLOG_TABLE"`
+	Autoshrine         string `yaml:"autoshrine" env:"AUTOSHRINE_TABLE"`
+	GuildSettings      string `yaml:"guild_settings" env:"GUILD_SETTINGS_TABLE"`
+	CommandConfig      string `yaml:"command_config" env:"COMMAND_CONFIG_TABLE"`
+	PermissionOverride string `yaml:"permission_overrides" env:"PERMISSION_OVERRIDE_TABLE"`
+	Paginator          string `yaml:"paginators" env:"PAGINATOR_TABLE"`
+	JobStatus          string `yaml:"job_status" env:"JOB_STATUS_TABLE"`
+	Warnings           string `yaml:"warnings" env:"WARNINGS_TABLE"`
+	Escalation         string `yaml:"escalation" env:"ESCALATION_TABLE"`
+	TempBans           string `yaml:"tempbans" env:"TEMPBANS_TABLE"`
+	Cases              string `yaml:"cases" env:"CASES_TABLE"`
+	ModLogEvents       string `yaml:"modlog_events" env:"MODLOG_EVENTS_TABLE"`
+	MessageCache       string `yaml:"message_cache" env:"MESSAGE_CACHE_TABLE"`
+	SchemaVersion      string `yaml:"schema_version" env:"SCHEMA_VERSION_TABLE"`
+}
+
+type URLConfig struct {
+	DBDWiki         string `yaml:"dbd_wiki" env:"DBD_WIKI_URL"`
+	Shrine          string `yaml:"shrine" env:"SHRINE_URL"`
+	Google          string `yaml:"google" env:"GOOGLE_URL"`
+	CustomSearch    string `yaml:"custom_search" env:"CUSTOM_SEARCH_URL"`
+	UrbanDictionary string `yaml:"urban_dictionary" env:"URBAN_DICTIONARY_URL"`
+	Lingua          string `yaml:"lingua" env:"LINGUA_URL"`
+	Wikipedia       string `yaml:"wikipedia" env:"WIKIPEDIA_URL"`
+}
+
+type SearchConfig struct {
+	CustomSearchCX string `yaml:"custom_search_cx" env:"CUSTOM_SEARCH_CX"`
+}
+
+type JobConfig struct {
+	StatusInterval time.Duration `yaml:"status_interval" env:"STATUS_INTERVAL"`
+	Autokick       string        `yaml:"autokick" env:"AUTOKICK_SCHEDULE"`
+	ShrineInterval time.Duration `yaml:"shrine_interval" env:"SHRINE_INTERVAL"`
+}
+
+type CacheConfig struct {
+	Size            int           `yaml:"size" env:"MESSAGE_CACHE_SIZE"`
+	TTL             time.Duration `yaml:"ttl" env:"MESSAGE_CACHE_TTL"`
+	Persist         bool          `yaml:"persist" env:"MESSAGE_CACHE_PERSIST"`
+	ExcludeChannels []string      `yaml:"exclude_channels"`
+	ExcludeUsers    []string      `yaml:"exclude_users"`
+}
+
+/**
+Returns the settings the bot uses when nothing is configured.
+*/
+func defaultConfig() *Config {
+	return &Config{
+		StatusAddr: ":8080",
+		Logging:    LoggingConfig{Level: "info", Format: "logfmt"},
+		Storage:    StorageConfig{Driver: "mysql", SQLitePath: "aio-bot.db"},
+		Tables: TableConfig{
+			Activity:           "activity",
+			Leaderboard:        "leaderboard",
+			JoinLeave:          "join_leave_messages",
+			Autokick:           "autokick",
+			ModLog:             "modlogs",
+			Autoshrine:         "autoshrine",
+			GuildSettings:      "guild_settings",
+			CommandConfig:      "command_config",
+			PermissionOverride: "permission_overrides",
+			Paginator:          "paginators",
+			JobStatus:          "job_status",
+			Warnings:           "warnings",
+			Escalation:         "escalation_steps",
+			TempBans:           "tempbans",
+			Cases:              "mod_cases",
+			ModLogEvents:       "modlog_disabled_events",
+			MessageCache:       "message_cache",
+			SchemaVersion:      "schema_version",
+		},
+		URLs: URLConfig{
+			DBDWiki:         "https://deadbydaylight.gamepedia.com",
+			Shrine:          "https://raw.githubusercontent.com/cazwacki/periodic-dbd-data/master/shrine.json",
+			Google:          "https://www.google.com",
+			CustomSearch:    "https://customsearch.googleapis.com",
+			UrbanDictionary: "https://mashape-community-urban-dictionary.p.rapidapi.com",
+			Lingua:          "https://lingua-robot.p.rapidapi.com",
+			Wikipedia:       "https://en.wikipedia.org",
+		},
+		Search: SearchConfig{CustomSearchCX: "007244931007990492385:f42b7zsrt0k"},
+		Jobs: JobConfig{
+			StatusInterval: 2 * time.Hour,
+			Autokick:       "0 */6 * * *",
+			ShrineInterval: 15 * time.Minute,
+		},
+		Cache: CacheConfig{
+			Size:            100,
+			TTL:             24 * time.Hour,
+			ExcludeChannels: []string{},
+			ExcludeUsers:    []string{},
+		},
+		Statuses: []string{
+			"VSCode",
+			"with print statements instead of debugging properly",
+			"video games instead of doing my classwork",
+			"with scissors",
+			"Hentai Killer VR",
+			"with people who unironically use Ripcord",
+			"with unsafe syscalls",
+			"PuTTY",
+			"Human Simulator 2",
+		},
+	}
+}
+
+var (
+	// the settings in use; replaced as a whole on reload, never modified
+	config     = defaultConfig()
+	configLock sync.RWMutex
+	// when the config file was last changed, to notice edits
+	configModTime time.Time
+)
+
+/**
+Returns the settings in use. The result must not be modified.
+*/
+func currentConfig() *Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return config
+}
+
+/**
+Returns the path of the config file and whether it was chosen with CONFIG_FILE.
+*/
+func configPath() (string, bool) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path, true
+	}
+	return "config.yaml", false
+}
+
+/****
+LOADING AND VALIDATION
+****/
+
+/**
+Reads the config file at path on top of the defaults, applies the environment
+overrides and validates the result. A missing file is only an error if
+required is set; otherwise the defaults and environment are used. All of the
+problems found are reported together.
+*/
+func loadConfig(path string, required bool) (*Config, error) {
+	cfg := defaultConfig()
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && (required || !os.IsNotExist(err)) {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if err == nil {
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		// an empty file is fine and just keeps the defaults
+		if err = decoder.Decode(cfg); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("unable to parse %s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+	}
+
+	problems := applyEnvOverrides(reflect.ValueOf(cfg).Elem())
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration in %s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+/**
+Sets every field that has an env tag to its environment variable, if that is
+set. Returns a problem for each variable that can't be used.
+*/
+func applyEnvOverrides(section reflect.Value) []string {
+	var problems []string
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Field(i)
+		if field.Kind() == reflect.Struct {
+			problems = append(problems, applyEnvOverrides(field)...)
+			continue
+		}
+		name := section.Type().Field(i).Tag.Get("env")
+		value := os.Getenv(name)
+		if name == "" || value == "" {
+			continue
+		}
+
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case bool:
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, name+": must be true or false, got '"+value+"'")
+				continue
+			}
+			field.SetBool(enabled)
+		case int:
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, name+": must be a whole number, got '"+value+"'")
+				continue
+			}
+			field.SetInt(int64(number))
+		case time.Duration:
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, name+": must be a duration like 15m or 2h, got '"+value+"'")
+				continue
+			}
+			field.SetInt(int64(d))
+		}
+	}
+	return problems
+}
+
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/**
+Checks the settings, returning a description of each problem.
+*/
+func (cfg *Config) validate() []string {
+	var problems []string
+	problem := func(setting string, format string, a ...interface{}) {
+		problems = append(problems, setting+": "+fmt.Sprintf(format, a...))
+	}
+
+	if cfg.StatusAddr != "off" {
+		if _, _, err := net.SplitHostPort(cfg.StatusAddr); err != nil {
+			problem("status_addr", "must be an address like :8080, or off, got '%s'", cfg.StatusAddr)
+		}
+	}
+	if _, ok := parseLogLevel(cfg.Logging.Level); !ok {
+		problem("logging.level", "must be debug, info, warn or error, got '%s'", cfg.Logging.Level)
+	}
+	if format := strings.ToLower(cfg.Logging.Format); format != "logfmt" && format != "json" {
+		problem("logging.format", "must be logfmt or json, got '%s'", cfg.Logging.Format)
+	}
+
+	switch strings.ToLower(cfg.Storage.Driver) {
+	case "mysql":
+		if cfg.Storage.Host == "" || cfg.Storage.Database == "" || cfg.Storage.Username == "" {
+			problem("storage", "host, database and username are needed for mysql")
+		}
+	case "sqlite":
+		if cfg.Storage.SQLitePath == "" {
+			problem("storage.sqlite_path", "is needed for sqlite")
+		}
+	default:
+		problem("storage.driver", "must be mysql or sqlite, got '%s'", cfg.Storage.Driver)
+	}
+
+	seen := make(map[string]string)
+	tables := reflect.ValueOf(cfg.Tables)
+	for i := 0; i < tables.NumField(); i++ {
+		setting := "tables." + tables.Type().Field(i).Tag.Get("yaml")
+		name := tables.Field(i).String()
+		if !tableNameRegex.MatchString(name) {
+			problem(setting, "must be a name made of letters, digits and underscores, got '%s'", name)
+		} else if other, ok := seen[strings.ToLower(name)]; ok {
+			problem(setting, "is the same table as %s", other)
+		}
+		seen[strings.ToLower(name)] = setting
+	}
+
+	urls := reflect.ValueOf(cfg.URLs)
+	for i := 0; i < urls.NumField(); i++ {
+		setting := "urls." + urls.Type().Field(i).Tag.Get("yaml")
+		parsed, err := url.Parse(urls.Field(i).String())
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problem(setting, "must be an http or https URL, got '%s'", urls.Field(i).String())
+		}
+	}
+
+	if cfg.Search.CustomSearchCX == "" {
+		problem("search.custom_search_cx", "is needed for ~image")
+	}
+
+	if cfg.Jobs.StatusInterval < time.Minute {
+		problem("jobs.status_interval", "must be at least 1m, got %s", cfg.Jobs.StatusInterval)
+	}
+	if cron, err := parseCron(cfg.Jobs.Autokick); err != nil {
+		problem("jobs.autokick", "%s", err.Error())
+	} else if cron.next(time.Now()).IsZero() {
+		problem("jobs.autokick", "'%s' never matches a date", cfg.Jobs.Autokick)
+	}
+	if cfg.Jobs.ShrineInterval < time.Minute {
+		problem("jobs.shrine_interval", "must be at least 1m, got %s", cfg.Jobs.ShrineInterval)
+	}
+
+	if cfg.Cache.Size < 0 || cfg.Cache.Size > 1000 {
+		problem("message_cache.size", "must be between 0 and 1000, got %d", cfg.Cache.Size)
+	}
+	if cfg.Cache.TTL < time.Minute {
+		problem("message_cache.ttl", "must be at least 1m, got %s", cfg.Cache.TTL)
+	}
+	for i, id := range cfg.Cache.ExcludeChannels {
+		if !snowflakeRegex.MatchString(id) {
+			problem(fmt.Sprintf("message_cache.exclude_channels[%d]", i), "must be a channel ID, got '%s'", id)
+		}
+	}
+	for i, id := range cfg.Cache.ExcludeUsers {
+		if !snowflakeRegex.MatchString(id) {
+			problem(fmt.Sprintf("message_cache.exclude_users[%d]", i), "must be a user ID, got '%s'", id)
+		}
+	}
+
+	if len(cfg.Statuses) == 0 {
+		problem("statuses", "needs at least one status")
+	}
+	for i, status := range cfg.Statuses {
+		if status == "" || len([]rune(status)) > 128 {
+			problem(fmt.Sprintf("statuses[%d]", i), "must be between 1 and 128 characters")
+		}
+	}
+	return problems
+}
+
+/****
+RELOADING
+****/
+
+/**
+Loads the config file for the first time. The bot shouldn't start if this fails.
+*/
+func initConfig() error {
+	path, required := configPath()
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	config = cfg
+	if info, err := os.Stat(path); err == nil {
+		configModTime = info.ModTime()
+		logInfo("Loaded the configuration from " + path)
+	}
+	configLock.Unlock()
+	return nil
+}
+
+/**
+Loads the config file again and applies the settings that can change while the
+bot runs: the log level, game statuses, job schedules, search settings and how
+messages are cached.
+Settings that are only read at startup keep their current values, with a
+warning to restart. If the file is invalid, the settings in use are kept.
+*/
+func reloadConfigFile() error {
+	path, required := configPath()
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return err
+	}
+
+	configLock.Lock()
+	previous := config
+	restartNeeded := cfg.StatusAddr != previous.StatusAddr || cfg.Logging.Format != previous.Logging.Format ||
+		cfg.Storage != previous.Storage || cfg.Tables != previous.Tables || cfg.URLs != previous.URLs || cfg.Cache.Persist != previous.Cache.Persist
+	// the settings only read at startup keep the values in use until the bot restarts
+	cfg.StatusAddr = previous.StatusAddr
+	cfg.Logging.Format = previous.Logging.Format
+	cfg.Storage = previous.Storage
+	cfg.Tables = previous.Tables
+	cfg.URLs = previous.URLs
+	cfg.Cache.Persist = previous.Cache.Persist
+	config = cfg
+	if info, err := os.Stat(path); err == nil {
+		configModTime = info.ModTime()
+	}
+	configLock.Unlock()
+
+	if restartNeeded {
+		logWarning("Storage, table, URL, log format, status address or message cache persistence settings changed; restart the bot to apply them")
+	}
+	if cfg.Logging.Level != previous.Logging.Level {
+		// only when it changed, so a level set with ~loglevel isn't undone
+		level, _ := parseLogLevel(cfg.Logging.Level)
+		setLogLevel(level)
+	}
+	if cfg.Jobs != previous.Jobs {
+		rescheduleJob("status", every(cfg.Jobs.StatusInterval))
+		rescheduleJob("autokick", mustParseCron(cfg.Jobs.Autokick))
+		rescheduleJob("shrine", every(cfg.Jobs.ShrineInterval))
+	}
+	logSuccess("Reloaded the configuration from " + path)
+	return nil
+}
+
+/**
+Reloads the config file if it changed since it was last read. This runs as the
+config job, so edits to the file apply without a restart.
+*/
+func reloadChangedConfig() error {
+	path, _ := configPath()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	configLock.RLock()
+	changed := !info.ModTime().Equal(configModTime)
+	configLock.RUnlock()
+	if !changed {
+		return nil
+	}
+	err = reloadConfigFile()
+	if err != nil {
+		// don't try the same broken file again until it changes
+		configLock.Lock()
+		configModTime = info.ModTime()
+		configLock.Unlock()
+		return errors.New("kept the previous configuration; " + err.Error())
+	}
+	return nil
+}
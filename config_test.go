@@ -0,0 +1,197 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+Sets an environment variable until the test ends.
+**/
+func setTestEnv(t *testing.T, name string, value string) {
+	previous, wasSet := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if wasSet {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+/**
+Writes a config file in a new directory and returns its path.
+**/
+func writeTestConfig(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("Unable to create a directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write the config: %s", err.Error())
+	}
+	return path
+}
+
+/**
+Test that the config file and environment are combined and validated.
+**/
+func TestConfig(t *testing.T) {
+	t.Run("the example documents the defaults", func(t *testing.T) {
+		setTestEnv(t, "STORAGE_DRIVER", "sqlite")
+		cfg, err := loadConfig("config.example.yaml", true)
+		if err != nil {
+			t.Fatalf("Unable to load the example: %s", err.Error())
+		}
+		expected := defaultConfig()
+		expected.Storage.Driver = "sqlite"
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Logf("The example differs from the defaults:\n%+v\n%+v", cfg, expected)
+			t.Fail()
+		}
+	})
+
+	t.Run("the environment overrides the file", func(t *testing.T) {
+		path := writeTestConfig(t, `
+storage:
+  driver: sqlite
+  sqlite_path: bot.db
+tables:
+  activity: member_activity
+jobs:
+  shrine_interval: 5m
+statuses: [with fire]
+`)
+		setTestEnv(t, "SHRINE_INTERVAL", "20m")
+		setTestEnv(t, "PROD_MODE", "true")
+		setTestEnv(t, "MESSAGE_CACHE_SIZE", "50")
+		cfg, err := loadConfig(path, true)
+		if err != nil {
+			t.Fatalf("Unable to load the config: %s", err.Error())
+		}
+		if cfg.Jobs.ShrineInterval != 20*time.Minute || !cfg.ProdMode || cfg.Cache.Size != 50 {
+			t.Logf("The environment should win, got %s, %v and %d", cfg.Jobs.ShrineInterval, cfg.ProdMode, cfg.Cache.Size)
+			t.Fail()
+		}
+		if cfg.Tables.Activity != "member_activity" || cfg.Storage.SQLitePath != "bot.db" || len(cfg.Statuses) != 1 {
+			t.Logf("The file's settings were not used: %+v", cfg)
+			t.Fail()
+		}
+		if cfg.Tables.Leaderboard != "leaderboard" || cfg.Jobs.StatusInterval != 2*time.Hour {
+			t.Logf("Settings missing from the file should keep their defaults: %+v", cfg)
+			t.Fail()
+		}
+	})
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		path := writeTestConfig(t, `
+storage:
+  driver: postgres
+tables:
+  activity: "activity; DROP TABLE leaderboard"
+  leaderboard: ACTIVITY
+urls:
+  wikipedia: en.wikipedia.org
+jobs:
+  autokick: "0 25 * * *"
+  shrine_interval: 10s
+message_cache:
+  ttl: 10s
+  exclude_users: [somebody]
+statuses: []
+`)
+		setTestEnv(t, "PROD_MODE", "maybe")
+		_, err := loadConfig(path, true)
+		if err == nil {
+			t.Fatalf("The config should have been rejected")
+		}
+		for _, expected := range []string{"PROD_MODE", "storage.driver", "tables.activity", "urls.wikipedia", "jobs.autokick", "jobs.shrine_interval", "message_cache.ttl", "message_cache.exclude_users[0]", "statuses"} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Logf("Expected a problem with %s in:\n%s", expected, err.Error())
+				t.Fail()
+			}
+		}
+
+		path = writeTestConfig(t, "storage:\n  driver: sqlite\njobs:\n  autokick: \"0 0 31 2 *\"\n")
+		if _, err = loadConfig(path, true); err == nil || !strings.Contains(err.Error(), "jobs.autokick: '0 0 31 2 *' never matches a date") {
+			t.Logf("Expected the schedule that never runs to be rejected, got %v", err)
+			t.Fail()
+		}
+
+		path = writeTestConfig(t, "storage:\n  drivr: sqlite\n")
+		if _, err = loadConfig(path, true); err == nil || !strings.Contains(err.Error(), "line 2: field drivr not found") {
+			t.Logf("Expected the misspelled setting to be pointed out, got %v", err)
+			t.Fail()
+		}
+	})
+
+	t.Run("the file is optional unless it was asked for", func(t *testing.T) {
+		setTestEnv(t, "STORAGE_DRIVER", "sqlite")
+		if _, err := loadConfig(filepath.Join("testdata", "missing.yaml"), false); err != nil {
+			t.Logf("The defaults should be used, got %s", err.Error())
+			t.Fail()
+		}
+		if _, err := loadConfig(filepath.Join("testdata", "missing.yaml"), true); err == nil {
+			t.Logf("A missing CONFIG_FILE should be an error")
+			t.Fail()
+		}
+	})
+
+	t.Run("changes apply while the bot runs", func(t *testing.T) {
+		useTestStorage(t)
+		previous := currentConfig()
+		previousLevel := getLogLevel()
+		defer func() {
+			configLock.Lock()
+			config = previous
+			configLock.Unlock()
+			setLogLevel(previousLevel)
+		}()
+
+		path := writeTestConfig(t, "storage:\n  driver: sqlite\n")
+		setTestEnv(t, "CONFIG_FILE", path)
+		if err := initConfig(); err != nil {
+			t.Fatalf("Unable to load the config: %s", err.Error())
+		}
+		scheduleJob(&job{name: "shrine", schedule: every(currentConfig().Jobs.ShrineInterval), run: func() error { return nil }})
+		defer unscheduleJob("shrine")
+
+		rewrite := func(contents string, modified time.Time) {
+			ioutil.WriteFile(path, []byte(contents), 0644)
+			os.Chtimes(path, modified, modified)
+		}
+		rewrite("storage:\n  driver: sqlite\nlogging:\n  level: debug\njobs:\n  shrine_interval: 1h\nurls:\n  wikipedia: https://de.wikipedia.org\nstatuses: [with fire]\n", time.Now().Add(time.Minute))
+		if err := reloadChangedConfig(); err != nil {
+			t.Fatalf("Unable to reload the config: %s", err.Error())
+		}
+		jobsLock.Lock()
+		shrineSchedule := jobs["shrine"].schedule.String()
+		jobsLock.Unlock()
+		if shrineSchedule != every(time.Hour).String() || getLogLevel() != LevelDebug || currentConfig().Statuses[0] != "with fire" {
+			t.Logf("The new settings weren't applied: %s, %s, %v", shrineSchedule, getLogLevel(), currentConfig().Statuses)
+			t.Fail()
+		}
+		if currentConfig().URLs.Wikipedia != "https://en.wikipedia.org" {
+			t.Logf("URLs should only change after a restart, got %s", currentConfig().URLs.Wikipedia)
+			t.Fail()
+		}
+
+		rewrite("storage:\n  driver: sqlite\nstatuses: []\n", time.Now().Add(2*time.Minute))
+		if err := reloadChangedConfig(); err == nil || !strings.Contains(err.Error(), "kept the previous configuration") {
+			t.Logf("Expected the invalid config to be rejected, got %v", err)
+			t.Fail()
+		}
+		if currentConfig().Statuses[0] != "with fire" {
+			t.Logf("The previous settings should have been kept")
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CooldownScope int32
+
+const (
+	PerUser    CooldownScope = 0
+	PerChannel CooldownScope = 1
+	PerGuild   CooldownScope = 2
+	PerBot     CooldownScope = 3 // shared by every guild, for APIs with a global quota
+)
+
+/**
+Allows a command to be used a number of times per window within the scope, e.g.
+twice per minute per user.
+*/
+type cooldown struct {
+	scope CooldownScope
+	uses  int
+	per   time.Duration
+}
+
+type cooldownBucket struct {
+	reset time.Time
+	used  int
+}
+
+var cooldownBuckets = make(map[string]*cooldownBucket)
+var cooldownBucketsLock sync.Mutex
+
+/**
+Helpers for declaring cooldowns in commandList.
+*/
+func perUser(uses int, per time.Duration) cooldown {
+	return cooldown{scope: PerUser, uses: uses, per: per}
+}
+
+func perChannel(uses int, per time.Duration) cooldown {
+	return cooldown{scope: PerChannel, uses: uses, per: per}
+}
+
+func perGuild(uses int, per time.Duration) cooldown {
+	return cooldown{scope: PerGuild, uses: uses, per: per}
+}
+
+func perBot(uses int, per time.Duration) cooldown {
+	return cooldown{scope: PerBot, uses: uses, per: per}
+}
+
+/**
+Returns the key of the bucket the invocation draws from for this cooldown.
+*/
+func cooldownKey(name string, c cooldown, m *Invocation) string {
+	switch c.scope {
+	case PerUser:
+		return fmt.Sprintf("%s:user:%s:%s", name, m.GuildID, m.Author.ID)
+	case PerChannel:
+		return fmt.Sprintf("%s:channel:%s", name, m.ChannelID)
+	case PerGuild:
+		return fmt.Sprintf("%s:guild:%s", name, m.GuildID)
+	default:
+		return fmt.Sprintf("%s:bot", name)
+	}
+}
+
+/**
+Takes a use out of every bucket the invocation draws from. If any of them is
+exhausted, nothing is taken and the time until that bucket refills is returned.
+*/
+func takeCooldowns(name string, cooldowns []cooldown, m *Invocation, now time.Time) (time.Duration, bool) {
+	if len(cooldowns) == 0 {
+		return 0, true
+	}
+
+	cooldownBucketsLock.Lock()
+	defer cooldownBucketsLock.Unlock()
+
+	var buckets []*cooldownBucket
+	var wait time.Duration
+	for _, c := range cooldowns {
+		key := cooldownKey(name, c, m)
+		bucket, ok := cooldownBuckets[key]
+		if !ok || !now.Before(bucket.reset) {
+			bucket = &cooldownBucket{reset: now.Add(c.per)}
+			cooldownBuckets[key] = bucket
+		}
+		if bucket.used >= c.uses && bucket.reset.Sub(now) > wait {
+			wait = bucket.reset.Sub(now)
+		}
+		buckets = append(buckets, bucket)
+	}
+	if wait > 0 {
+		return wait, false
+	}
+
+	for _, bucket := range buckets {
+		bucket.used++
+	}
+	return 0, true
+}
+
+/**
+Drops buckets whose window has passed so the map doesn't grow forever.
+*/
+func pruneCooldowns(now time.Time) {
+	cooldownBucketsLock.Lock()
+	defer cooldownBucketsLock.Unlock()
+	for key, bucket := range cooldownBuckets {
+		if !now.Before(bucket.reset) {
+			delete(cooldownBuckets, key)
+		}
+	}
+}
+
+/**
+Formats how long the user has to wait, rounded up to the next second.
+*/
+func formatRetry(wait time.Duration) string {
+	rounded := wait.Truncate(time.Second)
+	if rounded < wait {
+		rounded += time.Second
+	}
+	return rounded.String()
+}
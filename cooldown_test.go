@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that cooldown buckets fill up, refill once their window passes and are
+kept separate per user, channel and guild.
+**/
+func TestCooldowns(t *testing.T) {
+	invocation := func(userID string, channelID string) *Invocation {
+		return &Invocation{GuildID: "1", ChannelID: channelID, Author: &discordgo.User{ID: userID}}
+	}
+	now := time.Now()
+
+	t.Run("per user buckets are separate and refill", func(t *testing.T) {
+		cooldowns := []cooldown{perUser(2, time.Minute)}
+		for i := 0; i < 2; i++ {
+			if _, ok := takeCooldowns("test-user", cooldowns, invocation("a", "1"), now); !ok {
+				t.Logf("Use %d should have been allowed", i+1)
+				t.Fail()
+			}
+		}
+		wait, ok := takeCooldowns("test-user", cooldowns, invocation("a", "1"), now.Add(15*time.Second))
+		if ok || wait != 45*time.Second {
+			t.Logf("Third use should wait 45s, got %v (allowed: %t)", wait, ok)
+			t.Fail()
+		}
+		if _, ok := takeCooldowns("test-user", cooldowns, invocation("b", "1"), now); !ok {
+			t.Logf("Another user should have their own bucket")
+			t.Fail()
+		}
+		if _, ok := takeCooldowns("test-user", cooldowns, invocation("a", "1"), now.Add(time.Minute)); !ok {
+			t.Logf("The bucket should have refilled after a minute")
+			t.Fail()
+		}
+	})
+
+	t.Run("an exhausted bucket doesn't use up the others", func(t *testing.T) {
+		cooldowns := []cooldown{perUser(1, time.Minute), perChannel(2, time.Minute)}
+		takeCooldowns("test-channel", cooldowns, invocation("a", "1"), now)
+		if _, ok := takeCooldowns("test-channel", cooldowns, invocation("a", "1"), now); ok {
+			t.Logf("The user bucket should have been exhausted")
+			t.Fail()
+		}
+		if _, ok := takeCooldowns("test-channel", cooldowns, invocation("b", "1"), now); !ok {
+			t.Logf("The channel bucket was used by a rejected invocation")
+			t.Fail()
+		}
+		if _, ok := takeCooldowns("test-channel", cooldowns, invocation("c", "1"), now); ok {
+			t.Logf("The channel bucket should have been exhausted")
+			t.Fail()
+		}
+		if _, ok := takeCooldowns("test-channel", cooldowns, invocation("c", "2"), now); !ok {
+			t.Logf("Another channel should have its own bucket")
+			t.Fail()
+		}
+	})
+
+	t.Run("retry times are rounded up to the second", func(t *testing.T) {
+		if formatRetry(1500*time.Millisecond) != "2s" || formatRetry(90*time.Second) != "1m30s" {
+			t.Logf("Unexpected formatting: %s, %s", formatRetry(1500*time.Millisecond), formatRetry(90*time.Second))
+			t.Fail()
+		}
+	})
+
+	t.Run("aliases resolve to their command", func(t *testing.T) {
+		initCommandInfo()
+		if resolveCommand("w") != "wiki" || resolveCommand("IMG") != "image" || resolveCommand("kick") != "kick" {
+			t.Logf("Aliases did not resolve correctly")
+			t.Fail()
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -74,9 +75,10 @@ Helper function for handle_addon and should be used before scrape_addon
 to ensure the string is appropriately formatted such that it can be used
 as the end of the URL query to https://deadbydaylight.gamepedia.com/.
 */
-func formatAddon(command []string) string {
+func formatAddon(name string) string {
+	command := strings.Fields(name)
 	specialWords := " of on up brand outs "
-	for i := 1; i < len(command); i++ {
+	for i := 0; i < len(command); i++ {
 		words := strings.Split(command[i], "-")
 		for j := 0; j < len(words); j++ {
 			if !strings.Contains(specialWords, words[j]) {
@@ -87,7 +89,7 @@ func formatAddon(command []string) string {
 		}
 		command[i] = strings.Join(words, "-")
 	}
-	addon := strings.Join(command[1:], "_")
+	addon := strings.Join(command, "_")
 	addon = strings.Replace(addon, "_And", "_&", 1)
 	addon = url.QueryEscape(addon)
 	return addon
@@ -98,9 +100,10 @@ Helper function for Handle_perk and should be used before scrape_perk.
 Formats the perk provided in the command such that it can be used as
 the end of the URL query to https://deadbydaylight.gamepedia.com/.
 */
-func formatPerk(command []string) string {
+func formatPerk(name string) string {
+	command := strings.Fields(name)
 	specialWords := " in the of for from "
-	for i := 1; i < len(command); i++ {
+	for i := 0; i < len(command); i++ {
 		if strings.Contains(specialWords, command[i]) {
 			command[i] = strings.ToLower(command[i])
 		} else {
@@ -113,7 +116,7 @@ func formatPerk(command []string) string {
 			command[i] = strings.Join(words, "-")
 		}
 	}
-	perk := strings.Join(command[1:], "_")
+	perk := strings.Join(command, "_")
 	perk = strings.Replace(perk, "_And", "_&", 1)
 	perk = url.QueryEscape(perk)
 	return perk
@@ -127,7 +130,7 @@ desired information in the Survivor struct created above.
 func scrapeSurvivor(survivor string) Survivor {
 	var resultingSurvivor Survivor
 
-	resultingSurvivor.PageURL = "https://deadbydaylight.gamepedia.com/" + survivor
+	resultingSurvivor.PageURL = dbdWikiURL + "/" + survivor
 
 	// Request the HTML page.
 	doc := loadPage(resultingSurvivor.PageURL)
@@ -176,7 +179,7 @@ func scrapeSurvivor(survivor string) Survivor {
 	docPerks := doc.Find(".wikitable").First()
 	docPerks.Find("tr").Each(func(i int, s *goquery.Selection) {
 		resultingSurvivor.Perks = append(resultingSurvivor.Perks, s.Find("th").Last().Text())
-		resultingSurvivor.PerkURLs = append(resultingSurvivor.PerkURLs, "https://deadbydaylight.gamepedia.com"+s.Find("th").Last().Find("a").AttrOr("href", "nil"))
+		resultingSurvivor.PerkURLs = append(resultingSurvivor.PerkURLs, dbdWikiURL+s.Find("th").Last().Find("a").AttrOr("href", "nil"))
 	})
 
 	return resultingSurvivor
@@ -194,7 +197,7 @@ func scrapeKiller(killer string) Killer {
 	if strings.Contains(strings.ToLower(killer), "nemesis") {
 		killer = "Nemesis_T-Type"
 	}
-	resultingKiller.PageURL = "https://deadbydaylight.gamepedia.com/" + killer
+	resultingKiller.PageURL = dbdWikiURL + "/" + killer
 
 	// Request the HTML page.
 	doc := loadPage(resultingKiller.PageURL)
@@ -258,7 +261,7 @@ func scrapeKiller(killer string) Killer {
 	docPerks := doc.Find(".wikitable").First()
 	docPerks.Find("tr").Each(func(i int, s *goquery.Selection) {
 		resultingKiller.Perks = append(resultingKiller.Perks, s.Find("th").Last().Text())
-		resultingKiller.PerkURLs = append(resultingKiller.PerkURLs, "https://deadbydaylight.gamepedia.com"+s.Find("th").Last().Find("a").AttrOr("href", "nil"))
+		resultingKiller.PerkURLs = append(resultingKiller.PerkURLs, dbdWikiURL+s.Find("th").Last().Find("a").AttrOr("href", "nil"))
 	})
 
 	return resultingKiller
@@ -272,7 +275,7 @@ desired information in the Addon struct created above.
 func scrapeAddon(addon string) Addon {
 	var resultingAddon Addon
 
-	resultingAddon.PageURL = "https://deadbydaylight.gamepedia.com/" + addon
+	resultingAddon.PageURL = dbdWikiURL + "/" + addon
 
 	// Request the HTML page.
 	doc := loadPage(resultingAddon.PageURL)
@@ -315,7 +318,7 @@ desired information in the Perk struct created above.
 func scrapePerk(perk string) Perk {
 	var resultingPerk Perk
 
-	resultingPerk.PageURL = "https://deadbydaylight.gamepedia.com/" + perk
+	resultingPerk.PageURL = dbdWikiURL + "/" + perk
 
 	// Request the HTML page.
 	doc := loadPage(resultingPerk.PageURL)
@@ -377,16 +380,15 @@ desired information in the Shrine struct created above.
 func scrapeShrine() Shrine {
 	var resultingShrine Shrine
 
-	request, err := http.NewRequest(http.MethodGet, "https://raw.githubusercontent.com/cazwacki/periodic-dbd-data/master/shrine.json", nil)
+	// Timeout after 2 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, shrineURL, nil)
 	if err != nil {
 		return resultingShrine
 	}
 
-	client := http.Client{
-		Timeout: time.Second * 2, // Timeout after 2 seconds
-	}
-
-	res, err := client.Do(request)
+	res, err := httpClient.Do(request)
 	if err != nil || res.Body == nil {
 		return resultingShrine
 	}
@@ -406,12 +408,8 @@ func scrapeShrine() Shrine {
 Fetches survivor information from https://deadbydaylight.gamepedia.com/Dead_by_Daylight_Wiki
 and displays the survivor's attributes listed in the Survivor struct.
 **/
-func handleSurvivor(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if len(command) < 2 {
-		sendError(s, m, "survivor", Syntax)
-		return
-	}
-	requestedSurvivor := strings.Join(command[1:], " ")
+func handleSurvivor(s *discordgo.Session, m *Invocation, args *Args) {
+	requestedSurvivor := args.Values["name"]
 	requestedSurvivor = strings.ReplaceAll(strings.Title(strings.ToLower(requestedSurvivor)), " ", "_")
 	survivor := scrapeSurvivor(requestedSurvivor)
 	logInfo(fmt.Sprintf("%+v\n", survivor))
@@ -440,7 +438,7 @@ func handleSurvivor(s *discordgo.Session, m *discordgo.MessageCreate, command []
 	var thumbnail discordgo.MessageEmbedThumbnail
 	thumbnail.URL = survivor.IconURL
 	embed.Thumbnail = &thumbnail
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send message embed. " + err.Error())
 	}
@@ -450,12 +448,8 @@ func handleSurvivor(s *discordgo.Session, m *discordgo.MessageCreate, command []
 Fetches killer information from https://deadbydaylight.gamepedia.com/Dead_by_Daylight_Wiki
 and displays the killer's attributes listed in the Killer struct.
 **/
-func handleKiller(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if len(command) < 2 {
-		sendError(s, m, "killer", Syntax)
-		return
-	}
-	requestedKiller := strings.Join(command[1:], " ")
+func handleKiller(s *discordgo.Session, m *Invocation, args *Args) {
+	requestedKiller := args.Values["name"]
 	requestedKiller = strings.ReplaceAll(strings.Title(strings.ToLower(requestedKiller)), " ", "_")
 	killer := scrapeKiller(requestedKiller)
 	logInfo(fmt.Sprintf("%+v\n", killer))
@@ -491,7 +485,7 @@ func handleKiller(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	var thumbnail discordgo.MessageEmbedThumbnail
 	thumbnail.URL = killer.IconURL
 	embed.Thumbnail = &thumbnail
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send message embed. " + err.Error())
 	}
@@ -501,12 +495,8 @@ func handleKiller(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 Fetches addon information from https://deadbydaylight.gamepedia.com/Dead_by_Daylight_Wiki
 and displays the png icon as well as the add-on's function.
 **/
-func handleAddon(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if len(command) < 2 {
-		sendError(s, m, "addon", Syntax)
-		return
-	}
-	requestedAddonString := formatAddon(command)
+func handleAddon(s *discordgo.Session, m *Invocation, args *Args) {
+	requestedAddonString := formatAddon(args.Values["name"])
 	addon := scrapeAddon(requestedAddonString)
 	logInfo(fmt.Sprintf("%+v\n", addon))
 
@@ -524,7 +514,7 @@ func handleAddon(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 	var thumbnail discordgo.MessageEmbedThumbnail
 	thumbnail.URL = addon.IconURL
 	embed.Thumbnail = &thumbnail
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send message embed. " + err.Error())
 	}
@@ -534,18 +524,14 @@ func handleAddon(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 Fetches perk information from https://deadbydaylight.gamepedia.com/Dead_by_Daylight_Wiki
 and displays the gif icon as well as the perk's source (if there is one) and what it does.
 **/
-func handlePerk(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if len(command) < 2 {
-		sendError(s, m, "addon", Syntax)
-		return
-	}
-	requestedPerkString := formatPerk(command)
+func handlePerk(s *discordgo.Session, m *Invocation, args *Args) {
+	requestedPerkString := formatPerk(args.Values["name"])
 	perk := scrapePerk(requestedPerkString)
 	logInfo(fmt.Sprintf("%+v\n", perk))
 
 	// create and send response
 	if perk.Name == "" {
-		sendError(s, m, "addon", ReadParse)
+		sendError(s, m, "perk", ReadParse)
 		return
 	}
 	// construct embed message
@@ -560,15 +546,15 @@ func handlePerk(s *discordgo.Session, m *discordgo.MessageCreate, command []stri
 	var footer discordgo.MessageEmbedFooter
 	footer.Text = perk.Quote
 	embed.Footer = &footer
-	s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	sendEmbed(s, m, &embed)
 }
 
 /**
 Checks https://deadbydaylight.gamepedia.com/Dead_by_Daylight_Wiki for the most recent shrine
 post and outputs its information.
 **/
-func handleShrine(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
+func handleShrine(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 	shrine := scrapeShrine()
 
 	// create and send response
@@ -612,7 +598,7 @@ func handleShrine(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	embed.Footer = &footer
 
 	// send response
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err = sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send shrine embed! " + err.Error())
 		return
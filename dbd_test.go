@@ -11,18 +11,20 @@ Those commands don't need to be tested since they are verified to work
 at github.com/bwmarrin/discordgo
 **/
 func TestDBD(t *testing.T) {
-	// t.Run("Shrine scrapes correctly", func(t *testing.T) {
-	// 	shrine := scrapeShrine()
-	// 	perkCount := 4
-	// 	if len(shrine.Perks) != perkCount || len(shrine.Prices) != perkCount || len(shrine.Owners) != perkCount {
-	// 		t.Logf("Failed to pull the expected %d perks", perkCount)
-	// 		t.Fail()
-	// 	}
-	// 	if shrine.TimeUntilReset == "" {
-	// 		t.Logf("Failed to detect time until shrine resets")
-	// 		t.Fail()
-	// 	}
-	// })
+	useFixtures(t)
+
+	t.Run("Shrine scrapes correctly", func(t *testing.T) {
+		shrine := scrapeShrine()
+		perkCount := 4
+		if len(shrine.Perks) != perkCount {
+			t.Logf("Failed to pull the expected %d perks", perkCount)
+			t.Fail()
+		}
+		if shrine.End == 0 {
+			t.Logf("Failed to detect when the shrine resets")
+			t.Fail()
+		}
+	})
 
 	// just using one perk. this will fail if the design scheme for perks
 	// the website changes significantly.
@@ -0,0 +1,1084 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+An in-process stand-in for the Discord API, so handlers can be tested without
+bot tokens or a live server. The session it hands out sends every REST request
+to an httptest server that answers from one in-memory guild and records what
+the bot did. Gateway events are injected by updating the session state and
+calling the bot's handlers, the same way discordgo would.
+**/
+type fakeDiscord struct {
+	t       *testing.T
+	server  *httptest.Server
+	Session *discordgo.Session
+	Guild   *discordgo.Guild
+	Channel *discordgo.Channel // the channel messages are sent in by default
+	Bot     *discordgo.User
+
+	lock        sync.Mutex
+	nextID      int
+	users       map[string]*discordgo.User // everyone who was ever a member, even after leaving
+	channels    map[string]*discordgo.Channel
+	messages    map[string][]*discordgo.Message // every message per channel, oldest first
+	sent        []*discordgo.Message            // messages sent by the bot, in order
+	interaction map[string]string               // interaction tokens to their channel
+	clicked     map[string]string               // component interaction tokens to the message clicked
+	reactions   []fakeReaction
+	bans        []fakeModeration
+	kicks       []fakeModeration
+	unbans      []string
+	memberEdits []fakeMemberEdit
+	deleted     []string
+	auditLog    []*discordgo.AuditLogEntry
+
+	// the next sends to a channel fail with this status
+	failSends     int
+	failSendsWith int
+}
+
+type fakeReaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+}
+
+type fakeModeration struct {
+	UserID string
+	Reason string
+}
+
+type fakeMemberEdit struct {
+	UserID string
+	Fields map[string]interface{}
+}
+
+/**
+Rewrites requests meant for Discord so they reach the fake server instead.
+**/
+type fakeTransport struct {
+	target *url.URL
+}
+
+func (transport fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = transport.target.Scheme
+	r.URL.Host = transport.target.Host
+	r.Host = transport.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+/**
+Starts a fake Discord with a guild named "Test Server" that has one text
+channel, the bot and an owner. The server is stopped when the test ends.
+**/
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	fake := &fakeDiscord{
+		t:           t,
+		nextID:      1000,
+		users:       make(map[string]*discordgo.User),
+		channels:    make(map[string]*discordgo.Channel),
+		messages:    make(map[string][]*discordgo.Message),
+		interaction: make(map[string]string),
+		clicked:     make(map[string]string),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.server.Close)
+	target, _ := url.Parse(fake.server.URL)
+
+	session, err := discordgo.New("Bot fake-token")
+	if err != nil {
+		t.Fatalf("Unable to create a session: %s", err.Error())
+	}
+	session.Client = &http.Client{Transport: fakeTransport{target: target}, Timeout: 5 * time.Second}
+	session.State.MaxMessageCount = 100
+	fake.Session = session
+
+	fake.Bot = &discordgo.User{ID: fake.newID(), Username: "aio-bot", Discriminator: "0000", Bot: true}
+	session.State.User = fake.Bot
+
+	guildID := fake.newID()
+	fake.Guild = &discordgo.Guild{
+		ID:    guildID,
+		Name:  "Test Server",
+		Roles: []*discordgo.Role{{ID: guildID, Name: "@everyone", Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionChangeNickname}},
+	}
+	session.State.GuildAdd(fake.Guild)
+	fake.Channel = fake.AddChannel("general")
+	fake.AddMember(fake.Bot)
+	owner := fake.AddMember(&discordgo.User{ID: fake.newID(), Username: "owner", Discriminator: "0001"})
+	fake.Guild.OwnerID = owner.User.ID
+	return fake
+}
+
+func (fake *fakeDiscord) newID() string {
+	fake.nextID++
+	return strconv.Itoa(fake.nextID)
+}
+
+/**
+Adds a text channel to the guild.
+**/
+func (fake *fakeDiscord) AddChannel(name string) *discordgo.Channel {
+	fake.lock.Lock()
+	channel := &discordgo.Channel{ID: fake.newID(), GuildID: fake.Guild.ID, Name: name, Type: discordgo.ChannelTypeGuildText}
+	fake.channels[channel.ID] = channel
+	fake.lock.Unlock()
+	fake.Session.State.ChannelAdd(channel)
+	return channel
+}
+
+/**
+Adds a role to the guild.
+**/
+func (fake *fakeDiscord) AddRole(name string, permissions int64) *discordgo.Role {
+	fake.lock.Lock()
+	role := &discordgo.Role{ID: fake.newID(), Name: name, Permissions: permissions}
+	fake.lock.Unlock()
+	fake.Session.State.RoleAdd(fake.Guild.ID, role)
+	return role
+}
+
+/**
+Adds a member to the guild without sending a GuildMemberAdd event.
+**/
+func (fake *fakeDiscord) AddMember(user *discordgo.User, roles ...string) *discordgo.Member {
+	member := &discordgo.Member{GuildID: fake.Guild.ID, User: user, Roles: roles, JoinedAt: time.Now()}
+	fake.lock.Lock()
+	fake.users[user.ID] = user
+	fake.lock.Unlock()
+	fake.Session.State.MemberAdd(member)
+	return member
+}
+
+/**
+Creates a user who is a member of the guild with the given roles.
+**/
+func (fake *fakeDiscord) AddUser(name string, roles ...string) *discordgo.User {
+	fake.lock.Lock()
+	user := &discordgo.User{ID: fake.newID(), Username: name, Discriminator: "1234"}
+	fake.lock.Unlock()
+	fake.AddMember(user, roles...)
+	return user
+}
+
+/**
+Looks up a member from the session state, or returns nil.
+**/
+func (fake *fakeDiscord) Member(userID string) *discordgo.Member {
+	member, err := fake.Session.State.Member(fake.Guild.ID, userID)
+	if err != nil {
+		return nil
+	}
+	return member
+}
+
+/**
+Points the bot at an in-memory SQLite database until the test ends.
+**/
+func useTestStorage(t *testing.T) {
+	loadTableNames()
+	store, err := openSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open SQLite: %s", err.Error())
+	}
+	if err = store.migrate(len(migrations)); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+	previous := storage
+	storage = store
+	t.Cleanup(func() {
+		storage = previous
+		store.Close()
+	})
+}
+
+/****
+GATEWAY EVENTS
+****/
+
+/**
+Updates the session state with the event and passes it to the handler the bot
+registers for it in runBot.
+**/
+func (fake *fakeDiscord) Inject(event interface{}) {
+	s := fake.Session
+	if create, ok := event.(*discordgo.MessageCreate); ok {
+		// the state edits the messages it keeps in place, so it gets its own copy
+		// for edits not to race with handlers still reading the original
+		copied := *create.Message
+		s.State.OnInterface(s, &discordgo.MessageCreate{Message: &copied})
+	} else {
+		s.State.OnInterface(s, event)
+	}
+	switch event := event.(type) {
+	case *discordgo.MessageCreate:
+		messageCreate(s, event)
+	case *discordgo.MessageReactionAdd:
+		messageReactionAdd(s, event)
+	case *discordgo.GuildMemberAdd:
+		guildMemberAdd(s, event)
+	case *discordgo.GuildMemberRemove:
+		guildMemberRemove(s, event)
+	case *discordgo.GuildBanAdd:
+		guildBanAdd(s, event)
+	case *discordgo.GuildBanRemove:
+		guildBanRemove(s, event)
+	case *discordgo.VoiceStateUpdate:
+		voiceStateUpdate(s, event)
+	case *discordgo.InteractionCreate:
+		interactionCreate(s, event)
+	case *discordgo.MessageUpdate:
+		messageUpdate(s, event)
+	case *discordgo.MessageDelete:
+		messageDelete(s, event)
+	case *discordgo.MessageDeleteBulk:
+		messageDeleteBulk(s, event)
+	case *discordgo.ChannelCreate:
+		channelCreate(s, event)
+	case *discordgo.ChannelUpdate:
+		channelUpdate(s, event)
+	case *discordgo.ChannelDelete:
+		channelDelete(s, event)
+	case *discordgo.GuildRoleCreate:
+		guildRoleCreate(s, event)
+	case *discordgo.GuildRoleUpdate:
+		guildRoleUpdate(s, event)
+	case *discordgo.GuildRoleDelete:
+		guildRoleDelete(s, event)
+	case *discordgo.GuildEmojisUpdate:
+		guildEmojisUpdate(s, event)
+	case *discordgo.GuildMemberUpdate:
+		guildMemberUpdate(s, event)
+	case *discordgo.GuildUpdate:
+		guildUpdate(s, event)
+	default:
+		fake.t.Fatalf("The fake can't inject %T", event)
+	}
+}
+
+/**
+Sends a message from the user in the default channel.
+**/
+func (fake *fakeDiscord) SendMessage(author *discordgo.User, content string) *discordgo.Message {
+	return fake.SendMessageIn(fake.Channel.ID, author, content)
+}
+
+/**
+Sends a message from the user in the given channel.
+**/
+func (fake *fakeDiscord) SendMessageIn(channelID string, author *discordgo.User, content string) *discordgo.Message {
+	return fake.sendMessage(&discordgo.Message{ChannelID: channelID, Content: content, Author: author})
+}
+
+/**
+Sends a message from the user that replies to another message.
+**/
+func (fake *fakeDiscord) SendReply(author *discordgo.User, to *discordgo.Message, content string) *discordgo.Message {
+	return fake.sendMessage(&discordgo.Message{
+		ChannelID:        to.ChannelID,
+		Content:          content,
+		Author:           author,
+		MessageReference: &discordgo.MessageReference{MessageID: to.ID, ChannelID: to.ChannelID, GuildID: fake.Guild.ID},
+	})
+}
+
+func (fake *fakeDiscord) sendMessage(message *discordgo.Message) *discordgo.Message {
+	channelID := message.ChannelID
+	author := message.Author
+	fake.lock.Lock()
+	message.ID = fake.newID()
+	message.GuildID = fake.Guild.ID
+	message.Timestamp = time.Now()
+	fake.messages[channelID] = append(fake.messages[channelID], message)
+	fake.lock.Unlock()
+	if member := fake.Member(author.ID); member != nil {
+		message.Member = member
+	}
+	fake.Inject(&discordgo.MessageCreate{Message: message})
+	return message
+}
+
+/**
+Sends a message from the user in the default channel and waits for the bot to
+reply in that channel.
+**/
+func (fake *fakeDiscord) Reply(author *discordgo.User, content string) *discordgo.Message {
+	fake.t.Helper()
+	replies := func() []*discordgo.Message {
+		var replies []*discordgo.Message
+		for _, message := range fake.Sent() {
+			if message.ChannelID == fake.Channel.ID {
+				replies = append(replies, message)
+			}
+		}
+		return replies
+	}
+	before := len(replies())
+	fake.SendMessage(author, content)
+	fake.WaitFor("a reply to "+content, func() bool { return len(replies()) > before })
+	return replies()[before]
+}
+
+/**
+Reacts to a message as the user.
+**/
+func (fake *fakeDiscord) React(user *discordgo.User, message *discordgo.Message, emoji string) {
+	fake.Inject(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    user.ID,
+		MessageID: message.ID,
+		ChannelID: message.ChannelID,
+		GuildID:   fake.Guild.ID,
+		Emoji:     discordgo.Emoji{Name: emoji},
+	}})
+}
+
+/**
+Has the user join the guild.
+**/
+func (fake *fakeDiscord) Join(user *discordgo.User) {
+	fake.Inject(&discordgo.GuildMemberAdd{Member: &discordgo.Member{GuildID: fake.Guild.ID, User: user, JoinedAt: time.Now()}})
+}
+
+/**
+Runs a slash command as the user in the default channel.
+**/
+func (fake *fakeDiscord) SlashCommand(user *discordgo.User, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+	fake.lock.Lock()
+	id := fake.newID()
+	token := "token-" + id
+	fake.interaction[token] = fake.Channel.ID
+	fake.lock.Unlock()
+	fake.Inject(&discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        id,
+		AppID:     fake.Bot.ID,
+		Type:      discordgo.InteractionApplicationCommand,
+		Token:     token,
+		GuildID:   fake.Guild.ID,
+		ChannelID: fake.Channel.ID,
+		Member:    fake.Member(user.ID),
+		Data:      discordgo.ApplicationCommandInteractionData{Name: name, Options: options},
+	}})
+}
+
+/**
+Has the user click a button or pick from a select menu on one of the bot's
+messages. Values are the options picked from a select menu.
+**/
+func (fake *fakeDiscord) Click(user *discordgo.User, message *discordgo.Message, customID string, values ...string) {
+	fake.lock.Lock()
+	id := fake.newID()
+	token := "token-" + id
+	fake.interaction[token] = message.ChannelID
+	fake.clicked[token] = message.ID
+	clicked := *fake.findMessage(message.ChannelID, message.ID)
+	fake.lock.Unlock()
+
+	// Discord sends the member's permissions in the channel with the interaction
+	member := *fake.Member(user.ID)
+	permissions, err := fake.Session.State.UserChannelPermissions(user.ID, message.ChannelID)
+	if err != nil {
+		fake.t.Fatalf("Unable to get the permissions of %s: %s", user.Username, err.Error())
+	}
+	member.Permissions = permissions
+
+	componentType := discordgo.ButtonComponent
+	if len(values) > 0 {
+		componentType = discordgo.SelectMenuComponent
+	}
+	fake.Inject(&discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        id,
+		AppID:     fake.Bot.ID,
+		Type:      discordgo.InteractionMessageComponent,
+		Token:     token,
+		GuildID:   fake.Guild.ID,
+		ChannelID: message.ChannelID,
+		Member:    &member,
+		Message:   &clicked,
+		Data:      discordgo.MessageComponentInteractionData{CustomID: customID, ComponentType: componentType, Values: values},
+	}})
+}
+
+/****
+RECORDED ACTIONS
+****/
+
+/**
+Waits for the condition to become true, failing the test after two seconds.
+Handlers run commands in goroutines, so their effects show up a little later.
+**/
+func (fake *fakeDiscord) WaitFor(description string, condition func() bool) {
+	fake.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			fake.t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/**
+Waits for the bot to send its nth message and returns it.
+**/
+func (fake *fakeDiscord) WaitForMessage(n int) *discordgo.Message {
+	fake.t.Helper()
+	fake.WaitFor("message "+strconv.Itoa(n), func() bool { return len(fake.Sent()) >= n })
+	return fake.Sent()[n-1]
+}
+
+func (fake *fakeDiscord) Sent() []*discordgo.Message {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]*discordgo.Message{}, fake.sent...)
+}
+
+/**
+Returns the messages the bot sent to a channel, in order. DM channels have the
+ID "dm-" followed by the user's ID.
+**/
+func (fake *fakeDiscord) SentTo(channelID string) []*discordgo.Message {
+	var messages []*discordgo.Message
+	for _, message := range fake.Sent() {
+		if message.ChannelID == channelID {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+/**
+Returns a copy of a message as it is now, after any edits.
+**/
+func (fake *fakeDiscord) Message(channelID string, messageID string) discordgo.Message {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if message := fake.findMessage(channelID, messageID); message != nil {
+		return *message
+	}
+	return discordgo.Message{}
+}
+
+func (fake *fakeDiscord) Reactions() []fakeReaction {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]fakeReaction{}, fake.reactions...)
+}
+
+func (fake *fakeDiscord) Bans() []fakeModeration {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]fakeModeration{}, fake.bans...)
+}
+
+func (fake *fakeDiscord) Unbans() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]string{}, fake.unbans...)
+}
+
+func (fake *fakeDiscord) Kicks() []fakeModeration {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]fakeModeration{}, fake.kicks...)
+}
+
+func (fake *fakeDiscord) MemberEdits() []fakeMemberEdit {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]fakeMemberEdit{}, fake.memberEdits...)
+}
+
+func (fake *fakeDiscord) Deleted() []string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]string{}, fake.deleted...)
+}
+
+/**
+Adds an entry to the audit log, which the ban and leave handlers read.
+**/
+func (fake *fakeDiscord) AddAuditLogEntry(entry *discordgo.AuditLogEntry) {
+	fake.lock.Lock()
+	// the mod log only trusts recent entries, so these IDs are snowflakes from now
+	fake.nextID++
+	entry.ID = strconv.FormatInt((time.Now().UnixMilli()-1420070400000)<<22+int64(fake.nextID), 10)
+	fake.auditLog = append([]*discordgo.AuditLogEntry{entry}, fake.auditLog...)
+	fake.lock.Unlock()
+}
+
+/**
+Makes the next n messages sent to a channel fail with the HTTP status.
+**/
+func (fake *fakeDiscord) FailSends(n int, status int) {
+	fake.lock.Lock()
+	fake.failSends = n
+	fake.failSendsWith = status
+	fake.lock.Unlock()
+}
+
+/****
+REST API
+****/
+
+func (fake *fakeDiscord) writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func (fake *fakeDiscord) notFound(w http.ResponseWriter, what string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 10000, "message": "Unknown " + what})
+}
+
+/**
+Reads the JSON body of a request, including the payload_json part of a
+multipart request with files.
+**/
+func readPayload(r *http.Request, value interface{}) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return json.Unmarshal([]byte(r.FormValue("payload_json")), value)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	// put the body back so the payload can be read again
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	return json.Unmarshal(body, value)
+}
+
+/**
+Reads a message payload, along with the fields it set. Components are
+interfaces that only discordgo.Message knows how to decode, so the payload is
+read as a message rather than the struct the bot sent it as.
+**/
+func readMessage(r *http.Request) (*discordgo.Message, map[string]json.RawMessage) {
+	var fields map[string]json.RawMessage
+	readPayload(r, &fields)
+	message := &discordgo.Message{}
+	raw, _ := json.Marshal(fields)
+	if err := json.Unmarshal(raw, message); err != nil {
+		return message, nil
+	}
+	// null means the field wasn't set, same as leaving it out
+	for name, value := range fields {
+		if string(value) == "null" {
+			delete(fields, name)
+		}
+	}
+	return message, fields
+}
+
+/**
+Returns the values of the {} segments if the path matches the pattern.
+**/
+func matchPath(pattern string, path string) ([]string, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	var values []string
+	for i, part := range patternParts {
+		if part == "{}" {
+			values = append(values, pathParts[i])
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+/**
+Stores a message the bot sent and returns it as Discord would.
+**/
+func (fake *fakeDiscord) recordMessage(channelID string, data *discordgo.Message) *discordgo.Message {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	message := &discordgo.Message{
+		ID:         fake.newID(),
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+		Flags:      data.Flags,
+		Author:     fake.Bot,
+		Timestamp:  time.Now(),
+	}
+	if channel, ok := fake.channels[channelID]; ok {
+		message.GuildID = channel.GuildID
+	}
+	fake.messages[channelID] = append(fake.messages[channelID], message)
+	fake.sent = append(fake.sent, message)
+	return message
+}
+
+func (fake *fakeDiscord) findMessage(channelID string, messageID string) *discordgo.Message {
+	for _, message := range fake.messages[channelID] {
+		if message.ID == messageID {
+			return message
+		}
+	}
+	return nil
+}
+
+type fakeRoute struct {
+	method  string
+	pattern string
+	handle  func(fake *fakeDiscord, w http.ResponseWriter, r *http.Request, ids []string)
+}
+
+// the endpoints the bot uses, with {} standing for an ID
+var fakeRoutes = []fakeRoute{
+	{"GET", "/users/{}", (*fakeDiscord).getUser},
+	{"POST", "/users/@me/channels", (*fakeDiscord).createDM},
+	{"GET", "/channels/{}", (*fakeDiscord).getChannel},
+	{"GET", "/channels/{}/messages", (*fakeDiscord).getMessages},
+	{"POST", "/channels/{}/messages", (*fakeDiscord).createMessage},
+	{"GET", "/channels/{}/messages/{}", (*fakeDiscord).getMessage},
+	{"PATCH", "/channels/{}/messages/{}", (*fakeDiscord).editMessage},
+	{"DELETE", "/channels/{}/messages/{}", (*fakeDiscord).deleteMessage},
+	{"POST", "/channels/{}/messages/bulk-delete", (*fakeDiscord).bulkDelete},
+	{"PUT", "/channels/{}/messages/{}/reactions/{}/@me", (*fakeDiscord).addReaction},
+	{"DELETE", "/channels/{}/messages/{}/reactions/{}/{}", (*fakeDiscord).noContent},
+	{"DELETE", "/channels/{}/messages/{}/reactions", (*fakeDiscord).clearReactions},
+	{"POST", "/channels/{}/invites", (*fakeDiscord).createInvite},
+	{"GET", "/guilds/{}", (*fakeDiscord).getGuild},
+	{"GET", "/guilds/{}/roles", (*fakeDiscord).getRoles},
+	{"GET", "/guilds/{}/channels", (*fakeDiscord).getChannels},
+	{"GET", "/guilds/{}/members", (*fakeDiscord).getMembers},
+	{"GET", "/guilds/{}/members/{}", (*fakeDiscord).getMember},
+	{"PATCH", "/guilds/{}/members/{}", (*fakeDiscord).editMember},
+	{"PATCH", "/guilds/{}/members/@me/nick", (*fakeDiscord).editMember},
+	{"DELETE", "/guilds/{}/members/{}", (*fakeDiscord).kickMember},
+	{"PUT", "/guilds/{}/members/{}/roles/{}", (*fakeDiscord).addMemberRole},
+	{"DELETE", "/guilds/{}/members/{}/roles/{}", (*fakeDiscord).removeMemberRole},
+	{"PUT", "/guilds/{}/bans/{}", (*fakeDiscord).banMember},
+	{"DELETE", "/guilds/{}/bans/{}", (*fakeDiscord).unbanMember},
+	{"GET", "/guilds/{}/audit-logs", (*fakeDiscord).getAuditLog},
+	{"PUT", "/applications/{}/commands", (*fakeDiscord).overwriteCommands},
+	{"POST", "/interactions/{}/{}/callback", (*fakeDiscord).interactionCallback},
+	{"PATCH", "/webhooks/{}/{}/messages/@original", (*fakeDiscord).interactionMessage},
+	{"DELETE", "/webhooks/{}/{}/messages/@original", (*fakeDiscord).noContent},
+	{"POST", "/webhooks/{}/{}", (*fakeDiscord).interactionMessage},
+}
+
+func (fake *fakeDiscord) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v"+discordgo.APIVersion)
+	for _, route := range fakeRoutes {
+		if route.method != r.Method {
+			continue
+		}
+		if ids, ok := matchPath(route.pattern, path); ok {
+			route.handle(fake, w, r, ids)
+			return
+		}
+	}
+	fake.t.Logf("The fake Discord API has no route for %s %s", r.Method, path)
+	fake.notFound(w, "route")
+}
+
+func (fake *fakeDiscord) noContent(w http.ResponseWriter, r *http.Request, ids []string) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) getUser(w http.ResponseWriter, r *http.Request, ids []string) {
+	if ids[0] == "@me" {
+		fake.writeJSON(w, fake.Bot)
+		return
+	}
+	fake.lock.Lock()
+	user := fake.users[ids[0]]
+	fake.lock.Unlock()
+	if user == nil {
+		fake.notFound(w, "user")
+		return
+	}
+	fake.writeJSON(w, user)
+}
+
+func (fake *fakeDiscord) createDM(w http.ResponseWriter, r *http.Request, ids []string) {
+	var data struct {
+		RecipientID string `json:"recipient_id"`
+	}
+	readPayload(r, &data)
+	fake.lock.Lock()
+	channel := &discordgo.Channel{ID: "dm-" + data.RecipientID, Type: discordgo.ChannelTypeDM}
+	if member := fake.Member(data.RecipientID); member != nil {
+		channel.Recipients = []*discordgo.User{member.User}
+	}
+	fake.channels[channel.ID] = channel
+	fake.lock.Unlock()
+	fake.writeJSON(w, channel)
+}
+
+func (fake *fakeDiscord) getChannel(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	channel, ok := fake.channels[ids[0]]
+	fake.lock.Unlock()
+	if !ok {
+		fake.notFound(w, "channel")
+		return
+	}
+	fake.writeJSON(w, channel)
+}
+
+func (fake *fakeDiscord) getMessages(w http.ResponseWriter, r *http.Request, ids []string) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 50
+	}
+	before := r.URL.Query().Get("before")
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	// newest first, like Discord
+	messages := []*discordgo.Message{}
+	history := fake.messages[ids[0]]
+	for i := len(history) - 1; i >= 0 && len(messages) < limit; i-- {
+		if before != "" && snowflakeOrder(history[i].ID) >= snowflakeOrder(before) {
+			continue
+		}
+		messages = append(messages, history[i])
+	}
+	fake.writeJSON(w, messages)
+}
+
+func snowflakeOrder(id string) int {
+	order, _ := strconv.Atoi(id)
+	return order
+}
+
+func (fake *fakeDiscord) createMessage(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	failing, status := fake.failSends > 0, fake.failSendsWith
+	if failing {
+		fake.failSends--
+	}
+	fake.lock.Unlock()
+	if failing {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message": "fake failure", "code": 0}`))
+		return
+	}
+
+	data, fields := readMessage(r)
+	if fields == nil {
+		fake.t.Logf("Unreadable message in %s", ids[0])
+	}
+	fake.writeJSON(w, fake.recordMessage(ids[0], data))
+}
+
+func (fake *fakeDiscord) getMessage(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	message := fake.findMessage(ids[0], ids[1])
+	fake.lock.Unlock()
+	if message == nil {
+		fake.notFound(w, "message")
+		return
+	}
+	fake.writeJSON(w, message)
+}
+
+func (fake *fakeDiscord) editMessage(w http.ResponseWriter, r *http.Request, ids []string) {
+	data, fields := readMessage(r)
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	message := fake.findMessage(ids[0], ids[1])
+	if message == nil {
+		fake.notFound(w, "message")
+		return
+	}
+	applyEdit(message, data, fields)
+	fake.writeJSON(w, message)
+}
+
+/**
+Changes the fields of the message that the edit set.
+**/
+func applyEdit(message *discordgo.Message, data *discordgo.Message, fields map[string]json.RawMessage) {
+	if _, ok := fields["content"]; ok {
+		message.Content = data.Content
+	}
+	if _, ok := fields["embeds"]; ok {
+		message.Embeds = data.Embeds
+	}
+	if _, ok := fields["components"]; ok {
+		message.Components = data.Components
+	}
+}
+
+func (fake *fakeDiscord) deleteMessage(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	fake.deleted = append(fake.deleted, ids[1])
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) bulkDelete(w http.ResponseWriter, r *http.Request, ids []string) {
+	var data struct {
+		Messages []string `json:"messages"`
+	}
+	readPayload(r, &data)
+	fake.lock.Lock()
+	fake.deleted = append(fake.deleted, data.Messages...)
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) addReaction(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	fake.reactions = append(fake.reactions, fakeReaction{ChannelID: ids[0], MessageID: ids[1], Emoji: ids[2]})
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) clearReactions(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	var reactions []fakeReaction
+	for _, reaction := range fake.reactions {
+		if reaction.MessageID != ids[1] {
+			reactions = append(reactions, reaction)
+		}
+	}
+	fake.reactions = reactions
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) createInvite(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.writeJSON(w, &discordgo.Invite{Code: "fake-invite", Channel: &discordgo.Channel{ID: ids[0]}})
+}
+
+func (fake *fakeDiscord) getGuild(w http.ResponseWriter, r *http.Request, ids []string) {
+	guild, err := fake.Session.State.Guild(ids[0])
+	if err != nil {
+		fake.notFound(w, "guild")
+		return
+	}
+	fake.Session.State.RLock()
+	defer fake.Session.State.RUnlock()
+	fake.writeJSON(w, guild)
+}
+
+func (fake *fakeDiscord) getRoles(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.Session.State.RLock()
+	defer fake.Session.State.RUnlock()
+	fake.writeJSON(w, fake.Guild.Roles)
+}
+
+func (fake *fakeDiscord) getChannels(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	channels := []*discordgo.Channel{}
+	for _, channel := range fake.channels {
+		if channel.GuildID == ids[0] {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return snowflakeOrder(channels[i].ID) < snowflakeOrder(channels[j].ID) })
+	fake.writeJSON(w, channels)
+}
+
+func (fake *fakeDiscord) getMembers(w http.ResponseWriter, r *http.Request, ids []string) {
+	after := r.URL.Query().Get("after")
+	fake.Session.State.RLock()
+	defer fake.Session.State.RUnlock()
+	members := []*discordgo.Member{}
+	for _, member := range fake.Guild.Members {
+		if snowflakeOrder(member.User.ID) > snowflakeOrder(after) {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return snowflakeOrder(members[i].User.ID) < snowflakeOrder(members[j].User.ID) })
+	fake.writeJSON(w, members)
+}
+
+func (fake *fakeDiscord) getMember(w http.ResponseWriter, r *http.Request, ids []string) {
+	member := fake.Member(ids[1])
+	if member == nil {
+		fake.notFound(w, "member")
+		return
+	}
+	fake.Session.State.RLock()
+	defer fake.Session.State.RUnlock()
+	fake.writeJSON(w, member)
+}
+
+func (fake *fakeDiscord) editMember(w http.ResponseWriter, r *http.Request, ids []string) {
+	// the bot's own nickname is set through /members/@me/nick
+	userID := fake.Bot.ID
+	if len(ids) > 1 && ids[1] != "@me" {
+		userID = ids[1]
+	}
+	fields := make(map[string]interface{})
+	readPayload(r, &fields)
+	member := fake.Member(userID)
+	if member == nil {
+		fake.notFound(w, "member")
+		return
+	}
+	fake.lock.Lock()
+	fake.memberEdits = append(fake.memberEdits, fakeMemberEdit{UserID: userID, Fields: fields})
+	fake.lock.Unlock()
+
+	fake.Session.State.Lock()
+	if nick, ok := fields["nick"].(string); ok {
+		member.Nick = nick
+	}
+	if raw, ok := fields["communication_disabled_until"].(string); ok {
+		until, _ := time.Parse(time.RFC3339, raw)
+		member.CommunicationDisabledUntil = &until
+	}
+	fake.Session.State.Unlock()
+	fake.getMember(w, r, []string{ids[0], userID})
+}
+
+func (fake *fakeDiscord) editMemberRoles(w http.ResponseWriter, ids []string, add bool) {
+	member := fake.Member(ids[1])
+	if member == nil {
+		fake.notFound(w, "member")
+		return
+	}
+	fake.Session.State.Lock()
+	var roles []string
+	for _, role := range member.Roles {
+		if role != ids[2] {
+			roles = append(roles, role)
+		}
+	}
+	if add {
+		roles = append(roles, ids[2])
+	}
+	member.Roles = roles
+	fake.Session.State.Unlock()
+
+	fake.lock.Lock()
+	fake.memberEdits = append(fake.memberEdits, fakeMemberEdit{UserID: ids[1], Fields: map[string]interface{}{"roles": roles}})
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) addMemberRole(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.editMemberRoles(w, ids, true)
+}
+
+func (fake *fakeDiscord) removeMemberRole(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.editMemberRoles(w, ids, false)
+}
+
+func (fake *fakeDiscord) kickMember(w http.ResponseWriter, r *http.Request, ids []string) {
+	if fake.Member(ids[1]) == nil {
+		fake.notFound(w, "member")
+		return
+	}
+	fake.lock.Lock()
+	fake.kicks = append(fake.kicks, fakeModeration{UserID: ids[1], Reason: r.URL.Query().Get("reason")})
+	fake.lock.Unlock()
+	fake.Session.State.MemberRemove(&discordgo.Member{GuildID: ids[0], User: &discordgo.User{ID: ids[1]}})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) banMember(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	fake.bans = append(fake.bans, fakeModeration{UserID: ids[1], Reason: r.URL.Query().Get("reason")})
+	fake.lock.Unlock()
+	if fake.Member(ids[1]) != nil {
+		fake.Session.State.MemberRemove(&discordgo.Member{GuildID: ids[0], User: &discordgo.User{ID: ids[1]}})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) unbanMember(w http.ResponseWriter, r *http.Request, ids []string) {
+	fake.lock.Lock()
+	fake.unbans = append(fake.unbans, ids[1])
+	fake.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fake *fakeDiscord) getAuditLog(w http.ResponseWriter, r *http.Request, ids []string) {
+	actionType, err := strconv.Atoi(r.URL.Query().Get("action_type"))
+	if err != nil {
+		actionType = -1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 50
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	entries := []*discordgo.AuditLogEntry{}
+	for _, entry := range fake.auditLog {
+		if len(entries) < limit && (actionType < 0 || (entry.ActionType != nil && int(*entry.ActionType) == actionType)) {
+			entries = append(entries, entry)
+		}
+	}
+	fake.writeJSON(w, &discordgo.GuildAuditLog{AuditLogEntries: entries})
+}
+
+func (fake *fakeDiscord) overwriteCommands(w http.ResponseWriter, r *http.Request, ids []string) {
+	commands := []*discordgo.ApplicationCommand{}
+	readPayload(r, &commands)
+	fake.writeJSON(w, commands)
+}
+
+/**
+Responses to slash commands are recorded like messages in the channel the
+interaction came from.
+**/
+func (fake *fakeDiscord) interactionMessage(w http.ResponseWriter, r *http.Request, ids []string) {
+	data, _ := readMessage(r)
+	fake.lock.Lock()
+	channelID := fake.interaction[ids[1]]
+	fake.lock.Unlock()
+	fake.writeJSON(w, fake.recordMessage(channelID, data))
+}
+
+/**
+Deferred responses are filled in later through the webhook routes. Replies are
+recorded like messages, and updates edit the message whose component was
+clicked.
+**/
+func (fake *fakeDiscord) interactionCallback(w http.ResponseWriter, r *http.Request, ids []string) {
+	var response struct {
+		Type discordgo.InteractionResponseType `json:"type"`
+		Data json.RawMessage                   `json:"data"`
+	}
+	readPayload(r, &response)
+	data, fields := readMessage(httptest.NewRequest("POST", "/", bytes.NewReader(response.Data)))
+
+	fake.lock.Lock()
+	channelID := fake.interaction[ids[1]]
+	messageID := fake.clicked[ids[1]]
+	fake.lock.Unlock()
+	switch response.Type {
+	case discordgo.InteractionResponseChannelMessageWithSource:
+		fake.recordMessage(channelID, data)
+	case discordgo.InteractionResponseUpdateMessage:
+		fake.lock.Lock()
+		if message := fake.findMessage(channelID, messageID); message != nil {
+			applyEdit(message, data, fields)
+		}
+		fake.lock.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,15 +5,18 @@ go 1.14
 require (
 	cloud.google.com/go/compute v1.8.0 // indirect
 	github.com/PuerkitoBio/goquery v1.8.0
-	github.com/bwmarrin/discordgo v0.25.0
+	github.com/bwmarrin/discordgo v0.27.1
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/googleapis/gax-go/v2 v2.5.1 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/prometheus/client_golang v1.12.2
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
 	golang.org/x/net v0.0.0-20220811182439-13a9a731de15 // indirect
 	golang.org/x/oauth2 v0.0.0-20220808172628-8227340efae7 // indirect
 	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
 	google.golang.org/api v0.92.0
 	google.golang.org/genproto v0.0.0-20220810155839-1856144b1d9c // indirect
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.14.8
 )
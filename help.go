@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// order the categories are shown in by ~help
+var helpCategories = []string{"Utility", "Lookup", "Moderation", "Voice", "Server", "Dead by Daylight", "Owner"}
+
+var permissionNames = map[int64]string{
+	discordgo.PermissionCreateInstantInvite: "Create Invite",
+	discordgo.PermissionKickMembers:         "Kick Members",
+	discordgo.PermissionBanMembers:          "Ban Members",
+	discordgo.PermissionManageServer:        "Manage Server",
+	discordgo.PermissionManageMessages:      "Manage Messages",
+	discordgo.PermissionVoiceMuteMembers:    "Mute Members",
+	discordgo.PermissionVoiceDeafenMembers:  "Deafen Members",
+	discordgo.PermissionVoiceMoveMembers:    "Move Members",
+	discordgo.PermissionChangeNickname:      "Change Nickname",
+	discordgo.PermissionManageNicknames:     "Manage Nicknames",
+	discordgo.PermissionManageRoles:         "Manage Roles",
+	discordgo.PermissionManageEmojis:        "Manage Emojis",
+	discordgo.PermissionModerateMembers:     "Timeout Members",
+	discordgo.PermissionAdministrator:       "Administrator",
+}
+
+/**
+Groups the names of the commands the member can use by category.
+*/
+func visibleCommands(access commandAccess) map[string][]string {
+	categories := make(map[string][]string)
+	for name, cmd := range commandList {
+		if !access.canUse(name) {
+			continue
+		}
+		categories[cmd.category] = append(categories[cmd.category], name)
+	}
+	for category := range categories {
+		sort.Strings(categories[category])
+	}
+	return categories
+}
+
+/**
+Describes the arguments a command takes, e.g. "<user> [reason]".
+*/
+func specSyntax(specs []argSpec) string {
+	var parts []string
+	for _, spec := range specs {
+		part := spec.name
+		switch spec.kind {
+		case ArgChoice:
+			part = strings.Join(spec.choices, "|")
+		case ArgRest:
+			part += "..."
+		case ArgFlag:
+			part = "-" + spec.name + " <" + spec.name + ">"
+		}
+		if spec.optional {
+			parts = append(parts, "["+part+"]")
+		} else {
+			parts = append(parts, "<"+part+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+/**
+Builds one line of usage per way the command can be invoked.
+*/
+func usageLines(guildPrefix string, name string) []string {
+	cmd := commandList[name]
+	if cmd.subcommands == nil {
+		return []string{strings.TrimSpace(guildPrefix + name + " " + specSyntax(cmd.args))}
+	}
+
+	var subcommands []string
+	for subcommand := range cmd.subcommands {
+		subcommands = append(subcommands, subcommand)
+	}
+	sort.Strings(subcommands)
+
+	var lines []string
+	for _, subcommand := range subcommands {
+		line := guildPrefix + name
+		if subcommand != "" {
+			line += " " + subcommand
+		}
+		lines = append(lines, strings.TrimSpace(line+" "+specSyntax(cmd.subcommands[subcommand])))
+	}
+	return lines
+}
+
+/**
+Builds the paginated list of commands, one page per category.
+*/
+func helpPages(guildPrefix string, access commandAccess) []*discordgo.MessageEmbed {
+	categories := visibleCommands(access)
+
+	var pages []*discordgo.MessageEmbed
+	for _, category := range helpCategories {
+		names, ok := categories[category]
+		if !ok {
+			continue
+		}
+
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = "❓ " + category + " Commands ❓"
+		embed.Description = fmt.Sprintf("Use `%shelp <command>` to see how to use a command.", guildPrefix)
+
+		var thumbnail discordgo.MessageEmbedThumbnail
+		thumbnail.URL = "https://img.pngio.com/robot-icon-of-flat-style-available-in-svg-png-eps-ai-icon-robot-icon-png-256_256.png"
+		embed.Thumbnail = &thumbnail
+
+		var contents []*discordgo.MessageEmbedField
+		for _, name := range names {
+			contents = append(contents, createField(guildPrefix+name, commandList[name].description, false))
+		}
+		embed.Fields = contents
+		pages = append(pages, &embed)
+	}
+
+	// self-credit + github profile picture
+	for i, page := range pages {
+		var footer discordgo.MessageEmbedFooter
+		footer.Text = fmt.Sprintf("Page %d of %d • Created by Charles Zawacki; Written in Go", i+1, len(pages))
+		footer.IconURL = "https://avatars0.githubusercontent.com/u/44577941?s=460&u=4eb7b9ff5410be189eea9863c33916c805dbd2b2&v=4"
+		page.Footer = &footer
+	}
+	return pages
+}
+
+/**
+Builds the detailed explanation of a single command.
+*/
+func commandHelp(guildPrefix string, name string) *discordgo.MessageEmbed {
+	cmd := commandList[name]
+
+	var embed discordgo.MessageEmbed
+	embed.Type = "rich"
+	embed.Title = guildPrefix + name
+	embed.Description = cmd.description
+
+	var contents []*discordgo.MessageEmbedField
+	contents = append(contents, createField("Usage", "```\n"+strings.Join(usageLines(guildPrefix, name), "\n")+"\n```", false))
+	if len(cmd.examples) > 0 {
+		var examples []string
+		for _, example := range cmd.examples {
+			examples = append(examples, "`"+strings.ReplaceAll(example, defaultPrefix, guildPrefix)+"`")
+		}
+		contents = append(contents, createField("Examples", strings.Join(examples, "\n"), false))
+	}
+	if len(cmd.aliases) > 0 {
+		var aliases []string
+		for _, alias := range cmd.aliases {
+			aliases = append(aliases, "`"+guildPrefix+alias+"`")
+		}
+		contents = append(contents, createField("Aliases", strings.Join(aliases, ", "), false))
+	}
+	contents = append(contents, createField("Category", cmd.category, true))
+	var requires []string
+	if cmd.permission != 0 {
+		requires = append(requires, permissionNames[cmd.permission])
+	}
+	if cmd.othersPermission != 0 {
+		requires = append(requires, permissionNames[cmd.othersPermission]+" for others")
+	}
+	var subcommands []string
+	for subcommand := range cmd.subcommandPermissions {
+		subcommands = append(subcommands, subcommand)
+	}
+	sort.Strings(subcommands)
+	for _, subcommand := range subcommands {
+		requires = append(requires, permissionNames[cmd.subcommandPermissions[subcommand]]+" for `"+subcommand+"`")
+	}
+	if len(requires) > 0 {
+		contents = append(contents, createField("Requires", strings.Join(requires, "\n"), true))
+	}
+	embed.Fields = contents
+	return &embed
+}
+
+/**
+Lists the commands the user can use by category, or explains a single command in detail.
+*/
+func handleHelp(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	guildPrefix := getGuildPrefix(m.GuildID)
+
+	access := accessFor(s, m)
+
+	if requested, ok := args.Values["command"]; ok {
+		name := resolveCommand(strings.TrimPrefix(requested, guildPrefix))
+		if _, exists := commandList[name]; !exists || !access.canUse(name) {
+			attemptSendMsg(s, m, fmt.Sprintf("There is no command called `%s`. Use `%shelp` to see every command.", requested, guildPrefix))
+			return
+		}
+		_, err := sendEmbed(s, m, commandHelp(guildPrefix, name))
+		if err != nil {
+			logError("Unable to send message! " + err.Error())
+		}
+		return
+	}
+
+	_, err := sendPaginated(s, m, helpPages(guildPrefix, access))
+	if err != nil {
+		logError("Unable to send message! " + err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that ~help is generated from commandList and only shows commands
+the caller is allowed to use.
+**/
+func TestHelp(t *testing.T) {
+	initCommandInfo()
+
+	t.Run("every command is documented and categorized", func(t *testing.T) {
+		for name, cmd := range commandList {
+			if cmd.description == "" || len(cmd.description) > 100 {
+				t.Logf("%s needs a description of at most 100 characters", name)
+				t.Fail()
+			}
+			found := false
+			for _, category := range helpCategories {
+				if cmd.category == category {
+					found = true
+				}
+			}
+			if !found {
+				t.Logf("%s has unknown category '%s'", name, cmd.category)
+				t.Fail()
+			}
+			permissions := []int64{cmd.permission, cmd.othersPermission}
+			for _, permission := range cmd.subcommandPermissions {
+				permissions = append(permissions, permission)
+			}
+			for _, permission := range permissions {
+				if permission != 0 && permissionNames[permission] == "" {
+					t.Logf("%s requires a permission with no display name", name)
+					t.Fail()
+				}
+			}
+		}
+	})
+
+	t.Run("commands are hidden without the required permission", func(t *testing.T) {
+		visible := visibleCommands(commandAccess{})
+		for _, name := range visible["Moderation"] {
+			if commandList[name].permission != 0 {
+				t.Logf("%s was shown to a user without permissions", name)
+				t.Fail()
+			}
+		}
+		if _, ok := visible["Voice"]; ok {
+			t.Logf("Voice commands were shown to a user without permissions")
+			t.Fail()
+		}
+
+		visible = visibleCommands(commandAccess{perms: discordgo.PermissionKickMembers})
+		if !strings.Contains(strings.Join(visible["Moderation"], " "), "kick") {
+			t.Logf("kick was hidden from a user who can kick members")
+			t.Fail()
+		}
+
+		if len(helpPages("~", commandAccess{perms: discordgo.PermissionAdministrator})) != len(helpCategories)-1 {
+			t.Logf("Administrators should see every category except the owner commands")
+			t.Fail()
+		}
+		if _, ok := visibleCommands(commandAccess{owner: true})["Owner"]; !ok {
+			t.Logf("Owners should see the owner commands")
+			t.Fail()
+		}
+	})
+
+	t.Run("detailed help uses the guild's prefix", func(t *testing.T) {
+		embed := commandHelp("!", "greeter")
+		if embed.Title != "!greeter" {
+			t.Logf("Unexpected title %s", embed.Title)
+			t.Fail()
+		}
+		usage := embed.Fields[0].Value
+		if !strings.Contains(usage, "!greeter set <join|leave> <channel> <message...> [-img <img>]") {
+			t.Logf("Usage was not generated from the argument specs: %s", usage)
+			t.Fail()
+		}
+		if !strings.Contains(embed.Fields[1].Value, "`!greeter reset leave`") {
+			t.Logf("Examples did not use the guild's prefix: %s", embed.Fields[1].Value)
+			t.Fail()
+		}
+	})
+}
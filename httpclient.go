@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// every outbound request goes through this client, so tests can replace its transport
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// base URLs of the services the bot scrapes or calls, without a trailing slash
+var (
+	dbdWikiURL         = "https://deadbydaylight.gamepedia.com"
+	shrineURL          = "https://raw.githubusercontent.com/cazwacki/periodic-dbd-data/master/shrine.json"
+	googleURL          = "https://www.google.com"
+	customSearchURL    = "https://customsearch.googleapis.com"
+	urbanDictionaryURL = "https://mashape-community-urban-dictionary.p.rapidapi.com"
+	linguaURL          = "https://lingua-robot.p.rapidapi.com"
+	wikipediaURL       = "https://en.wikipedia.org"
+)
+
+// query parameters that hold secrets; they are left out of fixtures
+var secretParams = []string{"key"}
+
+/**
+Reads the base URLs from the configuration, and sets up recording or replaying
+fixtures if HTTP_FIXTURES is "record" or "replay". The URLs are only read at
+startup.
+*/
+func loadHTTPConfig() {
+	urls := currentConfig().URLs
+	dbdWikiURL = strings.TrimSuffix(urls.DBDWiki, "/")
+	shrineURL = urls.Shrine
+	googleURL = strings.TrimSuffix(urls.Google, "/")
+	customSearchURL = strings.TrimSuffix(urls.CustomSearch, "/")
+	urbanDictionaryURL = strings.TrimSuffix(urls.UrbanDictionary, "/")
+	linguaURL = strings.TrimSuffix(urls.Lingua, "/")
+	wikipediaURL = strings.TrimSuffix(urls.Wikipedia, "/")
+
+	mode := os.Getenv("HTTP_FIXTURES")
+	if mode != "" {
+		dir := envOr("HTTP_FIXTURES_DIR", filepath.Join("testdata", "fixtures"))
+		httpClient.Transport = &fixtureTransport{dir: dir, record: mode == "record", next: httpClient.Transport}
+		logWarning("HTTP requests are using fixtures in " + dir + " (" + mode + ")")
+	}
+}
+
+/****
+FIXTURES
+****/
+
+/**
+A response saved to disk, so scrapers can be tested offline.
+*/
+type fixture struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+/**
+Answers requests from fixtures in dir. When recording, requests are passed on
+to next and the responses are saved first.
+*/
+type fixtureTransport struct {
+	dir    string
+	record bool
+	next   http.RoundTripper
+}
+
+var unsafePathCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+/**
+Returns the URL without secret query parameters.
+*/
+func publicURL(requestURL *url.URL) string {
+	public := *requestURL
+	query := public.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+	public.RawQuery = query.Encode()
+	return public.String()
+}
+
+/**
+Returns the file a request's fixture is stored in: one directory per host, named
+after the path, with a hash of the query if there is one.
+*/
+func (transport *fixtureTransport) path(requestURL *url.URL) string {
+	name := strings.Trim(unsafePathCharacters.ReplaceAllString(requestURL.Path, "_"), "_")
+	if name == "" {
+		name = "index"
+	}
+	query := requestURL.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+	if len(query) > 0 {
+		hash := sha1.Sum([]byte(query.Encode()))
+		name += "-" + hex.EncodeToString(hash[:4])
+	}
+	return filepath.Join(transport.dir, requestURL.Hostname(), name+".json")
+}
+
+func (transport *fixtureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	path := transport.path(r.URL)
+	if transport.record {
+		return transport.save(r, path)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("no fixture for " + publicURL(r.URL) + " at " + path)
+	}
+	var saved fixture
+	err = json.Unmarshal(contents, &saved)
+	if err != nil {
+		return nil, errors.New("unreadable fixture " + path + ": " + err.Error())
+	}
+	return &http.Response{
+		Status:        http.StatusText(saved.Status),
+		StatusCode:    saved.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{saved.ContentType}},
+		Body:          ioutil.NopCloser(strings.NewReader(saved.Body)),
+		ContentLength: int64(len(saved.Body)),
+		Request:       r,
+	}, nil
+}
+
+/**
+Performs the request and saves its response as a fixture.
+*/
+func (transport *fixtureTransport) save(r *http.Request, path string) (*http.Response, error) {
+	next := transport.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	contents, err := json.MarshalIndent(fixture{
+		URL:         publicURL(r.URL),
+		Status:      res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        string(body),
+	}, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(path, contents, 0644)
+	}
+	if err != nil {
+		logError("Unable to save fixture " + path + ": " + err.Error())
+	} else {
+		logInfo("Saved fixture " + path)
+	}
+	return res, nil
+}
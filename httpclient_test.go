@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+Answers the bot's HTTP requests from the fixtures in testdata/fixtures until
+the test ends. Run the tests with HTTP_FIXTURES=record to refresh them from the
+real services.
+**/
+func useFixtures(t *testing.T) {
+	previous := httpClient.Transport
+	httpClient.Transport = &fixtureTransport{
+		dir:    filepath.Join("testdata", "fixtures"),
+		record: os.Getenv("HTTP_FIXTURES") == "record",
+		next:   previous,
+	}
+	t.Cleanup(func() { httpClient.Transport = previous })
+}
+
+/**
+Test that responses are recorded as fixtures and replayed without the server.
+**/
+func TestFixtures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<h1>" + r.URL.Query().Get("q") + "</h1>"))
+	}))
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatalf("Unable to create a directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	get := func(transport *fixtureTransport, url string) (string, error) {
+		client := &http.Client{Transport: transport}
+		res, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		return res.Header.Get("Content-Type") + " " + string(body), err
+	}
+
+	t.Run("recorded responses are replayed", func(t *testing.T) {
+		recorded, err := get(&fixtureTransport{dir: dir, record: true}, server.URL+"/search?q=gecko&key=secret")
+		if err != nil || recorded != "text/html <h1>gecko</h1>" {
+			t.Fatalf("Unexpected recorded response '%s' (%v)", recorded, err)
+		}
+		server.Close()
+
+		// the key isn't part of the fixture, so replaying doesn't need it
+		replayed, err := get(&fixtureTransport{dir: dir}, server.URL+"/search?q=gecko&key=other")
+		if err != nil || replayed != recorded {
+			t.Logf("Expected the recorded response, got '%s' (%v)", replayed, err)
+			t.Fail()
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+		for _, file := range files {
+			contents, _ := ioutil.ReadFile(file)
+			if strings.Contains(string(contents), "secret") {
+				t.Logf("%s contains the API key", file)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("missing fixtures are an error", func(t *testing.T) {
+		_, err := get(&fixtureTransport{dir: dir}, server.URL+"/search?q=newt")
+		if err == nil || !strings.Contains(err.Error(), "no fixture") {
+			t.Logf("Expected a missing fixture error, got %v", err)
+			t.Fail()
+		}
+	})
+}
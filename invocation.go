@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Describes a single invocation of a command, regardless of whether it came from a
+prefixed message or a slash command. Handlers read everything they need about the
+caller from here so the same logic serves both entry points.
+*/
+type Invocation struct {
+	ID          string // ID of the invoking message; empty for slash commands
+	ChannelID   string
+	GuildID     string
+	Content     string
+	Author      *discordgo.User
+	Member      *discordgo.Member
+	Interaction *discordgo.Interaction
+
+	lock      sync.Mutex
+	responded bool
+	outcome   string // how the command went, for metrics; empty until something fails
+}
+
+/**
+Records how the invocation went, e.g. the type of error it failed with.
+*/
+func (m *Invocation) setOutcome(outcome string) {
+	m.lock.Lock()
+	m.outcome = outcome
+	m.lock.Unlock()
+}
+
+/**
+Counts the finished invocation in the command metrics.
+*/
+func recordInvocation(m *Invocation, command string) {
+	m.lock.Lock()
+	outcome := m.outcome
+	m.lock.Unlock()
+	if outcome == "" {
+		outcome = "ok"
+	}
+	commandsTotal.WithLabelValues(command, outcome).Inc()
+}
+
+/**
+Builds an invocation from a message that was sent in a channel.
+*/
+func invocationFromMessage(m *discordgo.MessageCreate) *Invocation {
+	return &Invocation{
+		ID:        m.ID,
+		ChannelID: m.ChannelID,
+		GuildID:   m.GuildID,
+		Content:   m.Content,
+		Author:    m.Author,
+		Member:    m.Member,
+	}
+}
+
+/**
+Builds an invocation from a slash command interaction.
+*/
+func invocationFromInteraction(i *discordgo.InteractionCreate) *Invocation {
+	invocation := &Invocation{
+		ChannelID:   i.ChannelID,
+		GuildID:     i.GuildID,
+		Member:      i.Member,
+		Author:      i.User,
+		Interaction: i.Interaction,
+	}
+	if i.Member != nil {
+		invocation.Author = i.Member.User
+	}
+	return invocation
+}
+
+/**
+Sends a message back to wherever the command was invoked. Text that is too long
+is split into several messages, and failures that might pass are retried. If
+the message still can't be sent, the user is told. Returns the first message
+that was sent.
+*/
+func respond(s *discordgo.Session, m *Invocation, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	var first *discordgo.Message
+	for i, part := range splitMessage(data) {
+		message, err := withRetries(func() (*discordgo.Message, error) {
+			return respondOnce(s, m, part)
+		})
+		if err != nil {
+			reportSendFailure(s, m, err)
+			return first, err
+		}
+		if i == 0 {
+			first = message
+		}
+	}
+	return first, nil
+}
+
+/**
+Makes a single attempt at sending a message that fits in one. For slash
+commands, the first message fills in the deferred response and any later ones
+are sent as followups.
+*/
+func respondOnce(s *discordgo.Session, m *Invocation, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if m.Interaction == nil {
+		return s.ChannelMessageSendComplex(m.ChannelID, data)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.responded {
+		// unset fields are left as they are, like a message that was never sent
+		edit := &discordgo.WebhookEdit{}
+		if data.Content != "" {
+			edit.Content = &data.Content
+		}
+		if len(data.Embeds) > 0 {
+			edit.Embeds = &data.Embeds
+		}
+		if len(data.Components) > 0 {
+			edit.Components = &data.Components
+		}
+		message, err := s.InteractionResponseEdit(m.Interaction, edit)
+		// a failed edit can be retried; the response is still waiting to be filled in
+		m.responded = err == nil
+		return message, err
+	}
+	return s.FollowupMessageCreate(m.Interaction, true, &discordgo.WebhookParams{
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+	})
+}
+
+/**
+Sends an embed back to wherever the command was invoked. An embed too big for
+one message is paginated.
+*/
+func sendEmbed(s *discordgo.Session, m *Invocation, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	pages := splitEmbed(embed)
+	if len(pages) > 1 {
+		return sendPaginated(s, m, pages)
+	}
+	return respond(s, m, &discordgo.MessageSend{Embeds: pages})
+}
+
+/**
+Reacts to the invoking message. Slash commands have no message to react to, so
+this does nothing for them.
+*/
+func reactToInvocation(s *discordgo.Session, m *Invocation, emoji string) {
+	if m.Interaction != nil {
+		return
+	}
+	err := s.MessageReactionAdd(m.ChannelID, m.ID, emoji)
+	if err != nil {
+		logError("Failed to react to command; " + err.Error())
+	}
+}
+
+/**
+Cleans up after a handler has returned. A deferred slash command response that
+was never filled in would otherwise show "thinking..." until it expires.
+*/
+func finishInvocation(s *discordgo.Session, m *Invocation) {
+	if m.Interaction == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.responded {
+		m.responded = true
+		err := s.InteractionResponseDelete(m.Interaction)
+		if err != nil {
+			logWarning("Failed to delete unused interaction response; " + err.Error())
+		}
+	}
+}
+
+/**
+Parses and validates the arguments of the requested command, then runs its
+handler. Both the prefix router and the slash command router end up here.
+*/
+func dispatchCommand(s *discordgo.Session, m *Invocation, invokeWord string) {
+	invokeWord = resolveCommand(invokeWord)
+	validCommand, ok := commandList[invokeWord]
+	if !ok {
+		return
+	}
+	defer recordInvocation(m, invokeWord)
+
+	// the config command is always available so a server can't lock itself out
+	if invokeWord != "config" && !commandEnabled(m.GuildID, m.ChannelID, invokeWord) {
+		logWith(LevelInfo, invocationFields(m, invokeWord), "Command is disabled in this channel")
+		m.setOutcome("disabled")
+		if m.Interaction != nil {
+			attemptSendMsg(s, m, "That command is disabled in this channel.")
+		}
+		finishInvocation(s, m)
+		return
+	}
+
+	access := accessFor(s, m)
+	if !access.canUse(invokeWord) {
+		sendError(s, m, invokeWord, Permissions)
+		finishInvocation(s, m)
+		return
+	}
+
+	var args *Args
+	var err error
+	if m.Interaction != nil {
+		args, err = argsFromOptions(validCommand, m.Interaction.ApplicationCommandData().Options)
+	} else {
+		args, err = parseArgs(validCommand, m.Content)
+	}
+	if err != nil {
+		logWith(LevelInfo, invocationFields(m, invokeWord), "Invalid arguments: "+err.Error())
+		sendError(s, m, invokeWord, Syntax)
+		finishInvocation(s, m)
+		return
+	}
+
+	// some uses of a command need more than the command itself
+	permission, ok := validCommand.subcommandPermissions[args.Subcommand]
+	if target := args.Values["user"]; validCommand.othersPermission != 0 && target != "" && target != m.Author.ID {
+		permission, ok = validCommand.othersPermission, true
+	}
+	if ok && !access.allows(invokeWord, permission) {
+		sendError(s, m, invokeWord, Permissions)
+		finishInvocation(s, m)
+		return
+	}
+
+	wait, ok := takeCooldowns(invokeWord, validCommand.cooldowns, m, time.Now())
+	if !ok {
+		logWith(LevelInfo, invocationFields(m, invokeWord), "User is on cooldown")
+		m.setOutcome("cooldown")
+		attemptSendMsg(s, m, fmt.Sprintf("⏳ Slow down! Try `%s%s` again in %s.", getGuildPrefix(m.GuildID), invokeWord, formatRetry(wait)))
+		finishInvocation(s, m)
+		return
+	}
+
+	logWith(LevelDebug, invocationFields(m, invokeWord), "Running command")
+	started := time.Now()
+	validCommand.handle(s, m, args)
+	commandDuration.WithLabelValues(invokeWord).Observe(time.Since(started).Seconds())
+	finishInvocation(s, m)
+}
+
+/**
+Handler function when the discord session receives an interaction. Slash commands
+are acknowledged right away and then dispatched like any other command; clicks
+on message components go to whatever the component belongs to.
+*/
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type == discordgo.InteractionMessageComponent {
+		if strings.HasPrefix(i.MessageComponentData().CustomID, paginatorPrefix) {
+			go navigatePaginator(s, i)
+		}
+		return
+	}
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	// Ignore DMs, same as prefixed commands
+	if i.GuildID == "" || i.Member == nil {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if _, ok := commandList[data.Name]; !ok {
+		logWarning("Received unknown application command " + data.Name)
+		return
+	}
+
+	// acknowledge now; handlers fill in the response when they have one
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		logError("Failed to acknowledge interaction! " + err.Error())
+		return
+	}
+
+	m := invocationFromInteraction(i)
+	m.Content = "/" + data.Name
+	logInfo("Slash command: " + m.Content)
+	go dispatchCommand(s, m, data.Name)
+}
+
+/**
+Registers every command in commandList as a global application command so it can
+be invoked with a slash.
+*/
+func registerApplicationCommands(s *discordgo.Session) {
+	var names []string
+	for name := range commandList {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var applicationCommands []*discordgo.ApplicationCommand
+	for _, name := range names {
+		// slash commands are visible to everyone, so owner commands stay prefix only
+		if commandList[name].ownerOnly {
+			continue
+		}
+		description := commandList[name].description
+		// Discord counts characters, and a split character fails the whole registration
+		if runes := []rune(description); len(runes) > 100 {
+			description = string(runes[:100])
+		}
+		applicationCommands = append(applicationCommands, &discordgo.ApplicationCommand{
+			Name:        name,
+			Description: description,
+			Options:     applicationCommandOptions(commandList[name]),
+		})
+	}
+
+	_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, "", applicationCommands)
+	if err != nil {
+		logError("Failed to register application commands! " + err.Error())
+		return
+	}
+	logSuccess(strconv.Itoa(len(applicationCommands)) + " application commands registered")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type LogLevel int32
+
+const (
+	LevelDebug LogLevel = 0
+	LevelInfo  LogLevel = 1
+	LevelWarn  LogLevel = 2
+	LevelError LogLevel = 3
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// changed at runtime by ~loglevel, so always access it atomically
+var logLevel = int32(LevelInfo)
+var logJSON bool
+var logOutput io.Writer = os.Stdout
+var logOutputLock sync.Mutex
+
+/**
+Extra context attached to a log line, e.g. the guild and command it is about.
+*/
+type LogFields map[string]string
+
+/**
+Parses a level name such as "warn". Returns false if the name isn't a level.
+*/
+func parseLogLevel(name string) (LogLevel, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		name = "warn"
+	}
+	for i, levelName := range logLevelNames {
+		if name == levelName {
+			return LogLevel(i), true
+		}
+	}
+	return LevelInfo, false
+}
+
+func (level LogLevel) String() string {
+	return logLevelNames[level]
+}
+
+func getLogLevel() LogLevel {
+	return LogLevel(atomic.LoadInt32(&logLevel))
+}
+
+func setLogLevel(level LogLevel) {
+	atomic.StoreInt32(&logLevel, int32(level))
+}
+
+/**
+Configures the logger from the configured level (debug, info, warn or error)
+and format (logfmt or json).
+*/
+func configureLogging() {
+	settings := currentConfig().Logging
+	level, _ := parseLogLevel(settings.Level)
+	setLogLevel(level)
+	logJSON = strings.EqualFold(settings.Format, "json")
+}
+
+/**
+Returns the fields that describe a command invocation.
+*/
+func invocationFields(m *Invocation, command string) LogFields {
+	fields := LogFields{"guild": m.GuildID, "channel": m.ChannelID, "command": command}
+	if m.Author != nil {
+		fields["user"] = m.Author.ID
+	}
+	return fields
+}
+
+/**
+Formats a log line as logfmt or JSON.
+*/
+func formatLogLine(now time.Time, level string, caller string, fields LogFields, message string) string {
+	if logJSON {
+		entry := map[string]string{"time": now.Format(time.RFC3339), "level": level, "caller": caller, "msg": message}
+		for key, value := range fields {
+			entry[key] = value
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Sprintf(`{"level":"error","msg":%q}`, "Failed to format log line: "+err.Error())
+		}
+		return string(line)
+	}
+
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	line := "time=" + now.Format(time.RFC3339) + " level=" + level + " caller=" + caller
+	for _, key := range keys {
+		line += " " + key + "=" + logfmtValue(fields[key])
+	}
+	return line + " msg=" + logfmtValue(message)
+}
+
+/**
+Quotes a logfmt value if it has spaces, quotes or equals signs in it.
+*/
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+/**
+Writes a log line if the level is enabled. Depth is the number of calls between
+the function being annotated and this one.
+*/
+func writeLog(level LogLevel, label string, fields LogFields, message string, depth int) {
+	if level < getLogLevel() {
+		return
+	}
+	caller := "unknown"
+	pc, _, _, ok := runtime.Caller(depth + 1)
+	if ok {
+		caller = runtime.FuncForPC(pc).Name()
+	}
+
+	line := formatLogLine(time.Now(), label, caller, fields, message)
+	logOutputLock.Lock()
+	fmt.Fprintln(logOutput, line)
+	logOutputLock.Unlock()
+}
+
+/**
+Prints a debug log to the console if the log level allows it.
+*/
+func logDebug(message string) {
+	writeLog(LevelDebug, "debug", nil, message, 1)
+}
+
+/**
+Prints an info log to the console if the log level allows it.
+*/
+func logInfo(message string) {
+	writeLog(LevelInfo, "info", nil, message, 1)
+}
+
+/**
+Prints a warning log to the console if the log level allows it.
+*/
+func logWarning(message string) {
+	writeLog(LevelWarn, "warn", nil, message, 1)
+}
+
+/**
+Prints an error log to the console.
+*/
+func logError(message string) {
+	writeLog(LevelError, "error", nil, message, 1)
+}
+
+/**
+Prints a success log to the console if the log level allows it. Successes are
+info logs marked with status=ok.
+*/
+func logSuccess(message string) {
+	writeLog(LevelInfo, "info", LogFields{"status": "ok"}, message, 1)
+}
+
+/**
+Same as the helpers above, but with fields attached to the line.
+*/
+func logWith(level LogLevel, fields LogFields, message string) {
+	writeLog(level, level.String(), fields, message, 1)
+}
+
+/**
+Shows or changes how much the bot logs.
+**/
+func handleLogLevel(s *discordgo.Session, m *Invocation, args *Args) {
+	name, ok := args.Values["level"]
+	if !ok {
+		attemptSendMsg(s, m, fmt.Sprintf("The log level is `%s`.", getLogLevel()))
+		return
+	}
+	level, _ := parseLogLevel(name)
+	setLogLevel(level)
+	logWith(LevelWarn, invocationFields(m, "loglevel"), "Log level changed to "+level.String())
+	sendSuccess(s, m, fmt.Sprintf("The log level is now `%s`.", level))
+}
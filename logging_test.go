@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+Test that log lines are filtered by level, carry their fields and keep the
+caller annotation.
+**/
+func TestLogging(t *testing.T) {
+	var buffer bytes.Buffer
+	previousOutput := logOutput
+	logOutput = &buffer
+	defer func() {
+		logOutput = previousOutput
+		setLogLevel(LevelInfo)
+		logJSON = false
+	}()
+
+	t.Run("level names are parsed", func(t *testing.T) {
+		if level, ok := parseLogLevel(" WARNING "); !ok || level != LevelWarn {
+			t.Logf("Expected WARNING to parse as warn, got %s", level)
+			t.Fail()
+		}
+		if _, ok := parseLogLevel("verbose"); ok {
+			t.Logf("verbose is not a log level")
+			t.Fail()
+		}
+	})
+
+	t.Run("lines below the log level are dropped", func(t *testing.T) {
+		buffer.Reset()
+		setLogLevel(LevelWarn)
+		logInfo("hidden")
+		logWarning("shown")
+		if strings.Contains(buffer.String(), "hidden") || !strings.Contains(buffer.String(), "msg=shown") {
+			t.Logf("Unexpected output: %s", buffer.String())
+			t.Fail()
+		}
+		if !strings.Contains(buffer.String(), "caller=") || !strings.Contains(buffer.String(), "TestLogging") {
+			t.Logf("The caller annotation is missing: %s", buffer.String())
+			t.Fail()
+		}
+	})
+
+	t.Run("logfmt quotes values that need it", func(t *testing.T) {
+		line := formatLogLine(time.Unix(0, 0).UTC(), "info", "main.f", LogFields{"user": "1", "guild": "2"}, "Running command")
+		expected := `time=1970-01-01T00:00:00Z level=info caller=main.f guild=2 user=1 msg="Running command"`
+		if line != expected {
+			t.Logf("Expected %s, got %s", expected, line)
+			t.Fail()
+		}
+	})
+
+	t.Run("json lines include the fields", func(t *testing.T) {
+		logJSON = true
+		line := formatLogLine(time.Unix(0, 0).UTC(), "warn", "main.f", LogFields{"command": "kick"}, "Command failed")
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Logf("Invalid JSON %s: %s", line, err.Error())
+			t.FailNow()
+		}
+		if entry["command"] != "kick" || entry["level"] != "warn" || entry["msg"] != "Command failed" {
+			t.Logf("Unexpected entry %v", entry)
+			t.Fail()
+		}
+	})
+}
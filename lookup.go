@@ -83,12 +83,10 @@ type GoogleResult struct {
 	ResultTitle string
 }
 
-// ImageSet : holds 10 images and the associated message of an image query
+// ImageSet : holds the images found for an image query
 type ImageSet struct {
-	Query   string
-	Message *discordgo.Message
-	Images  []string
-	Index   int
+	Query  string
+	Images []string
 }
 
 /**
@@ -99,7 +97,7 @@ func fetchResults(query string, resultCount int) []GoogleResult {
 
 	// Request the HTML page.
 	query = url.QueryEscape(query)
-	doc := loadPage(fmt.Sprintf("https://www.google.com/search?q=%s&num=100&hl=en", query))
+	doc := loadPage(fmt.Sprintf("%s/search?q=%s&num=100&hl=en", googleURL, query))
 
 	if doc == nil {
 		return results
@@ -133,7 +131,7 @@ func fetchUrbanDefinitions(query string) UrbanResults {
 	var urbanDefinitions UrbanResults
 
 	// fetch response from lingua robot API
-	url := "https://mashape-community-urban-dictionary.p.rapidapi.com/define?term=" + query
+	url := urbanDictionaryURL + "/define?term=" + query
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -144,9 +142,9 @@ func fetchUrbanDefinitions(query string) UrbanResults {
 	req.Header.Add("x-rapidapi-key", os.Getenv("URBAN_DICTIONARY_API_KEY"))
 	req.Header.Add("x-rapidapi-host", "mashape-community-urban-dictionary.p.rapidapi.com")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Println("Error performing request! " + err.Error())
+		logError("Error performing request! " + err.Error())
 		return urbanDefinitions
 	}
 
@@ -154,7 +152,7 @@ func fetchUrbanDefinitions(query string) UrbanResults {
 	body, err := ioutil.ReadAll(res.Body)
 
 	if err != nil {
-		fmt.Println("Error reading response! " + err.Error())
+		logError("Error reading response! " + err.Error())
 		return urbanDefinitions
 	}
 
@@ -173,7 +171,7 @@ func fetchDefinitions(query string) DictResults {
 	var definitions DictResults
 
 	// fetch response from lingua robot API
-	url := "https://lingua-robot.p.rapidapi.com/language/v1/entries/en/" + query
+	url := linguaURL + "/language/v1/entries/en/" + query
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -184,7 +182,7 @@ func fetchDefinitions(query string) DictResults {
 	req.Header.Add("x-rapidapi-key", os.Getenv("LINGUA_API_KEY"))
 	req.Header.Add("x-rapidapi-host", "lingua-robot.p.rapidapi.com")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		logError("Error making request! " + err.Error())
 		return definitions
@@ -210,15 +208,16 @@ Uses Google CustomSearch API to generate and return 10 images.
 func fetchImage(query string) ImageSet {
 	logInfo("Query: '" + query + "'")
 	var newset ImageSet
-	client := &http.Client{Transport: &transport.APIKey{Key: os.Getenv("GOOGLE_API_KEY")}}
+	client := &http.Client{Transport: &transport.APIKey{Key: os.Getenv("GOOGLE_API_KEY"), Transport: httpClient.Transport}, Timeout: httpClient.Timeout}
 
 	svc, err := customsearch.New(client)
 	if err != nil {
 		logError("Failed to initialize customsearch client! " + err.Error())
 		return newset
 	}
+	svc.BasePath = customSearchURL + "/"
 
-	resp, err := svc.Cse.List().Cx("007244931007990492385:f42b7zsrt0k").SearchType("image").Q(query).Do()
+	resp, err := svc.Cse.List().Cx(currentConfig().Search.CustomSearchCX).SearchType("image").Q(query).Do()
 	if err != nil {
 		logError("Failed to pull images from Google CustomSearch API! " + err.Error())
 		return newset
@@ -229,7 +228,6 @@ func fetchImage(query string) ImageSet {
 	}
 
 	newset.Query = query
-	newset.Index = 0
 
 	return newset
 }
@@ -239,7 +237,7 @@ func fetchArticle(query string) Article {
 	var article Article
 
 	// fetch response from lingua robot API
-	url := "https://en.wikipedia.org/api/rest_v1/page/summary/" + query
+	url := wikipediaURL + "/api/rest_v1/page/summary/" + query
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -247,7 +245,7 @@ func fetchArticle(query string) Article {
 		return article
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		logError("Failed to perform GET request! " + err.Error())
 		return article
@@ -270,15 +268,11 @@ func fetchArticle(query string) Article {
 /**
 Takes a passed in time and uses the Discord embed timestamp feature to convert it to a local time.
 */
-func handleConvert(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	if len(command) != 3 {
-		sendError(s, m, "convert", Syntax)
-		return
-	}
+func handleConvert(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
-	cmdTime := command[1]
-	cmdTimezone := command[2]
+	cmdTime := args.Values["time"]
+	cmdTimezone := args.Values["timezone"]
 
 	cmdTime = strings.ToUpper(cmdTime)
 
@@ -332,7 +326,7 @@ func handleConvert(s *discordgo.Session, m *discordgo.MessageCreate, command []s
 	var footer discordgo.MessageEmbedFooter
 	footer.Text = "...this in your local time:"
 	embed.Footer = &footer
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err = sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
@@ -342,15 +336,10 @@ func handleConvert(s *discordgo.Session, m *discordgo.MessageCreate, command []s
 /**
 Handles a word using the Urban Dictionary and sends the definition(s) back to the channel.
 */
-func handleUrban(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	// was the command invoked correctly?
-	if len(command) == 1 {
-		sendError(s, m, "urban", Syntax)
-		return
-	}
+func handleUrban(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
-	query := url.QueryEscape(strings.Join(command[1:], " "))
+	query := url.QueryEscape(args.Values["query"])
 	terms := fetchUrbanDefinitions(query)
 
 	// did the API return any definition?
@@ -362,7 +351,7 @@ func handleUrban(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 	// construct embed response
 	var embed discordgo.MessageEmbed
 	embed.Type = "rich"
-	embed.Title = "Urban Definitions for \"" + strings.Join(command[1:], " ") + "\""
+	embed.Title = "Urban Definitions for \"" + args.Values["query"] + "\""
 
 	definitionCount := len(terms.UrbanEntries)
 	if definitionCount > 5 {
@@ -374,10 +363,7 @@ func handleUrban(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 		currentEntry := terms.UrbanEntries[i]
 		cleanedDefinition := strings.ReplaceAll(currentEntry.Definition, "[", "")
 		cleanedDefinition = strings.ReplaceAll(cleanedDefinition, "]", "")
-		newDescription := fmt.Sprintf("**[%d. +%d, -%d](%s)**\n%s\n\n", (i + 1), currentEntry.ThumbsUp, currentEntry.ThumbsDown, currentEntry.Permalink, cleanedDefinition)
-		if len(descriptions)+len(newDescription) <= 2048 {
-			descriptions += newDescription
-		}
+		descriptions += fmt.Sprintf("**[%d. +%d, -%d](%s)**\n%s\n\n", (i + 1), currentEntry.ThumbsUp, currentEntry.ThumbsDown, currentEntry.Permalink, cleanedDefinition)
 	}
 	embed.Description = descriptions
 	var footer discordgo.MessageEmbedFooter
@@ -386,7 +372,7 @@ func handleUrban(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 	embed.Footer = &footer
 
 	// send response
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
@@ -396,15 +382,10 @@ func handleUrban(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 /**
 Defines a word using the Cambridge dictionary and sends the definition back to the channel.
 */
-func handleDefine(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	// was the command invoked correctly?
-	if len(command) == 1 {
-		sendError(s, m, "define", Syntax)
-		return
-	}
+func handleDefine(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
-	query := url.QueryEscape(strings.Join(command[1:], "-"))
+	query := url.QueryEscape(strings.Join(strings.Fields(args.Values["query"]), "-"))
 	terms := fetchDefinitions(query)
 
 	// did the API return any definition?
@@ -416,7 +397,7 @@ func handleDefine(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	// construct embed response
 	var embed discordgo.MessageEmbed
 	embed.Type = "rich"
-	embed.Title = "Definitions for \"" + strings.Join(command[1:], " ") + "\""
+	embed.Title = "Definitions for \"" + args.Values["query"] + "\""
 	var fields []*discordgo.MessageEmbedField
 	for _, entry := range terms.Entries {
 		for _, definition := range entry.Definitions {
@@ -426,12 +407,7 @@ func handleDefine(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 				if len(sense.Labels) != 0 {
 					labels += "(" + strings.Join(sense.Labels, ", ") + ")"
 				}
-				newDescription := fmt.Sprintf("`%d. %s`\n %s\n\n", index+1, labels, sense.Definition)
-				if len(descriptions)+len(newDescription) <= 1024 {
-					descriptions += newDescription
-				} else {
-					logWarning("Omitting definition due to description length exceeding capacity")
-				}
+				descriptions += fmt.Sprintf("`%d. %s`\n %s\n\n", index+1, labels, sense.Definition)
 			}
 			logInfo("Added definition set")
 			fields = append(fields, createField(definition.PartOfSpeech, descriptions, false))
@@ -444,7 +420,7 @@ func handleDefine(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	embed.Footer = &footer
 
 	// send response
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
@@ -454,14 +430,9 @@ func handleDefine(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 /**
 Sends the first five search results for the query input by the user
 */
-func handleGoogle(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	// was the command invoked correctly?
-	if len(command) == 1 {
-		sendError(s, m, "google", Syntax)
-		return
-	}
-	results := fetchResults(strings.Join(command[1:], " "), 5)
+func handleGoogle(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	results := fetchResults(args.Values["query"], 5)
 
 	// did any results come in?
 	if len(results) == 0 {
@@ -472,9 +443,9 @@ func handleGoogle(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 
 	// construct embed response
 	var embed discordgo.MessageEmbed
-	embed.URL = fmt.Sprintf("https://www.google.com/search?q=%s&num=100&hl=en", url.QueryEscape(strings.Join(command[1:], " ")))
+	embed.URL = fmt.Sprintf("https://www.google.com/search?q=%s&num=100&hl=en", url.QueryEscape(args.Values["query"]))
 	embed.Type = "rich"
-	embed.Title = "Search Results for \"" + strings.Join(command[1:], " ") + "\""
+	embed.Title = "Search Results for \"" + args.Values["query"] + "\""
 	resultString := ""
 	for i, result := range results {
 		logInfo(fmt.Sprintf("result.ResultURL = %s\n", result.ResultURL))
@@ -486,7 +457,7 @@ func handleGoogle(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	footer.IconURL = "https://cdn4.iconfinder.com/data/icons/new-google-logo-2015/400/new-google-favicon-512.png"
 	embed.Footer = &footer
 	// send response
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
@@ -494,17 +465,11 @@ func handleGoogle(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 }
 
 /**
-Creates and populates an ImageSet to be added to the globalImageSet. Sends the image
-to the channel with emotes that can be used to scroll between images.
+Searches for images and sends them to the channel as a paginated message that can
+be used to scroll between images.
 */
-func handleImage(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	// did the user format the command correctly?
-	if len(command) == 1 {
-		attemptSendMsg(s, m, "Usage: `~image <word / phrase>`")
-		return
-	}
-
-	result := fetchImage(strings.Join(command[1:], " "))
+func handleImage(s *discordgo.Session, m *Invocation, args *Args) {
+	result := fetchImage(args.Values["query"])
 
 	// did the search engine return anything?
 	if len(result.Images) == 0 {
@@ -513,64 +478,51 @@ func handleImage(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 		return
 	}
 
-	// craft response and send
-	var embed discordgo.MessageEmbed
-	embed.Type = "rich"
-	embed.Title = "Image Results for \"" + strings.Join(command[1:], " ") + "\""
-	var image discordgo.MessageEmbedImage
-	image.URL = result.Images[0]
-	embed.Image = &image
-	var footer discordgo.MessageEmbedFooter
-	footer.Text = fmt.Sprintf("Image 1 of %d", len(result.Images))
-	footer.IconURL = "https://cdn4.iconfinder.com/data/icons/new-google-logo-2015/400/new-google-favicon-512.png"
-	embed.Footer = &footer
-	message, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendPaginated(s, m, imagePages(args.Values["query"], result.Images))
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
-		return
 	}
+}
 
-	result.Message = message
-	go appendToGlobalImageSet(s, result)
-
-	err = s.MessageReactionAdd(m.ChannelID, result.Message.ID, "⬅️")
-	if err != nil {
-		logError("Failed to add reaction to message! " + err.Error())
-		return
-	}
-	err = s.MessageReactionAdd(m.ChannelID, result.Message.ID, "➡️")
-	if err != nil {
-		logError("Failed to add reaction to message! " + err.Error())
-		return
-	}
-	err = s.MessageReactionAdd(m.ChannelID, result.Message.ID, "⏹️")
-	if err != nil {
-		logError("Failed to add reaction to message! " + err.Error())
-		return
-	}
+/**
+Makes a page for each image found for the query.
+*/
+func imagePages(query string, images []string) []*discordgo.MessageEmbed {
+	var pages []*discordgo.MessageEmbed
+	for i, link := range images {
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = "Image Results for \"" + query + "\""
+		var image discordgo.MessageEmbedImage
+		image.URL = link
+		embed.Image = &image
+		var footer discordgo.MessageEmbedFooter
+		footer.Text = fmt.Sprintf("Image %d of %d", i+1, len(images))
+		footer.IconURL = "https://cdn4.iconfinder.com/data/icons/new-google-logo-2015/400/new-google-favicon-512.png"
+		embed.Footer = &footer
+		pages = append(pages, &embed)
+	}
+	return pages
 }
 
-func handleWiki(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if len(command) == 1 {
-		attemptSendMsg(s, m, "Usage: `~wiki <word / phrase>`")
-		return
-	}
-	query := strings.Join(command[1:], "_")
+func handleWiki(s *discordgo.Session, m *Invocation, args *Args) {
+	words := strings.Fields(args.Values["query"])
+	query := strings.Join(words, "_")
 	page := fetchArticle(query)
 
 	// if there is no article, try again with smart capitalization
 	if page.URLs == nil {
 		specialWords := " in the of for from "
-		for i := 1; i < len(command); i++ {
-			if strings.Contains(specialWords, command[i]) {
-				command[i] = strings.ToLower(command[i])
+		for i := 0; i < len(words); i++ {
+			if strings.Contains(specialWords, words[i]) {
+				words[i] = strings.ToLower(words[i])
 			} else {
-				tmp := []rune(command[i])
+				tmp := []rune(words[i])
 				tmp[0] = unicode.ToUpper(tmp[0])
-				command[i] = string(tmp)
+				words[i] = string(tmp)
 			}
 		}
-		query = strings.Join(command[1:], "_")
+		query = strings.Join(words, "_")
 	}
 
 	page = fetchArticle(query)
@@ -601,7 +553,7 @@ func handleWiki(s *discordgo.Session, m *discordgo.MessageCreate, command []stri
 	footer.Text = "Pulled from Wikipedia"
 	footer.IconURL = "https://upload.wikimedia.org/wikipedia/commons/thumb/b/b3/Wikipedia-logo-v2-en.svg/1200px-Wikipedia-logo-v2-en.svg.png"
 	embed.Footer = &footer
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err := sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
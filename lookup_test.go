@@ -10,6 +10,8 @@ Those commands don't need to be tested since they are verified to work
 at github.com/bwmarrin/discordgo
 **/
 func TestLookups(t *testing.T) {
+	useFixtures(t)
+
 	t.Run("~image scrapes 10 images correctly", func(t *testing.T) {
 		imageSet := fetchImage("gecko")
 		if imageSet.Query != "gecko" {
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -15,20 +14,11 @@ import (
 )
 
 /**
-Used to validate a user's permissions before moving forward with a command. Prevents command abuse.
-If the user has administrator permissions, just automatically allow them to perform any bot command.
+Checks whether a set of permissions includes the requested permission. Administrators
+implicitly have every permission.
 **/
-func userHasValidPermissions(s *discordgo.Session, m *discordgo.MessageCreate, permission int64) bool {
-	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
-	if err != nil {
-		sendError(s, m, "", Discord)
-		logError("Failed to acquire user permissions! " + err.Error())
-		return false
-	}
-	if perms|permission == perms || perms|discordgo.PermissionAdministrator == perms {
-		return true
-	}
-	return false
+func permissionsInclude(perms int64, permission int64) bool {
+	return perms|permission == perms || perms|discordgo.PermissionAdministrator == perms
 }
 
 /**
@@ -41,7 +31,7 @@ func dmUser(s *discordgo.Session, userID string, message string) {
 		logError("Failed to create DM with user. " + err.Error())
 		return
 	}
-	_, err = s.ChannelMessageSend(channel.ID, message)
+	_, err = sendToChannel(s, channel.ID, &discordgo.MessageSend{Content: message})
 	if err != nil {
 		logError("Failed to send message! " + err.Error())
 		return
@@ -51,8 +41,8 @@ func dmUser(s *discordgo.Session, userID string, message string) {
 /**
 Attempts to copy over the last <number> messages to the given channel, then outputs its success
 */
-func attemptCopy(s *discordgo.Session, m *discordgo.MessageCreate, command []string, preserveMessages bool) {
-	logInfo(strings.Join(command, " "))
+func attemptCopy(s *discordgo.Session, m *Invocation, args *Args, preserveMessages bool) {
+	logInfo(m.Content)
 	var commandInvoked string
 	if preserveMessages {
 		commandInvoked = "cp"
@@ -60,25 +50,8 @@ func attemptCopy(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 		commandInvoked = "mv"
 	}
 
-	if len(command) != 3 {
-		sendError(s, m, commandInvoked, Syntax)
-		return
-	}
-
-	messageCount, err := strconv.Atoi(command[1])
-	if err != nil {
-		sendError(s, m, commandInvoked, Syntax)
-		return
-	}
-
-	// verify correctly invoking channel
-	if !strings.HasPrefix(command[2], "<#") || !strings.HasSuffix(command[2], ">") {
-		sendError(s, m, commandInvoked, Syntax)
-		return
-	}
-
-	channel := strings.ReplaceAll(command[2], "<#", "")
-	channel = strings.ReplaceAll(channel, ">", "")
+	messageCount := args.Ints["number"]
+	channel := args.Values["channel"]
 
 	// retrieve messages from current invoked channel
 	messages, err := s.ChannelMessages(m.ChannelID, messageCount, m.ID, "", "")
@@ -176,7 +149,7 @@ func attemptCopy(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 		embed.Fields = contents
 
 		// send response
-		_, err := s.ChannelMessageSendEmbed(channel, &embed)
+		_, err := sendEmbedToChannel(s, channel, &embed)
 		if err != nil {
 			logError("Failed to send result message! " + err.Error())
 			return
@@ -190,23 +163,10 @@ func attemptCopy(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 Helper function for handleProfile. Attempts to retrieve a user's avatar and return it
 in an embed.
 */
-func attemptProfile(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-
-	if len(command) != 2 {
-		sendError(s, m, "profile", Syntax)
-		return
-	}
+func attemptProfile(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "profile", Syntax)
-		return
-	}
-
-	userID := strings.TrimSuffix(command[1], ">")
-	userID = strings.TrimPrefix(userID, "<@")
-	userID = strings.TrimPrefix(userID, "!") // this means the user has a nickname
+	userID := args.Values["user"]
 	var embed discordgo.MessageEmbed
 	embed.Type = "rich"
 
@@ -224,7 +184,7 @@ func attemptProfile(s *discordgo.Session, m *discordgo.MessageCreate, command []
 	if err == nil {
 		nickname = member.Nick
 	} else {
-		fmt.Println(err)
+		logWarning("Unable to retrieve member's nickname. " + err.Error())
 	}
 
 	// title the embed
@@ -242,30 +202,17 @@ func attemptProfile(s *discordgo.Session, m *discordgo.MessageCreate, command []
 	image.URL = user.AvatarURL("512")
 	embed.Image = &image
 
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err = sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Failed to send result message! " + err.Error())
 		return
 	}
 }
 
-func attemptAbout(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-
-	if len(command) != 2 {
-		sendError(s, m, "about", Syntax)
-		return
-	}
-
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "about", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
+func attemptAbout(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
-	logInfo(strings.Join(command, " "))
+	userID := args.Values["user"]
 
 	member, err := s.GuildMember(m.GuildID, userID)
 	if err != nil {
@@ -311,7 +258,7 @@ func attemptAbout(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 	embed.Fields = contents
 
 	// send response
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+	_, err = sendEmbed(s, m, &embed)
 	if err != nil {
 		logError("Couldn't send the message... " + err.Error())
 		return
@@ -321,41 +268,20 @@ func attemptAbout(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 /**
 Outputs the bot's current uptime.
 **/
-func handleUptime(s *discordgo.Session, m *discordgo.MessageCreate, start []string) {
-	logInfo(start[0])
-	start_time, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", start[0])
-	if err != nil {
-		logError("Could not parse start time! " + err.Error())
-		attemptSendMsg(s, m, "Error parsing the date... :frowning:")
-	}
-	attemptSendMsg(s, m, fmt.Sprintf(":robot: Uptime: %s", time.Since(start_time).Truncate(time.Second/10).String()))
+func handleUptime(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(start.String())
+	attemptSendMsg(s, m, fmt.Sprintf(":robot: Uptime: %s", time.Since(start).Truncate(time.Second/10).String()))
 	logSuccess("Reported uptime")
 }
 
 /**
 Toggles "deafened" state of the specified user.
 **/
-func vcDeaf(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-
-	if !userHasValidPermissions(s, m, discordgo.PermissionVoiceDeafenMembers) {
-		sendError(s, m, "vcdeaf", Permissions)
-		return
-	}
-
-	if len(command) != 2 {
-		sendError(s, m, "vcdeaf", Syntax)
-		return
-	}
+func vcDeaf(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 
 	// 1. get user ID
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "vcdeaf", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
+	userID := args.Values["user"]
 
 	member, err := s.GuildMember(m.GuildID, userID)
 	if err != nil {
@@ -378,27 +304,11 @@ func vcDeaf(s *discordgo.Session, m *discordgo.MessageCreate, command []string)
 /**
 Toggles "VC muted" state of the specified user.
 **/
-func vcMute(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionVoiceMuteMembers) {
-		sendError(s, m, "vcmute", Permissions)
-		return
-	}
-
-	if len(command) != 2 {
-		sendError(s, m, "vcmute", Syntax)
-		return
-	}
-
+func vcMute(s *discordgo.Session, m *Invocation, args *Args) {
 	// 1. get user ID
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "vcmute", Syntax)
-		return
-	}
+	userID := args.Values["user"]
 
-	userID := stripUserID(command[1])
-
-	logInfo(strings.Join(command, " "))
+	logInfo(m.Content)
 
 	member, err := s.GuildMember(m.GuildID, userID)
 	if err != nil {
@@ -421,35 +331,14 @@ func vcMute(s *discordgo.Session, m *discordgo.MessageCreate, command []string)
 /**
 Moves the user to the specified voice channel.
 **/
-func vcMove(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionVoiceDeafenMembers) {
-		sendError(s, m, "vcmove", Permissions)
-		return
-	}
-
-	if len(command) != 3 {
-		sendError(s, m, "vcmove", Syntax)
-		return
-	}
-
+func vcMove(s *discordgo.Session, m *Invocation, args *Args) {
 	// 1. get user ID
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "vcmove", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
+	userID := args.Values["user"]
 
-	logInfo(strings.Join(command, " "))
+	logInfo(m.Content)
 
 	// 2. get voice channel
-	if !strings.HasPrefix(command[2], "<#") || !strings.HasSuffix(command[2], ">") {
-		sendError(s, m, "vcmove", Syntax)
-		return
-	}
-	channel := strings.ReplaceAll(command[2], "<#", "")
-	channel = strings.ReplaceAll(channel, ">", "")
+	channel := args.Values["channel"]
 
 	// 3. move user to voice channel
 	err := s.GuildMemberMove(m.GuildID, userID, &channel)
@@ -465,25 +354,9 @@ func vcMove(s *discordgo.Session, m *discordgo.MessageCreate, command []string)
 /**
 Kicks the specified user from the voice channel they are in, if any.
 **/
-func vcKick(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionVoiceMuteMembers) {
-		sendError(s, m, "vckick", Permissions)
-		return
-	}
-
-	if len(command) != 2 {
-		sendError(s, m, "vckick", Syntax)
-		return
-	}
-
+func vcKick(s *discordgo.Session, m *Invocation, args *Args) {
 	// 1. get user ID
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "vckick", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
+	userID := args.Values["user"]
 
 	// 2. remove user from voice channel
 	err := s.GuildMemberMove(m.GuildID, userID, nil)
@@ -496,36 +369,12 @@ func vcKick(s *discordgo.Session, m *discordgo.MessageCreate, command []string)
 	sendSuccess(s, m, "")
 }
 
-/**
-Forces the bot to exit with code 0. Note that in Heroku the bot will restart automatically.
-**/
-func handleShutdown(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	if m.Author.ID == "172311520045170688" {
-		sendSuccess(s, m, "")
-		s.Close()
-		os.Exit(0)
-	} else {
-		attemptSendMsg(s, m, "You dare try and go against the wishes of <@172311520045170688> ..? ")
-
-		time.Sleep(10 * time.Second)
-		attemptSendMsg(s, m, "Bruh this gonna be you when sage and his boys get here... I just pinged him so you better be afraid :slight_smile:")
-
-		time.Sleep(2 * time.Second)
-		attemptSendMsg(s, m, "https://media4.giphy.com/media/3o6Ztm3eJNDBy4NfiM/giphy.gif")
-	}
-}
-
 /**
 Generates an invite code to the channel in which ~invite was invoked if the user has the
 permission to create instant invites.
 **/
-func handleInvite(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	if !userHasValidPermissions(s, m, discordgo.PermissionCreateInstantInvite) {
-		sendError(s, m, "invite", Permissions)
-		return
-	}
+func handleInvite(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 	var invite discordgo.Invite
 	invite.Temporary = false
 	invite.MaxAge = 21600 // 6 hours
@@ -542,29 +391,13 @@ func handleInvite(s *discordgo.Session, m *discordgo.MessageCreate, command []st
 
 /**
 Nicknames the user if they target themselves, or nicknames a target user if the user who invoked
-~nick has the permission to change nicknames.
+~nick has the permission to change nicknames (checked before dispatch).
 **/
-func handleNickname(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !(userHasValidPermissions(s, m, discordgo.PermissionChangeNickname) && strings.Contains(command[1], m.Author.ID)) && !(userHasValidPermissions(s, m, discordgo.PermissionManageNicknames)) {
-		sendError(s, m, "nick", Permissions)
-		return
-	}
+func handleNickname(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	logInfo(m.Content)
 
-	logInfo(strings.Join(command, " "))
-
-	if len(command) != 3 {
-		sendError(s, m, "nick", Syntax)
-		return
-	}
-
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "nick", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
-	err := s.GuildMemberNickname(m.GuildID, userID, strings.Join(command[2:], " "))
+	err := s.GuildMemberNickname(m.GuildID, userID, args.Values["nickname"])
 	if err != nil {
 		logError("Failed to set nickname! " + err.Error())
 		sendError(s, m, "nick", Discord)
@@ -577,26 +410,9 @@ func handleNickname(s *discordgo.Session, m *discordgo.MessageCreate, command []
 /**
 Kicks a user from the server if the invoking user has the permission to kick users.
 **/
-func handleKick(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionKickMembers) {
-		sendError(s, m, "kick", Permissions)
-		return
-	}
-
-	if len(command) < 2 {
-		sendError(s, m, "kick", Syntax)
-		return
-	}
-
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if !regex.MatchString(command[1]) {
-		sendError(s, m, "kick", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
-	if len(command) > 2 {
-		reason := strings.Join(command[2:], " ")
+func handleKick(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	if reason, ok := args.Values["reason"]; ok {
 		// dm user why they were kicked
 		guild, err := s.Guild(m.GuildID)
 		if err != nil {
@@ -615,8 +431,9 @@ func handleKick(s *discordgo.Session, m *discordgo.MessageCreate, command []stri
 			sendError(s, m, "kick", Discord)
 			return
 		}
+		recordCase(s, ModCase{GuildID: m.GuildID, Action: "kick", UserID: userID, ModeratorID: m.Author.ID, Reason: reason})
 
-		sendSuccess(s, m, fmt.Sprintf(":wave: Kicked %s for the following reason: '%s'.", command[1], reason))
+		sendSuccess(s, m, fmt.Sprintf(":wave: Kicked <@%s> for the following reason: '%s'.", userID, reason))
 	} else {
 		// dm user they were kicked
 		guild, err := s.Guild(m.GuildID)
@@ -635,33 +452,17 @@ func handleKick(s *discordgo.Session, m *discordgo.MessageCreate, command []stri
 			sendError(s, m, "kick", Discord)
 			return
 		}
-		sendSuccess(s, m, fmt.Sprintf(":wave: Kicked %s.", command[1]))
+		recordCase(s, ModCase{GuildID: m.GuildID, Action: "kick", UserID: userID, ModeratorID: m.Author.ID})
+		sendSuccess(s, m, fmt.Sprintf(":wave: Kicked <@%s>.", userID))
 	}
 }
 
 /**
 Bans a user from the server if the invoking user has the permission to ban users.
 **/
-func handleBan(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionBanMembers) {
-		sendError(s, m, "ban", Permissions)
-		return
-	}
-
-	if len(command) < 2 {
-		sendError(s, m, "ban", Syntax)
-		return
-	}
-
-	regex := regexp.MustCompile(`^\<\@\!?[0-9]+\>$`)
-	if regex.MatchString(command[1]) {
-		sendError(s, m, "ban", Syntax)
-		return
-	}
-
-	userID := stripUserID(command[1])
-	if len(command) > 2 {
-		reason := strings.Join(command[2:], " ")
+func handleBan(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	if reason, ok := args.Values["reason"]; ok {
 
 		// dm user why they were banned
 		guild, err := s.Guild(m.GuildID)
@@ -681,8 +482,10 @@ func handleBan(s *discordgo.Session, m *discordgo.MessageCreate, command []strin
 			sendError(s, m, "ban", Discord)
 			return
 		}
+		forgetTempBan(m.GuildID, userID)
+		recordCase(s, ModCase{GuildID: m.GuildID, Action: "ban", UserID: userID, ModeratorID: m.Author.ID, Reason: reason})
 
-		sendSuccess(s, m, fmt.Sprintf(":hammer: Banned %s for the following reason: '%s'.", command[1], reason))
+		sendSuccess(s, m, fmt.Sprintf(":hammer: Banned <@%s> for the following reason: '%s'.", userID, reason))
 	} else {
 		// ban without reason
 		err := s.GuildBanCreate(m.GuildID, userID, 0)
@@ -691,6 +494,8 @@ func handleBan(s *discordgo.Session, m *discordgo.MessageCreate, command []strin
 			sendError(s, m, "ban", Discord)
 			return
 		}
+		forgetTempBan(m.GuildID, userID)
+		recordCase(s, ModCase{GuildID: m.GuildID, Action: "ban", UserID: userID, ModeratorID: m.Author.ID})
 		// dm user they were banned
 		guild, err := s.Guild(m.GuildID)
 		if err != nil {
@@ -702,35 +507,16 @@ func handleBan(s *discordgo.Session, m *discordgo.MessageCreate, command []strin
 		}
 		dmUser(s, userID, fmt.Sprintf("You have been banned from **%s** by %s#%s.\n", guildName, m.Author.Username, m.Author.Discriminator))
 
-		sendSuccess(s, m, fmt.Sprintf(":hammer: Banned %s.", command[1]))
+		sendSuccess(s, m, fmt.Sprintf(":hammer: Banned <@%s>.", userID))
 	}
 }
 
 /**
 Removes the <number> most recent messages from the channel where the command was called.
 **/
-func handlePurge(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionManageMessages) {
-		sendError(s, m, "purge", Permissions)
-		return
-	}
-
-	if len(command) != 2 {
-		sendError(s, m, "purge", Syntax)
-		return
-	}
-
-	messageCount, err := strconv.Atoi(command[1])
-	if err != nil {
-		sendError(s, m, "purge", Syntax)
-		return
-	}
-
-	if messageCount < 1 {
-		logWarning("User attempted to purge < 1 message.")
-		attemptSendMsg(s, m, ":frowning: Sorry, you must purge at least 1 message. Try again.")
-		return
-	}
+func handlePurge(s *discordgo.Session, m *Invocation, args *Args) {
+	messageCount := args.Ints["number"]
+	purged := 0
 
 	for messageCount > 0 {
 		messagesToPurge := 0
@@ -764,11 +550,18 @@ func handlePurge(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 		err = s.ChannelMessagesBulkDelete(m.ChannelID, messageIDs)
 		if err != nil {
 			logWarning("Failed to bulk delete messages! Attempting to continue... " + err.Error())
+		} else {
+			purged += len(messageIDs)
 		}
 		messageCount -= messagesToPurge
 	}
+	recordCase(s, ModCase{GuildID: m.GuildID, Action: "purge", ModeratorID: m.Author.ID, Details: fmt.Sprintf("**Messages**: %d in <#%s>", purged, m.ChannelID)})
+	// slash commands have no invoking message to clean up
+	if m.ID == "" {
+		return
+	}
 	time.Sleep(time.Second)
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+	err := s.ChannelMessageDelete(m.ChannelID, m.ID)
 	if err != nil {
 		logError("Failed to delete invoked command! " + err.Error())
 		return
@@ -779,49 +572,25 @@ func handlePurge(s *discordgo.Session, m *discordgo.MessageCreate, command []str
 Copies the <number> most recent messages from the channel where the command was called and
 pastes it in the requested channel.
 **/
-func handleCopy(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionManageMessages) {
-		sendError(s, m, "cp", Permissions)
-		return
-	}
-	attemptCopy(s, m, command, true)
+func handleCopy(s *discordgo.Session, m *Invocation, args *Args) {
+	attemptCopy(s, m, args, true)
 }
 
 /**
 Same as above, but purges each message it copies
 **/
-func handleMove(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	if !userHasValidPermissions(s, m, discordgo.PermissionManageMessages) {
-		sendError(s, m, "mv", Permissions)
-		return
-	}
-	attemptCopy(s, m, command, false)
+func handleMove(s *discordgo.Session, m *Invocation, args *Args) {
+	attemptCopy(s, m, args, false)
 }
 
 /**
 Allows user to create, remove, or edit emojis associated with the server.
 **/
-func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
-	logInfo(strings.Join(command, " "))
-	// validate calling user has permission to manage emojis
-	if !userHasValidPermissions(s, m, discordgo.PermissionManageEmojis) {
-		sendError(s, m, "emoji", Permissions)
-		return
-	}
-
-	if len(command) < 2 {
-		sendError(s, m, "emoji", Syntax)
-		return
-	}
-
+func emoji(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
 	// which command was invoked?
-	switch command[1] {
+	switch args.Subcommand {
 	case "help":
-
-		if len(command) != 2 {
-			sendError(s, m, "emoji", Syntax)
-		}
-
 		// send usage information
 		var embed discordgo.MessageEmbed
 		embed.Type = "rich"
@@ -834,20 +603,14 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 		contents = append(contents, createField("emoji delete <emoji>", "Remove the selected emoji from the server.", false))
 		embed.Fields = contents
 
-		_, err := s.ChannelMessageSendEmbed(m.ChannelID, &embed)
+		_, err := sendEmbed(s, m, &embed)
 		if err != nil {
 			logError("Failed to send instructions message embed! " + err.Error())
 			return
 		}
 	case "create":
-		// verify correct number of arguments
-		if len(command) != 4 {
-			sendError(s, m, "emoji", Syntax)
-			return
-		}
-
 		// verify alphanumeric and underscores
-		matched, err := regexp.MatchString(`^[a-zA-Z0-9_]*$`, command[1])
+		matched, err := regexp.MatchString(`^[a-zA-Z0-9_]*$`, args.Values["name"])
 		if err != nil {
 			logError("Failed to match regex! " + err.Error())
 			sendError(s, m, "emoji", Internal)
@@ -859,7 +622,7 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 		}
 
 		// convert image to base64 string
-		resp, err := http.Get(command[3])
+		resp, err := httpClient.Get(args.Values["url"])
 		if err != nil {
 			logError("No response from URL!" + err.Error())
 			sendError(s, m, "emoji", ReadParse)
@@ -905,7 +668,7 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 
 		base64Image += base64.StdEncoding.EncodeToString(bytes)
 
-		_, err = s.GuildEmojiCreate(m.GuildID, command[2], base64Image, nil)
+		_, err = s.GuildEmojiCreate(m.GuildID, &discordgo.EmojiParams{Name: args.Values["name"], Image: base64Image})
 		if err != nil {
 			logError("Failed to create new emoji!" + err.Error())
 			sendError(s, m, "emoji", Discord)
@@ -915,28 +678,7 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 		sendSuccess(s, m, "")
 
 	case "delete":
-		// verify correct number of arguments
-		if len(command) != 3 {
-			sendError(s, m, "emoji", Syntax)
-			return
-		}
-
-		// validate emoji string formatting
-		matched, err := regexp.MatchString(`^(<a?)?:\w+:(\d{18}>)$`, command[2])
-		if err != nil {
-			logError("Failed to match regex! " + err.Error())
-			sendError(s, m, "emoji", Internal)
-			return
-		}
-		if !matched {
-			logError("Regex did not match!")
-			sendError(s, m, "emoji", Syntax)
-			return
-		}
-
-		emojiID := strings.TrimSuffix(strings.Split(command[2], ":")[len(strings.Split(command[2], ":"))-1], ">")
-
-		err = s.GuildEmojiDelete(m.GuildID, emojiID)
+		err := s.GuildEmojiDelete(m.GuildID, args.Values["emoji"])
 		if err != nil {
 			logError("Failed to remove emoji from the server! " + err.Error())
 			sendError(s, m, "emoji", Discord)
@@ -946,26 +688,8 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 		sendSuccess(s, m, "")
 
 	case "rename":
-		// verify correct number of arguments
-		if len(command) != 4 {
-			sendError(s, m, "emoji", Syntax)
-			return
-		}
-
-		// verify valid emoji formatting provided for 2
-		matched, err := regexp.MatchString(`^(<a?)?:\w+:(\d{18}>)$`, command[2])
-		if err != nil {
-			logError("Failed to match regex! " + err.Error())
-			sendError(s, m, "emoji", Internal)
-			return
-		}
-		if !matched {
-			sendError(s, m, "emoji", Syntax)
-			return
-		}
-
-		// verify name is alphanumeric for 3
-		matched, err = regexp.MatchString(`^[a-zA-Z0-9_]*$`, command[3])
+		// verify name is alphanumeric
+		matched, err := regexp.MatchString(`^[a-zA-Z0-9_]*$`, args.Values["name"])
 		if err != nil {
 			logError("Failed to match regex! " + err.Error())
 			sendError(s, m, "emoji", Internal)
@@ -976,10 +700,8 @@ func emoji(s *discordgo.Session, m *discordgo.MessageCreate, command []string) {
 			return
 		}
 
-		emojiID := strings.TrimSuffix(strings.Split(command[2], ":")[len(strings.Split(command[2], ":"))-1], ">")
-
 		// set new name
-		_, err = s.GuildEmojiEdit(m.GuildID, emojiID, command[3], nil)
+		_, err = s.GuildEmojiEdit(m.GuildID, args.Values["emoji"], &discordgo.EmojiParams{Name: args.Values["name"]})
 		if err != nil {
 			logError("Failed to rename emoji! " + err.Error())
 			sendError(s, m, "emoji", Discord)
@@ -1,9 +1,6 @@
 package main
 
 import (
-	"fmt"
-	"math/rand"
-	"os"
 	"regexp"
 	"strings"
 	"testing"
@@ -12,38 +9,24 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-var response string
-var timeBetweenCommands time.Duration = 2 * time.Second
-var testingChannel string = "739852388264968243"
-
 /**
-Test anything that uses calculation outside of the discordgo commands.
-Those commands don't need to be tested since they are verified to work
-at github.com/bwmarrin/discordgo
+Test that commands respond correctly, using a fake Discord API so no bot
+tokens or live server are needed.
 **/
 func TestMessageResponse(t *testing.T) {
-	// main bot
-	go runBot(os.Getenv("BOT_TOKEN"))
-	time.Sleep(2 * time.Second) // give bot time to start
-
-	// test bot
-	dg, err := discordgo.New("Bot " + os.Getenv("TEST_BOT_TOKEN"))
-	if err != nil {
-		fmt.Println("Error creating test bot's discord session")
-		return
-	}
-	dg.AddHandler(updateResponse)
-	err = dg.Open()
-	if err != nil {
-		fmt.Println("Error opening test bot's connection,", err)
-		return
-	}
-
-	// -- tests
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	moderators := fake.AddRole("moderators", discordgo.PermissionAdministrator)
+	mod := fake.AddUser("mod", moderators.ID)
+	member := fake.AddUser("member")
+	target := fake.AddUser("target")
+
 	t.Run("Responds correctly to ~uptime", func(t *testing.T) {
-		dg.ChannelMessageSend(testingChannel, "~uptime")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		regex := regexp.MustCompile(`^:robot: Uptime: \d\.\ds$`)
+		response := fake.Reply(member, "~uptime").Content
+		regex := regexp.MustCompile(`^:robot: Uptime: \d+(\.\d)?m?s$`)
 		if !regex.MatchString(response) {
 			t.Logf("Failed to respond correctly to ~uptime; Response was `" + response + "`")
 			t.Fail()
@@ -51,112 +34,138 @@ func TestMessageResponse(t *testing.T) {
 	})
 
 	t.Run("Generates invitation", func(t *testing.T) {
-		dg.ChannelMessageSend(testingChannel, "~invite")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if strings.HasPrefix(response, ":mailbox_with_mail: Here's your invitation! https://discord.gg/") == false {
-			t.Logf("Failed to generate invitation")
+		response := fake.Reply(mod, "~invite").Content
+		if response != ":mailbox_with_mail: Here's your invitation! https://discord.gg/fake-invite" {
+			t.Logf("Failed to generate invitation; Response was `" + response + "`")
 			t.Fail()
 		}
 	})
 
 	t.Run("Permissions work appropriately", func(t *testing.T) {
-		randNum := rand.Intn(10000)
-		dg.ChannelMessageSend(testingChannel, fmt.Sprintf("~nick <@!700962207785156668> test nickname %d", randNum))
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Done!" {
-			t.Logf("Failed to change valid nickname")
-			t.Fail()
-		}
-	})
-
-	t.Run("Incorrect usages are reported", func(t *testing.T) {
-		dg.ChannelMessageSend(testingChannel, "~nick <@!700962207785156668>")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~nick @<user> <new name>`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
+		response := fake.Reply(member, "~nick <@!"+target.ID+"> test nickname").Content
+		if response != ":bangbang: You do not have the permissions to use this command." {
+			t.Logf("Should not have been able to nickname another user; Response was `" + response + "`")
 			t.Fail()
 		}
 
-		dg.ChannelMessageSend(testingChannel, "~kick user")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~kick @<user> (reason: optional)`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
-		}
+		message := fake.SendMessage(mod, "~nick <@!"+target.ID+"> test nickname")
+		fake.WaitFor("the nickname to change", func() bool {
+			fake.Session.State.RLock()
+			defer fake.Session.State.RUnlock()
+			return fake.Member(target.ID).Nick == "test nickname"
+		})
+		fake.WaitFor("the success reaction", func() bool {
+			for _, reaction := range fake.Reactions() {
+				if reaction.MessageID == message.ID && reaction.Emoji == "✔️" {
+					return true
+				}
+			}
+			return false
+		})
+	})
 
-		dg.ChannelMessageSend(testingChannel, "~kick")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~kick @<user> (reason: optional)`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
+	t.Run("Allow overrides grant every use of a command", func(t *testing.T) {
+		if response := fake.Reply(member, "~activity autokick 30").Content; response != ":bangbang: You do not have the permissions to use this command." {
+			t.Logf("Should not have been able to set autokick; Response was `" + response + "`")
 			t.Fail()
 		}
 
-		dg.ChannelMessageSend(testingChannel, "~ban user")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~ban @<user> (reason: optional)`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
+		for _, command := range []string{"nick", "activity"} {
+			storage.SetPermissionOverride(PermissionOverride{GuildID: fake.Guild.ID, Command: command, TargetID: member.ID, Allow: true})
 		}
+		invalidatePermissionOverrides(fake.Guild.ID)
+		defer func() {
+			storage.RemovePermissionOverrides(fake.Guild.ID, "nick")
+			storage.RemovePermissionOverrides(fake.Guild.ID, "activity")
+			invalidatePermissionOverrides(fake.Guild.ID)
+		}()
+
+		fake.SendMessage(member, "~nick <@!"+target.ID+"> granted nickname")
+		fake.WaitFor("the nickname to change", func() bool {
+			fake.Session.State.RLock()
+			defer fake.Session.State.RUnlock()
+			return fake.Member(target.ID).Nick == "granted nickname"
+		})
+		fake.SendMessage(member, "~activity autokick 30")
+		fake.WaitFor("autokick to be set", func() bool {
+			autokick, err := storage.GetAutoKick(fake.Guild.ID)
+			return err == nil && autokick != nil && autokick.DaysUntilKick == 30
+		})
+	})
 
-		dg.ChannelMessageSend(testingChannel, "~ban")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~ban @<user> (reason: optional)`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
-		}
+	t.Run("Overrides for @everyone apply to every member", func(t *testing.T) {
+		storage.SetPermissionOverride(PermissionOverride{GuildID: fake.Guild.ID, Command: "nick", TargetID: fake.Guild.ID, IsRole: true, Allow: true})
+		invalidatePermissionOverrides(fake.Guild.ID)
+		defer func() {
+			storage.RemovePermissionOverrides(fake.Guild.ID, "nick")
+			invalidatePermissionOverrides(fake.Guild.ID)
+		}()
+
+		fake.SendMessage(member, "~nick <@!"+target.ID+"> everyone's nickname")
+		fake.WaitFor("the nickname to change", func() bool {
+			fake.Session.State.RLock()
+			defer fake.Session.State.RUnlock()
+			return fake.Member(target.ID).Nick == "everyone's nickname"
+		})
+	})
 
-		dg.ChannelMessageSend(testingChannel, "~profile")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~profile @user`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
+	t.Run("Kicks and bans are carried out with their reason", func(t *testing.T) {
+		response := fake.Reply(mod, "~kick <@"+target.ID+"> spamming").Content
+		kicks := fake.Kicks()
+		if response != ":wave: Kicked <@"+target.ID+"> for the following reason: 'spamming'." || len(kicks) != 1 || kicks[0].UserID != target.ID || kicks[0].Reason != "spamming" {
+			t.Logf("Unexpected kick %+v; Response was `%s`", kicks, response)
 			t.Fail()
 		}
 
-		dg.ChannelMessageSend(testingChannel, "~profile user")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~profile @user`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
+		response = fake.Reply(mod, "~ban <@"+member.ID+">").Content
+		bans := fake.Bans()
+		if response != ":hammer: Banned <@"+member.ID+">." || len(bans) != 1 || bans[0].UserID != member.ID {
+			t.Logf("Unexpected ban %+v; Response was `%s`", bans, response)
 			t.Fail()
 		}
 
-		dg.ChannelMessageSend(testingChannel, "~cp 3")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~cp <number <= 100> <#channel>`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
-		}
+		// both users should have been told why in a DM
+		fake.WaitFor("the DMs", func() bool {
+			dms := 0
+			for _, message := range fake.Sent() {
+				if strings.HasPrefix(message.ChannelID, "dm-") {
+					dms++
+				}
+			}
+			return dms == 2
+		})
+	})
 
-		dg.ChannelMessageSend(testingChannel, "~cp 3 fakechannel")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~cp <number <= 100> <#channel>`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
+	t.Run("Activity is stored as written", func(t *testing.T) {
+		obrien := fake.AddUser("O'Brien")
+		logActivity(fake.Guild.ID, obrien, time.Now(), "Said 'hi'", true)
+		activity, err := storage.GetMemberActivity(fake.Guild.ID, obrien.ID)
+		if err != nil || activity == nil || activity.MemberName != "O'Brien#1234" || activity.Description != "Said 'hi'" {
+			t.Logf("Unexpected activity %+v %v", activity, err)
 			t.Fail()
 		}
+	})
 
-		dg.ChannelMessageSend(testingChannel, "~mv 3")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~mv <number <= 100> <#channel>`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
+	t.Run("Incorrect usages are reported", func(t *testing.T) {
+		usages := map[string]string{
+			"~nick <@!" + mod.ID + ">": "Usage: `~nick @user <nickname>`",
+			"~kick user":               "Usage: `~kick @user (reason: optional)`",
+			"~kick":                    "Usage: `~kick @user (reason: optional)`",
+			"~ban user":                "Usage: `~ban @user (reason: optional)`",
+			"~ban":                     "Usage: `~ban @user (reason: optional)`",
+			"~profile":                 "Usage: `~profile @user`",
+			"~profile user":            "Usage: `~profile @user`",
+			"~cp 3":                    "Usage: `~cp <number <= 100> #channel`",
+			"~cp 3 fakechannel":        "Usage: `~cp <number <= 100> #channel`",
+			"~mv 3":                    "Usage: `~mv <number <= 100> #channel`",
+			"~mv 3 fakechannel":        "Usage: `~mv <number <= 100> #channel`",
 		}
-
-		dg.ChannelMessageSend(testingChannel, "~mv 3 fakechannel")
-		time.Sleep(timeBetweenCommands) // allow response to populate
-		if response != "Usage: `~mv <number <= 100> <#channel>`" {
-			t.Logf("Should have reported incorrect usage, but didn't")
-			t.Fail()
+		for command, usage := range usages {
+			response := fake.Reply(mod, command).Content
+			if response != usage {
+				t.Logf("Should have reported incorrect usage of `%s`, but responded `%s`", command, response)
+				t.Fail()
+			}
 		}
 	})
-
-	// -- teardown
-	time.Sleep(2 * time.Second)
-	dg.ChannelMessageSend(testingChannel, "~shutdown")
-	dg.Close()
-}
-
-func updateResponse(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == s.State.User.ID {
-		return
-	}
-	response = m.Content
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var messageCacheTable string
+
+/**
+A recent message, kept because Discord doesn't say what a deleted message
+said. CachedAt is when its latest version was seen.
+*/
+type CachedMessage struct {
+	Message  *discordgo.Message
+	CachedAt time.Time
+}
+
+/**
+The last message deleted or edited in a channel, for ~snipe and ~editsnipe.
+Before is only set for edits.
+*/
+type snipedMessage struct {
+	Before  *discordgo.Message
+	Message *discordgo.Message
+	At      time.Time
+}
+
+var (
+	// the newest messages of each channel, oldest first
+	messageCache     = make(map[string][]CachedMessage)
+	lastDeleted      = make(map[string]snipedMessage)
+	lastEdited       = make(map[string]snipedMessage)
+	messageCacheLock sync.Mutex
+	// whether the cache is mirrored to the database; only read at startup
+	messageCachePersisted bool
+	// changes to the persisted cache, written by writeMessageCache so event
+	// handlers never wait on the database
+	messageCacheWrites      = make(chan messageCacheWrite, 1000)
+	startMessageCacheWriter sync.Once
+)
+
+/**
+A change to the persisted cache: a message to save and/or messages of a
+channel to remove. done is closed once it and every earlier change are written.
+*/
+type messageCacheWrite struct {
+	save      *CachedMessage
+	channelID string
+	remove    []string
+	done      chan struct{}
+}
+
+/**
+Returns whether a message may be cached with the given settings. Messages in
+excluded channels or by excluded users never are.
+*/
+func messageCacheable(message *discordgo.Message, settings CacheConfig) bool {
+	if message.GuildID == "" || message.Author == nil || settings.Size == 0 {
+		return false
+	}
+	for _, id := range settings.ExcludeChannels {
+		if id == message.ChannelID {
+			return false
+		}
+	}
+	for _, id := range settings.ExcludeUsers {
+		if id == message.Author.ID {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+Loads the messages that were cached before a restart, if the cache is persisted.
+*/
+func loadMessageCache() error {
+	settings := currentConfig().Cache
+	messageCachePersisted = settings.Persist
+	if !messageCachePersisted {
+		return nil
+	}
+	startMessageCacheWriter.Do(func() { go writeMessageCache() })
+	saved, err := storage.GetCachedMessages(time.Now().Add(-settings.TTL))
+	if err != nil {
+		return err
+	}
+	messageCacheLock.Lock()
+	defer messageCacheLock.Unlock()
+	messageCache = make(map[string][]CachedMessage)
+	for _, cached := range saved {
+		if messageCacheable(cached.Message, settings) {
+			messageCache[cached.Message.ChannelID] = append(messageCache[cached.Message.ChannelID], cached)
+		}
+	}
+	for channelID, messages := range messageCache {
+		if len(messages) > settings.Size {
+			messageCache[channelID] = messages[len(messages)-settings.Size:]
+		}
+	}
+	logInfo(fmt.Sprintf("Loaded %d cached messages", len(saved)))
+	return nil
+}
+
+/**
+Writes the queued changes to the persisted cache, in order.
+*/
+func writeMessageCache() {
+	for write := range messageCacheWrites {
+		if write.save != nil {
+			if err := storage.CacheMessage(*write.save); err != nil {
+				logError("Unable to save the message to the cache! " + err.Error())
+			}
+		}
+		if len(write.remove) > 0 {
+			if err := storage.UncacheMessages(write.channelID, write.remove...); err != nil {
+				logError("Unable to remove the messages from the cache! " + err.Error())
+			}
+		}
+		if write.done != nil {
+			close(write.done)
+		}
+	}
+}
+
+/**
+Queues a change to the persisted cache. It is dropped if the database has
+fallen too far behind, rather than holding up the event handlers.
+*/
+func queueMessageCacheWrite(write messageCacheWrite) {
+	if !messageCachePersisted {
+		return
+	}
+	select {
+	case messageCacheWrites <- write:
+	default:
+		logWarning("The message cache is too far behind the database, dropping a change")
+	}
+}
+
+/**
+Waits until the changes queued so far are written to the database.
+*/
+func flushMessageCache() {
+	if !messageCachePersisted {
+		return
+	}
+	done := make(chan struct{})
+	messageCacheWrites <- messageCacheWrite{done: done}
+	<-done
+}
+
+/**
+Remembers a message, or its latest version if it was already cached. Only the
+newest messages of each channel are kept.
+*/
+func cacheMessage(message *discordgo.Message) {
+	settings := currentConfig().Cache
+	if !messageCacheable(message, settings) {
+		return
+	}
+	cached := CachedMessage{Message: &discordgo.Message{}, CachedAt: time.Now()}
+	*cached.Message = *message
+
+	messageCacheLock.Lock()
+	messages := messageCache[message.ChannelID]
+	replaced := false
+	for i := range messages {
+		if messages[i].Message.ID == message.ID {
+			messages[i] = cached
+			replaced = true
+		}
+	}
+	if !replaced {
+		messages = append(messages, cached)
+	}
+	var evicted []string
+	if len(messages) > settings.Size {
+		for _, old := range messages[:len(messages)-settings.Size] {
+			evicted = append(evicted, old.Message.ID)
+		}
+		messages = messages[len(messages)-settings.Size:]
+	}
+	messageCache[message.ChannelID] = messages
+	messageCacheLock.Unlock()
+
+	queueMessageCacheWrite(messageCacheWrite{save: &cached, channelID: message.ChannelID, remove: evicted})
+}
+
+/**
+Returns the cached copy of a message, or nil if it isn't cached.
+*/
+func cachedMessage(channelID string, messageID string) *discordgo.Message {
+	ttl := currentConfig().Cache.TTL
+	messageCacheLock.Lock()
+	defer messageCacheLock.Unlock()
+	for _, cached := range messageCache[channelID] {
+		if cached.Message.ID == messageID && time.Since(cached.CachedAt) < ttl {
+			return cached.Message
+		}
+	}
+	return nil
+}
+
+/**
+Forgets messages, returning the cached copies of the ones that were cached.
+*/
+func uncacheMessages(channelID string, messageIDs ...string) []*discordgo.Message {
+	ttl := currentConfig().Cache.TTL
+	forget := make(map[string]bool)
+	for _, id := range messageIDs {
+		forget[id] = true
+	}
+
+	var uncached []*discordgo.Message
+	messageCacheLock.Lock()
+	var kept []CachedMessage
+	for _, cached := range messageCache[channelID] {
+		if !forget[cached.Message.ID] {
+			kept = append(kept, cached)
+		} else if time.Since(cached.CachedAt) < ttl {
+			uncached = append(uncached, cached.Message)
+		}
+	}
+	messageCache[channelID] = kept
+	messageCacheLock.Unlock()
+
+	queueMessageCacheWrite(messageCacheWrite{channelID: channelID, remove: messageIDs})
+	return uncached
+}
+
+/**
+Forgets a message, returning the cached copy or nil if it wasn't cached.
+*/
+func uncacheMessage(channelID string, messageID string) *discordgo.Message {
+	if uncached := uncacheMessages(channelID, messageID); len(uncached) == 1 {
+		return uncached[0]
+	}
+	return nil
+}
+
+/**
+Remembers a deleted message for ~snipe. Only cached messages by people are
+remembered, so the exclusions apply here too.
+*/
+func snipeDeleted(message *discordgo.Message) {
+	if message.Author.Bot {
+		return
+	}
+	messageCacheLock.Lock()
+	lastDeleted[message.ChannelID] = snipedMessage{Message: message, At: time.Now()}
+	messageCacheLock.Unlock()
+}
+
+/**
+Remembers the versions of an edited message for ~editsnipe.
+*/
+func snipeEdited(before *discordgo.Message, after *discordgo.Message) {
+	if before.Author.Bot {
+		return
+	}
+	messageCacheLock.Lock()
+	lastEdited[after.ChannelID] = snipedMessage{Before: before, Message: after, At: time.Now()}
+	messageCacheLock.Unlock()
+}
+
+/**
+Drops the messages and snipes that are older than the TTL or that the settings
+no longer allow, and trims each channel to the cache size. This runs as the
+message_cache job, so changes to the settings apply without a restart.
+*/
+func pruneMessageCache(now time.Time) error {
+	settings := currentConfig().Cache
+	cutoff := now.Add(-settings.TTL)
+	removed := make(map[string][]string)
+
+	messageCacheLock.Lock()
+	for channelID, messages := range messageCache {
+		var kept []CachedMessage
+		for _, cached := range messages {
+			if cached.CachedAt.Before(cutoff) || !messageCacheable(cached.Message, settings) {
+				removed[channelID] = append(removed[channelID], cached.Message.ID)
+			} else {
+				kept = append(kept, cached)
+			}
+		}
+		if len(kept) > settings.Size {
+			for _, old := range kept[:len(kept)-settings.Size] {
+				removed[channelID] = append(removed[channelID], old.Message.ID)
+			}
+			kept = kept[len(kept)-settings.Size:]
+		}
+		if len(kept) == 0 {
+			delete(messageCache, channelID)
+		} else {
+			messageCache[channelID] = kept
+		}
+	}
+	for _, snipes := range []map[string]snipedMessage{lastDeleted, lastEdited} {
+		for channelID, sniped := range snipes {
+			if sniped.At.Before(cutoff) || !messageCacheable(sniped.Message, settings) {
+				delete(snipes, channelID)
+			}
+		}
+	}
+	messageCacheLock.Unlock()
+
+	if !messageCachePersisted {
+		return nil
+	}
+	// so a queued save can't bring back a message pruned here
+	flushMessageCache()
+	for channelID, messageIDs := range removed {
+		if err := storage.UncacheMessages(channelID, messageIDs...); err != nil {
+			return err
+		}
+	}
+	return storage.RemoveCachedMessagesBefore(cutoff)
+}
+
+/****
+COMMANDS
+****/
+
+/**
+Returns the channel's last deleted or edited message, if it is recent enough.
+**/
+func findSnipe(snipes map[string]snipedMessage, channelID string) (snipedMessage, bool) {
+	messageCacheLock.Lock()
+	defer messageCacheLock.Unlock()
+	sniped, ok := snipes[channelID]
+	if !ok || time.Since(sniped.At) >= currentConfig().Cache.TTL {
+		return snipedMessage{}, false
+	}
+	return sniped, true
+}
+
+/**
+Shows the last message deleted in this channel.
+**/
+func handleSnipe(s *discordgo.Session, m *Invocation, args *Args) {
+	sniped, ok := findSnipe(lastDeleted, m.ChannelID)
+	if !ok {
+		attemptSendMsg(s, m, "There's nothing to snipe in this channel.")
+		return
+	}
+	_, err := sendEmbed(s, m, &discordgo.MessageEmbed{
+		Type:        "rich",
+		Author:      &discordgo.MessageEmbedAuthor{Name: sniped.Message.Author.String(), IconURL: sniped.Message.Author.AvatarURL("")},
+		Description: describeContent(sniped.Message),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Deleted"},
+		Timestamp:   sniped.At.Format(time.RFC3339),
+	})
+	if err != nil {
+		logError("Failed to send the sniped message! " + err.Error())
+	}
+}
+
+/**
+Shows the last message edited in this channel, before and after the edit.
+**/
+func handleEditSnipe(s *discordgo.Session, m *Invocation, args *Args) {
+	sniped, ok := findSnipe(lastEdited, m.ChannelID)
+	if !ok {
+		attemptSendMsg(s, m, "There's nothing to snipe in this channel.")
+		return
+	}
+	_, err := sendEmbed(s, m, &discordgo.MessageEmbed{
+		Type:        "rich",
+		Author:      &discordgo.MessageEmbedAuthor{Name: sniped.Message.Author.String(), IconURL: sniped.Message.Author.AvatarURL("")},
+		Description: fmt.Sprintf("[Jump to the message](https://discord.com/channels/%s/%s/%s)", sniped.Message.GuildID, sniped.Message.ChannelID, sniped.Message.ID),
+		Fields: []*discordgo.MessageEmbedField{
+			createField("Before", describeContent(sniped.Before), false),
+			createField("After", describeContent(sniped.Message), false),
+		},
+		Footer:    &discordgo.MessageEmbedFooter{Text: "Edited"},
+		Timestamp: sniped.At.Format(time.RFC3339),
+	})
+	if err != nil {
+		logError("Failed to send the sniped edit! " + err.Error())
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Uses the message cache settings until the test ends, starting with an empty cache.
+**/
+func useTestCacheSettings(t *testing.T, settings CacheConfig) {
+	previous := currentConfig()
+	cfg := *previous
+	cfg.Cache = settings
+	configLock.Lock()
+	config = &cfg
+	configLock.Unlock()
+
+	messageCacheLock.Lock()
+	messageCache = make(map[string][]CachedMessage)
+	lastDeleted = make(map[string]snipedMessage)
+	lastEdited = make(map[string]snipedMessage)
+	messageCacheLock.Unlock()
+	t.Cleanup(func() {
+		flushMessageCache()
+		configLock.Lock()
+		config = previous
+		configLock.Unlock()
+		messageCachePersisted = false
+	})
+}
+
+/**
+Test that recent messages are cached within the configured limits and can be sniped.
+**/
+func TestMessageCache(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	moderators := fake.AddRole("moderators", discordgo.PermissionManageMessages)
+	mod := fake.AddUser("mod", moderators.ID)
+	member := fake.AddUser("member")
+	private := fake.AddUser("private")
+	settings := CacheConfig{Size: 2, TTL: time.Hour, ExcludeUsers: []string{private.ID}}
+	useTestCacheSettings(t, settings)
+	deleteMessage := func(message *discordgo.Message) {
+		fake.Inject(&discordgo.MessageDelete{Message: &discordgo.Message{ID: message.ID, ChannelID: message.ChannelID, GuildID: fake.Guild.ID}})
+	}
+
+	t.Run("the last deleted and edited messages can be sniped", func(t *testing.T) {
+		if response := fake.Reply(mod, "~snipe").Content; response != "There's nothing to snipe in this channel." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+
+		deleteMessage(fake.SendMessage(member, "oops, wrong channel"))
+		sniped := fake.Reply(mod, "~snipe")
+		if len(sniped.Embeds) != 1 || sniped.Embeds[0].Description != "oops, wrong channel" || sniped.Embeds[0].Author.Name != "member#1234" {
+			t.Logf("Unexpected snipe %+v", sniped.Embeds)
+			t.Fail()
+		}
+
+		message := fake.SendMessage(member, "I never said that")
+		fake.Inject(&discordgo.MessageUpdate{Message: &discordgo.Message{ID: message.ID, ChannelID: message.ChannelID, GuildID: fake.Guild.ID, Author: member, Content: "I said nothing"}})
+		sniped = fake.Reply(mod, "~editsnipe")
+		if len(sniped.Embeds) != 1 || len(sniped.Embeds[0].Fields) != 2 || sniped.Embeds[0].Fields[0].Value != "I never said that" || sniped.Embeds[0].Fields[1].Value != "I said nothing" {
+			t.Logf("Unexpected edit snipe %+v", sniped.Embeds)
+			t.Fail()
+		}
+	})
+
+	t.Run("excluded users are never cached", func(t *testing.T) {
+		message := fake.SendMessage(private, "keep this between us")
+		if cachedMessage(message.ChannelID, message.ID) != nil {
+			t.Logf("The excluded user's message was cached")
+			t.Fail()
+		}
+		deleteMessage(message)
+		if sniped := fake.Reply(mod, "~snipe"); len(sniped.Embeds) != 1 || sniped.Embeds[0].Description != "oops, wrong channel" {
+			t.Logf("The excluded user's message shouldn't be sniped, got %+v", sniped.Embeds)
+			t.Fail()
+		}
+	})
+
+	t.Run("only the newest messages are kept, until they expire", func(t *testing.T) {
+		first := fake.SendMessage(member, "one")
+		second := fake.SendMessage(member, "two")
+		third := fake.SendMessage(member, "three")
+		if cachedMessage(first.ChannelID, first.ID) != nil || cachedMessage(second.ChannelID, second.ID) == nil || cachedMessage(third.ChannelID, third.ID) == nil {
+			t.Logf("Expected only the last two messages to be cached")
+			t.Fail()
+		}
+
+		if err := pruneMessageCache(time.Now().Add(2 * time.Hour)); err != nil {
+			t.Fatalf("Unable to prune the cache: %s", err.Error())
+		}
+		if cachedMessage(third.ChannelID, third.ID) != nil {
+			t.Logf("The expired message should be forgotten")
+			t.Fail()
+		}
+		if response := fake.Reply(mod, "~snipe").Content; response != "There's nothing to snipe in this channel." {
+			t.Logf("The expired snipe should be forgotten, got `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("the cache can be kept in the database", func(t *testing.T) {
+		settings.Persist = true
+		useTestCacheSettings(t, settings)
+		if err := loadMessageCache(); err != nil {
+			t.Fatalf("Unable to load the cache: %s", err.Error())
+		}
+		message := fake.SendMessage(member, "remember me")
+		flushMessageCache()
+		if saved, err := storage.GetCachedMessages(time.Time{}); err != nil || len(saved) != 1 || saved[0].Message.ID != message.ID {
+			t.Fatalf("Expected the message to be saved, got %+v %v", saved, err)
+		}
+
+		// as if the bot restarted
+		messageCacheLock.Lock()
+		messageCache = make(map[string][]CachedMessage)
+		messageCacheLock.Unlock()
+		if err := loadMessageCache(); err != nil {
+			t.Fatalf("Unable to load the cache: %s", err.Error())
+		}
+		if cached := cachedMessage(message.ChannelID, message.ID); cached == nil || cached.Content != "remember me" || cached.Author.ID != member.ID {
+			t.Fatalf("Expected the message to be loaded, got %+v", cached)
+		}
+
+		deleteMessage(message)
+		flushMessageCache()
+		saved, err := storage.GetCachedMessages(time.Time{})
+		for _, cached := range saved {
+			if strings.Contains(cached.Message.Content, "remember me") {
+				t.Logf("The deleted message should be removed from the database")
+				t.Fail()
+			}
+		}
+		if err != nil {
+			t.Logf("Unable to read the cache: %s", err.Error())
+			t.Fail()
+		}
+		if sniped := fake.Reply(mod, "~snipe"); len(sniped.Embeds) != 1 || sniped.Embeds[0].Description != "remember me" {
+			t.Logf("Expected the loaded message to be sniped, got %+v", sniped.Embeds)
+			t.Fail()
+		}
+	})
+}
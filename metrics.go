@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	commandsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "discordbot_commands_total",
+		Help: "Commands invoked, by command and outcome (ok, disabled, cooldown or the error type).",
+	}, []string{"command", "outcome"})
+	commandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "discordbot_command_duration_seconds",
+		Help:    "Time spent in command handlers.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"command"})
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "discordbot_db_query_duration_seconds",
+		Help:    "Time spent running database queries, by statement type.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "discordbot_db_query_errors_total",
+		Help: "Database queries that returned an error, by statement type.",
+	}, []string{"operation"})
+	scrapeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "discordbot_scrape_failures_total",
+		Help: "Pages that could not be loaded, by reason (request, status or parse).",
+	}, []string{"reason"})
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "discordbot_job_runs_total",
+		Help: "Background job runs, by job and result.",
+	}, []string{"job", "result"})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "discordbot_job_duration_seconds",
+		Help:    "Time spent in background job runs.",
+		Buckets: []float64{0.1, 1, 5, 15, 60, 300, 900},
+	}, []string{"job"})
+)
+
+// set once startup has finished, and cleared again when shutting down
+var botReady int32
+
+/**
+Records the result of a background job run that started at the given time.
+*/
+func recordJobRun(job string, started time.Time, ok bool) {
+	result := "ok"
+	if !ok {
+		result = "error"
+	}
+	jobRuns.WithLabelValues(job, result).Inc()
+	jobDuration.WithLabelValues(job).Observe(time.Since(started).Seconds())
+}
+
+/**
+Returns the kind of statement (select, insert, ...) for labelling query metrics.
+*/
+func queryOperation(statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(fields[0])
+}
+
+/**
+Sets whether the bot is ready to handle commands, for /readyz.
+*/
+func setReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&botReady, 1)
+	} else {
+		atomic.StoreInt32(&botReady, 0)
+	}
+}
+
+/**
+Checks that the Discord session is connected and the database answers a ping.
+Returns the state of each check and whether all of them passed.
+*/
+func healthChecks(s *discordgo.Session) (map[string]string, bool) {
+	checks := map[string]string{"discord": "ok", "database": "ok"}
+	healthy := true
+
+	s.RLock()
+	connected := s.DataReady
+	s.RUnlock()
+	if !connected {
+		checks["discord"] = "disconnected"
+		healthy = false
+	}
+
+	if storage == nil {
+		checks["database"] = "not connected"
+		healthy = false
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := storage.Ping(ctx); err != nil {
+			checks["database"] = err.Error()
+			healthy = false
+		}
+	}
+	return checks, healthy
+}
+
+/**
+Writes the checks as JSON, with a 503 status if they didn't pass.
+*/
+func writeChecks(w http.ResponseWriter, checks map[string]string, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(checks)
+}
+
+/**
+Serves /healthz, /readyz and /metrics on the configured status address (":8080"
+by default). Setting it to "off" disables the server.
+*/
+func startStatusServer(s *discordgo.Session) {
+	addr := currentConfig().StatusAddr
+	if addr == "off" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		checks, healthy := healthChecks(s)
+		writeChecks(w, checks, healthy)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks, healthy := healthChecks(s)
+		ready := atomic.LoadInt32(&botReady) == 1
+		if !ready {
+			checks["startup"] = "not finished"
+		} else {
+			checks["startup"] = "ok"
+		}
+		writeChecks(w, checks, healthy && ready)
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		logInfo("Serving health checks and metrics on " + addr)
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			logError("Status server stopped! " + err.Error())
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+/**
+Test that command outcomes are counted and the health checks fail when the
+bot isn't connected.
+**/
+func TestMetrics(t *testing.T) {
+	t.Run("statements are labelled by operation", func(t *testing.T) {
+		if queryOperation("  SELECT * FROM activity;") != "select" || queryOperation("") != "unknown" {
+			t.Logf("Unexpected query operation labels")
+			t.Fail()
+		}
+	})
+
+	t.Run("invocations are counted by outcome", func(t *testing.T) {
+		before := testutil.ToFloat64(commandsTotal.WithLabelValues("wiki", "ok"))
+		recordInvocation(&Invocation{}, "wiki")
+		if testutil.ToFloat64(commandsTotal.WithLabelValues("wiki", "ok")) != before+1 {
+			t.Logf("A successful invocation was not counted")
+			t.Fail()
+		}
+
+		failed := &Invocation{}
+		failed.setOutcome(Database.String())
+		recordInvocation(failed, "wiki")
+		if testutil.ToFloat64(commandsTotal.WithLabelValues("wiki", "database")) != 1 {
+			t.Logf("A failed invocation was not counted with its error type")
+			t.Fail()
+		}
+	})
+
+	t.Run("health checks fail while disconnected", func(t *testing.T) {
+		checks, healthy := healthChecks(&discordgo.Session{})
+		if healthy || checks["discord"] != "disconnected" || checks["database"] != "not connected" {
+			t.Logf("Unexpected health checks %v", checks)
+			t.Fail()
+		}
+		recorder := httptest.NewRecorder()
+		writeChecks(recorder, checks, healthy)
+		if recorder.Code != 503 {
+			t.Logf("Expected a 503 status, got %d", recorder.Code)
+			t.Fail()
+		}
+	})
+}
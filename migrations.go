@@ -0,0 +1,404 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var schemaVersionTable string
+
+/**
+A numbered change to the schema. Up applies it and down reverts it. Both get
+the transaction the version bump is recorded in; MySQL commits DDL
+immediately, so a failed migration may still need cleaning up by hand there.
+*/
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx, dialect sqlDialect) error
+	down        func(tx *sql.Tx, dialect sqlDialect) error
+}
+
+// in order; never edit a migration once it has shipped, add a new one instead
+var migrations = []migration{
+	{1, "create the initial tables", createInitialTables, dropInitialTables},
+	{2, "store activity and leaderboard timestamps as DATETIME", timestampsToDatetime, timestampsToText},
+	{3, "widen member names", widenMemberNames, narrowMemberNames},
+	{4, "create the paginator table", createPaginatorTable, dropPaginatorTable},
+	{5, "remember who asked for each paginator", addPaginatorAuthor, dropPaginatorAuthor},
+	{6, "create the job status table", createJobStatusTable, dropJobStatusTable},
+	{7, "create the warning and escalation tables", createWarningTables, dropWarningTables},
+	{8, "create the temporary ban table", createTempBanTable, dropTempBanTable},
+	{9, "create the moderation case table", createCaseTable, dropCaseTable},
+	{10, "create the table of disabled mod log events", createModLogEventTable, dropModLogEventTable},
+	{11, "create the message cache table", createMessageCacheTable, dropMessageCacheTable},
+}
+
+/**
+Runs each statement in order, stopping at the first error.
+*/
+func execAll(tx *sql.Tx, statements ...string) error {
+	for _, statement := range statements {
+		if statement == "" {
+			continue
+		}
+		_, err := tx.Exec(statement)
+		if err != nil {
+			return fmt.Errorf("%s: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+/**
+Returns the version the schema should be at: MIGRATE_TO if set, otherwise the
+latest migration.
+*/
+func targetSchemaVersion() (int, error) {
+	latest := migrations[len(migrations)-1].version
+	requested := os.Getenv("MIGRATE_TO")
+	if requested == "" {
+		return latest, nil
+	}
+	target, err := strconv.Atoi(requested)
+	if err != nil || target < 0 || target > latest {
+		return 0, fmt.Errorf("MIGRATE_TO must be a version between 0 and %d", latest)
+	}
+	return target, nil
+}
+
+/**
+Returns the version of the schema, 0 if no migration has run yet.
+*/
+func (store *sqlStorage) schemaVersion() (int, error) {
+	err := store.exec("CREATE TABLE IF NOT EXISTS " + schemaVersionTable + " (version int PRIMARY KEY, description varchar(200), applied_at DATETIME);")
+	if err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err = store.db.QueryRow("SELECT MAX(version) FROM " + schemaVersionTable + ";").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+/**
+Applies or reverts migrations until the schema is at the target version.
+*/
+func (store *sqlStorage) migrate(target int) error {
+	current, err := store.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current == target {
+		logInfo(fmt.Sprintf("Schema is up to date at version %d", current))
+		return nil
+	}
+
+	for _, step := range migrations {
+		if step.version <= current || step.version > target {
+			continue
+		}
+		logInfo(fmt.Sprintf("Migrating to version %d: %s", step.version, step.description))
+		err = store.runMigration(step.up, "INSERT INTO "+schemaVersionTable+" (version, description, applied_at) VALUES (?, ?, ?);",
+			step.version, step.description, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("migration %d failed: %w", step.version, err)
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		step := migrations[i]
+		if step.version > current || step.version <= target {
+			continue
+		}
+		logWarning(fmt.Sprintf("Reverting version %d: %s", step.version, step.description))
+		err = store.runMigration(step.down, "DELETE FROM "+schemaVersionTable+" WHERE (version = ?);", step.version)
+		if err != nil {
+			return fmt.Errorf("reverting migration %d failed: %w", step.version, err)
+		}
+	}
+	logSuccess(fmt.Sprintf("Migrated the schema from version %d to %d", current, target))
+	return nil
+}
+
+/**
+Runs one direction of a migration and records the new version in the same
+transaction.
+*/
+func (store *sqlStorage) runMigration(change func(tx *sql.Tx, dialect sqlDialect) error, record string, args ...interface{}) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = change(tx, store.dialect)
+	if err == nil {
+		_, err = tx.Exec(record, args...)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/****
+MIGRATIONS
+****/
+
+/**
+The tables as they were before migrations existed. IF NOT EXISTS lets this run
+against databases that already have them.
+*/
+func createInitialTables(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS "+activityTable+" (entry "+dialect.autoIncrementKey+", guild_id char(20), member_id char(20), member_name char(40), last_active char(70), description char(80), whitelist boolean);",
+		"CREATE TABLE IF NOT EXISTS "+leaderboardTable+" (entry "+dialect.autoIncrementKey+", guild_id char(20), member_id char(20), member_name char(40), points int(11), last_awarded char(70));",
+		"CREATE TABLE IF NOT EXISTS "+joinLeaveTable+" (entry "+dialect.autoIncrementKey+", guild_id char(20), channel_id char(20), message_type char(5), image_link varchar(1000), message varchar(2000));",
+		"CREATE TABLE IF NOT EXISTS "+autokickTable+" (guild_id char(20) PRIMARY KEY, days_until_kick int(11));",
+		"CREATE TABLE IF NOT EXISTS "+modLogTable+" (guild_id char(20) PRIMARY KEY, channel_id char(20));",
+		"CREATE TABLE IF NOT EXISTS "+autoshrineTable+" (guild_id char(20) PRIMARY KEY, channel_id char(20));",
+		"CREATE TABLE IF NOT EXISTS "+guildSettingsTable+" (guild_id char(20) PRIMARY KEY, prefix varchar(5));",
+		"CREATE TABLE IF NOT EXISTS "+commandConfigTable+" (guild_id char(20), command char(20), channel_id char(20), enabled boolean, PRIMARY KEY (guild_id, command, channel_id));",
+		"CREATE TABLE IF NOT EXISTS "+permissionOverrideTable+" (guild_id char(20), command char(20), target_id char(20), is_role boolean, allow boolean, PRIMARY KEY (guild_id, command, target_id));",
+	)
+}
+
+func dropInitialTables(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"DROP TABLE IF EXISTS "+activityTable+";",
+		"DROP TABLE IF EXISTS "+leaderboardTable+";",
+		"DROP TABLE IF EXISTS "+joinLeaveTable+";",
+		"DROP TABLE IF EXISTS "+autokickTable+";",
+		"DROP TABLE IF EXISTS "+modLogTable+";",
+		"DROP TABLE IF EXISTS "+autoshrineTable+";",
+		"DROP TABLE IF EXISTS "+guildSettingsTable+";",
+		"DROP TABLE IF EXISTS "+commandConfigTable+";",
+		"DROP TABLE IF EXISTS "+permissionOverrideTable+";",
+	)
+}
+
+/**
+Replaces a column with one of a new type, converting every value with convert.
+Values that can't be converted become NULL.
+*/
+func convertColumn(tx *sql.Tx, table string, column string, newType string, convert func(value interface{}) (interface{}, error)) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT entry, %s FROM %s;", column, table))
+	if err != nil {
+		return err
+	}
+	// read everything first; MySQL can't run other statements while rows are open
+	converted := make(map[int64]interface{})
+	for rows.Next() {
+		var entry int64
+		var value interface{}
+		err = rows.Scan(&entry, &value)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		newValue, err := convert(value)
+		if err != nil {
+			logWarning(fmt.Sprintf("Unable to convert %s of entry %d in %s, clearing it: %s", column, entry, table, err.Error()))
+			continue
+		}
+		converted[entry] = newValue
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	temporary := column + "_converted"
+	err = execAll(tx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, temporary, newType))
+	if err != nil {
+		return err
+	}
+	for entry, value := range converted {
+		_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE (entry = ?);", table, temporary), value, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return execAll(tx,
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, column),
+		fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", table, temporary, column),
+	)
+}
+
+// the layout of time.Time.String(), which timestamps used to be stored as
+const legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+/**
+Parses a timestamp stored as time.Time.String() text, dropping the monotonic
+clock reading it may end with.
+*/
+func parseLegacyTime(value string) (time.Time, error) {
+	return time.Parse(legacyTimeLayout, strings.Split(value, " m=")[0])
+}
+
+/**
+Parses a DATETIME that the driver returned as text.
+*/
+func parseDatetime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown DATETIME format '%s'", value)
+}
+
+/**
+Reads a time.Time.String() value, the way timestamps were stored as text.
+*/
+func parseTextTimestamp(value interface{}) (interface{}, error) {
+	var text string
+	switch value := value.(type) {
+	case string:
+		text = value
+	case []byte:
+		text = string(value)
+	default:
+		return nil, fmt.Errorf("unexpected %T", value)
+	}
+	parsed, err := parseLegacyTime(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.UTC(), nil
+}
+
+/**
+Writes a DATETIME value back as time.Time.String() text.
+*/
+func formatTextTimestamp(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return value.UTC().String(), nil
+	case string:
+		parsed, err := parseDatetime(value)
+		return parsed.String(), err
+	case []byte:
+		parsed, err := parseDatetime(string(value))
+		return parsed.String(), err
+	}
+	return nil, fmt.Errorf("unexpected %T", value)
+}
+
+func timestampsToDatetime(tx *sql.Tx, dialect sqlDialect) error {
+	err := convertColumn(tx, activityTable, "last_active", "DATETIME", parseTextTimestamp)
+	if err != nil {
+		return err
+	}
+	return convertColumn(tx, leaderboardTable, "last_awarded", "DATETIME", parseTextTimestamp)
+}
+
+func timestampsToText(tx *sql.Tx, dialect sqlDialect) error {
+	err := convertColumn(tx, activityTable, "last_active", "char(70)", formatTextTimestamp)
+	if err != nil {
+		return err
+	}
+	return convertColumn(tx, leaderboardTable, "last_awarded", "char(70)", formatTextTimestamp)
+}
+
+func widenMemberNames(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		dialect.modifyColumn(activityTable, "member_name", "varchar(100)"),
+		dialect.modifyColumn(leaderboardTable, "member_name", "varchar(100)"),
+	)
+}
+
+func narrowMemberNames(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		dialect.modifyColumn(activityTable, "member_name", "char(40)"),
+		dialect.modifyColumn(leaderboardTable, "member_name", "char(40)"),
+	)
+}
+
+func createPaginatorTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "CREATE TABLE IF NOT EXISTS "+paginatorTable+" (message_id char(20) PRIMARY KEY, channel_id char(20), guild_id char(20), pages mediumtext, page int, expires_at DATETIME);")
+}
+
+func dropPaginatorTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+paginatorTable+";")
+}
+
+func addPaginatorAuthor(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "ALTER TABLE "+paginatorTable+" ADD COLUMN author_id char(20);")
+}
+
+func dropPaginatorAuthor(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "ALTER TABLE "+paginatorTable+" DROP COLUMN author_id;")
+}
+
+func createJobStatusTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "CREATE TABLE IF NOT EXISTS "+jobStatusTable+" (name varchar(50) PRIMARY KEY, last_run DATETIME, last_success DATETIME, last_error text, failures int, paused boolean);")
+}
+
+func dropJobStatusTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+jobStatusTable+";")
+}
+
+func createWarningTables(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS "+warningsTable+" (id "+dialect.autoIncrementKey+", guild_id char(20), user_id char(20), moderator_id char(20), reason varchar(1000), created_at DATETIME);",
+		"CREATE INDEX "+warningsTable+"_member ON "+warningsTable+" (guild_id, user_id);",
+		"CREATE TABLE IF NOT EXISTS "+escalationTable+" (guild_id char(20), warnings int, action varchar(10), duration_seconds int, PRIMARY KEY (guild_id, warnings));",
+	)
+}
+
+func dropWarningTables(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"DROP TABLE IF EXISTS "+warningsTable+";",
+		"DROP TABLE IF EXISTS "+escalationTable+";",
+	)
+}
+
+func createTempBanTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "CREATE TABLE IF NOT EXISTS "+tempBanTable+" (guild_id char(20), user_id char(20), moderator_id char(20), reason varchar(1000), expires_at DATETIME, PRIMARY KEY (guild_id, user_id));")
+}
+
+func dropTempBanTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+tempBanTable+";")
+}
+
+func createCaseTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS "+caseTable+" (id "+dialect.autoIncrementKey+", guild_id char(20), case_number int, action varchar(10), user_id char(20), moderator_id char(20), reason varchar(1000), duration_seconds int, details varchar(1000), log_channel_id char(20), log_message_id char(20), created_at DATETIME);",
+		"CREATE UNIQUE INDEX "+caseTable+"_number ON "+caseTable+" (guild_id, case_number);",
+		"CREATE INDEX "+caseTable+"_member ON "+caseTable+" (guild_id, user_id);",
+	)
+}
+
+func dropCaseTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+caseTable+";")
+}
+
+func createModLogEventTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "CREATE TABLE IF NOT EXISTS "+modLogEventTable+" (guild_id char(20), event varchar(20), PRIMARY KEY (guild_id, event));")
+}
+
+func dropModLogEventTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+modLogEventTable+";")
+}
+
+func createMessageCacheTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS "+messageCacheTable+" (channel_id char(20), message_id char(20), message mediumtext, cached_at DATETIME, PRIMARY KEY (channel_id, message_id));",
+		"CREATE INDEX "+messageCacheTable+"_cached_at ON "+messageCacheTable+" (cached_at);",
+	)
+}
+
+func dropMessageCacheTable(tx *sql.Tx, dialect sqlDialect) error {
+	return execAll(tx, "DROP TABLE IF EXISTS "+messageCacheTable+";")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+/**
+Test that migrations convert existing rows on the way up and restore them on
+the way down.
+**/
+func TestMigrations(t *testing.T) {
+	loadTableNames()
+	store, err := openSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open SQLite: %s", err.Error())
+	}
+	defer store.Close()
+
+	t.Run("migration versions are sequential", func(t *testing.T) {
+		for i, step := range migrations {
+			if step.version != i+1 || step.up == nil || step.down == nil {
+				t.Logf("Migration %d is out of order or missing a direction", step.version)
+				t.Fail()
+			}
+		}
+	})
+
+	// start from the schema as it was before migrations, with text timestamps
+	if err = store.migrate(1); err != nil {
+		t.Fatalf("Unable to create the initial tables: %s", err.Error())
+	}
+	store.exec("INSERT INTO "+activityTable+" (guild_id, member_id, member_name, last_active, description) VALUES (?, ?, ?, ?, ?);",
+		"1", "a", "alice#0001", "2022-08-20 15:04:05.123456789 -0700 MST m=+0.012345678", "Wrote a message")
+	store.exec("INSERT INTO "+activityTable+" (guild_id, member_id, member_name, last_active, description) VALUES (?, ?, ?, ?, ?);",
+		"1", "b", "bob#0002", "not a timestamp", "Joined the server")
+	store.exec("INSERT INTO "+leaderboardTable+" (guild_id, member_id, member_name, points, last_awarded) VALUES (?, ?, ?, ?, ?);",
+		"1", "a", "alice#0001", 12, "2022-08-20 15:04:05 +0000 UTC")
+
+	t.Run("text timestamps become DATETIME", func(t *testing.T) {
+		if err := store.migrate(len(migrations)); err != nil {
+			t.Fatalf("Unable to migrate: %s", err.Error())
+		}
+		if version, _ := store.schemaVersion(); version != len(migrations) {
+			t.Logf("Expected version %d, got %d", len(migrations), version)
+			t.Fail()
+		}
+		alice, err := store.GetMemberActivity("1", "a")
+		if err != nil || alice == nil || !alice.LastActive.Equal(time.Date(2022, 8, 20, 22, 4, 5, 123456789, time.UTC)) {
+			t.Logf("Unexpected activity %+v (%v)", alice, err)
+			t.Fail()
+		}
+		bob, err := store.GetMemberActivity("1", "b")
+		if err != nil || bob == nil || !bob.LastActive.IsZero() {
+			t.Logf("An unreadable timestamp should be cleared, got %+v (%v)", bob, err)
+			t.Fail()
+		}
+		entry, err := store.GetLeaderboardEntry("1", "a")
+		if err != nil || entry == nil || entry.Points != 12 || !entry.LastAwarded.Equal(time.Date(2022, 8, 20, 15, 4, 5, 0, time.UTC)) {
+			t.Logf("Unexpected leaderboard entry %+v (%v)", entry, err)
+			t.Fail()
+		}
+	})
+
+	t.Run("migrating again does nothing", func(t *testing.T) {
+		if err := store.migrate(len(migrations)); err != nil {
+			t.Logf("Migrating an up to date schema failed: %s", err.Error())
+			t.Fail()
+		}
+	})
+
+	t.Run("reverting restores text timestamps", func(t *testing.T) {
+		if err := store.migrate(1); err != nil {
+			t.Fatalf("Unable to revert: %s", err.Error())
+		}
+		var lastActive string
+		err := store.db.QueryRow("SELECT last_active FROM "+activityTable+" WHERE (member_id = 'a');").Scan(&lastActive)
+		if err != nil || lastActive != "2022-08-20 22:04:05.123456789 +0000 UTC" {
+			t.Logf("Unexpected text timestamp %s (%v)", lastActive, err)
+			t.Fail()
+		}
+		if err := store.migrate(0); err != nil {
+			t.Fatalf("Unable to drop the tables: %s", err.Error())
+		}
+		if _, err := store.GetGuildActivity("1"); err == nil {
+			t.Logf("The tables should have been dropped")
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var modLogEventTable string
+
+/**
+A kind of event guilds can choose to leave out of their mod log.
+*/
+type modLogEvent struct {
+	name        string
+	description string
+}
+
+// every event that can be logged, in the order ~modlog events lists them
+var modLogEvents = []modLogEvent{
+	{"moderation", "Warnings, timeouts, kicks, bans, unbans and purges"},
+	{"deletes", "Deleted messages, including bulk deletes"},
+	{"edits", "Edited messages"},
+	{"channels", "Channels being created, changed or deleted"},
+	{"roles", "Roles being created, changed or deleted"},
+	{"emojis", "Emojis being added, renamed or removed"},
+	{"members", "Nickname and role changes on members"},
+	{"server", "Changes to the server's settings"},
+}
+
+// the audit log entry for an event can show up a moment after the event itself
+var auditLogDelay = 2 * time.Second
+
+// audit log entries that were already posted, so an entry is never logged twice
+var loggedAuditEntries = make(map[string]time.Time)
+var loggedAuditEntriesLock sync.Mutex
+
+// readable names for the settings audit log entries mention
+var auditChangeLabels = map[string]string{
+	"name":                          "Name",
+	"topic":                         "Topic",
+	"nsfw":                          "Age-restricted",
+	"rate_limit_per_user":           "Slowmode (seconds)",
+	"bitrate":                       "Bitrate",
+	"user_limit":                    "User limit",
+	"parent_id":                     "Category",
+	"nick":                          "Nickname",
+	"color":                         "Color",
+	"hoist":                         "Shown separately",
+	"mentionable":                   "Mentionable",
+	"permissions":                   "Permissions",
+	"icon_hash":                     "Icon",
+	"splash_hash":                   "Invite background",
+	"banner_hash":                   "Banner",
+	"owner_id":                      "Owner",
+	"afk_channel_id":                "AFK channel",
+	"afk_timeout":                   "AFK timeout (seconds)",
+	"system_channel_id":             "System messages channel",
+	"rules_channel_id":              "Rules channel",
+	"verification_level":            "Verification level",
+	"explicit_content_filter":       "Explicit content filter",
+	"default_message_notifications": "Default notifications",
+	"mfa_level":                     "2FA requirement",
+	"vanity_url_code":               "Vanity URL",
+	"preferred_locale":              "Language",
+	"$add":                          "Roles added",
+	"$remove":                       "Roles removed",
+}
+
+// changes that are noise in the mod log or are already logged as cases
+var ignoredAuditChanges = map[string]bool{
+	"position":                     true,
+	"id":                           true,
+	"type":                         true,
+	"communication_disabled_until": true,
+}
+
+/**
+The argument naming one of the mod log events.
+*/
+func modLogEventArg() argSpec {
+	var names []string
+	for _, event := range modLogEvents {
+		names = append(names, event.name)
+	}
+	return choiceArg("event", names...)
+}
+
+/**
+Returns the channel an event should be posted to, or an empty string if the
+guild has no mod log channel or turned the event off.
+*/
+func modLogChannelFor(guildID string, event string) (string, error) {
+	channelID, err := storage.GetModLogChannel(guildID)
+	if err != nil || channelID == "" {
+		return "", err
+	}
+	disabled, err := storage.GetDisabledModLogEvents(guildID)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range disabled {
+		if name == event {
+			return "", nil
+		}
+	}
+	return channelID, nil
+}
+
+/**
+Returns whether an event would be posted, so handlers can skip the work of
+describing events nobody will see.
+*/
+func modLogWants(guildID string, event string) bool {
+	channelID, err := modLogChannelFor(guildID, event)
+	if err != nil {
+		logError("Unable to read the mod log settings! " + err.Error())
+		return false
+	}
+	return channelID != ""
+}
+
+/**
+Finds the audit log entry behind an event that just happened: the newest one
+of the given actions on the target (any target if it is empty) from the last
+minute that hasn't been logged yet. Returns nil if there isn't one, e.g. when
+the bot can't read the audit log.
+*/
+func findAuditEntry(s *discordgo.Session, guildID string, targetID string, actions ...discordgo.AuditLogAction) *discordgo.AuditLogEntry {
+	time.Sleep(auditLogDelay)
+	auditLog, err := s.GuildAuditLog(guildID, "", "", -1, 10)
+	if err != nil {
+		logWarning("Could not read the guild audit log! " + err.Error())
+		return nil
+	}
+
+	loggedAuditEntriesLock.Lock()
+	defer loggedAuditEntriesLock.Unlock()
+	for id, created := range loggedAuditEntries {
+		if time.Since(created) > 5*time.Minute {
+			delete(loggedAuditEntries, id)
+		}
+	}
+	for _, entry := range auditLog.AuditLogEntries {
+		if entry.ActionType == nil || (targetID != "" && entry.TargetID != targetID) {
+			continue
+		}
+		created, err := discordgo.SnowflakeTimestamp(entry.ID)
+		if err != nil || time.Since(created) > time.Minute {
+			continue
+		}
+		for _, action := range actions {
+			if *entry.ActionType != action {
+				continue
+			}
+			if _, logged := loggedAuditEntries[entry.ID]; logged {
+				return nil
+			}
+			loggedAuditEntries[entry.ID] = created
+			return entry
+		}
+	}
+	return nil
+}
+
+/**
+Describes who made a change and why, if the audit log says.
+*/
+func describeAuditEntry(s *discordgo.Session, guildID string, entry *discordgo.AuditLogEntry) string {
+	if entry == nil {
+		return ""
+	}
+	description := fmt.Sprintf("**Actor**: %s\n", describeActor(s, guildID, entry.UserID))
+	if entry.Reason != "" {
+		description += fmt.Sprintf("**Reason**: '%s'\n", entry.Reason)
+	}
+	return description
+}
+
+/**
+Formats a value from an audit log change, which is whatever JSON Discord sent.
+*/
+func formatAuditValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "none"
+	case string:
+		if value == "" {
+			return "none"
+		}
+		return "`" + truncateText(value, 200) + "`"
+	case bool:
+		if value {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}:
+		// role changes on members are lists of roles
+		var names []string
+		for _, item := range value {
+			if role, ok := item.(map[string]interface{}); ok {
+				names = append(names, fmt.Sprintf("%v", role["name"]))
+			} else {
+				names = append(names, fmt.Sprintf("%v", item))
+			}
+		}
+		return strings.Join(names, ", ")
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+/**
+Lists the changes in an audit log entry, one per line.
+*/
+func describeAuditChanges(entry *discordgo.AuditLogEntry) string {
+	if entry == nil {
+		return ""
+	}
+	var lines []string
+	for _, change := range entry.Changes {
+		if change.Key == nil || ignoredAuditChanges[string(*change.Key)] {
+			continue
+		}
+		key := string(*change.Key)
+		label, ok := auditChangeLabels[key]
+		if !ok {
+			label = strings.ToUpper(key[:1]) + strings.ReplaceAll(key[1:], "_", " ")
+		}
+		switch {
+		case key == "$add" || key == "$remove":
+			lines = append(lines, fmt.Sprintf("**%s**: %s", label, formatAuditValue(change.NewValue)))
+		case change.OldValue == nil && change.NewValue == nil:
+			continue
+		default:
+			lines = append(lines, fmt.Sprintf("**%s**: %s → %s", label, formatAuditValue(change.OldValue), formatAuditValue(change.NewValue)))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+/**
+Posts an event to the mod log, logging why if it can't.
+*/
+func postModLogEvent(s *discordgo.Session, guildID string, event string, embed *discordgo.MessageEmbed) {
+	embed.Type = "rich"
+	embed.Timestamp = time.Now().Format(time.RFC3339)
+	_, err := sendToModLog(s, guildID, event, embed)
+	if err != nil {
+		logError("Failed to post the " + event + " event to the mod log! " + err.Error())
+	}
+}
+
+/**
+Describes the author of a message for the mod log.
+*/
+func describeAuthor(message *discordgo.Message) string {
+	return fmt.Sprintf("**Author**: %s (<@%s>)\n", message.Author.String(), message.Author.ID)
+}
+
+/**
+Returns the text of a message for the mod log, including its attachments.
+*/
+func describeContent(message *discordgo.Message) string {
+	content := message.Content
+	for _, attachment := range message.Attachments {
+		content += "\n[" + attachment.Filename + "](" + attachment.URL + ")"
+	}
+	if strings.TrimSpace(content) == "" {
+		return "*no text*"
+	}
+	return content
+}
+
+/****
+EVENT HANDLERS
+****/
+
+func messageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// updates without an author are Discord adding link previews
+	if m.GuildID == "" || m.Author == nil || m.Author.Bot {
+		return
+	}
+	before := cachedMessage(m.ChannelID, m.ID)
+	cacheMessage(m.Message)
+	if before != nil && before.Content == m.Content {
+		return
+	}
+	if after := cachedMessage(m.ChannelID, m.ID); before != nil && after != nil {
+		snipeEdited(before, after)
+	}
+	if !modLogWants(m.GuildID, "edits") {
+		return
+	}
+
+	beforeContent := "*not cached*"
+	if before != nil {
+		beforeContent = describeContent(before)
+	}
+	postModLogEvent(s, m.GuildID, "edits", &discordgo.MessageEmbed{
+		Title: "✏️Message Edited",
+		Description: describeAuthor(m.Message) + fmt.Sprintf("**Channel**: <#%s> • [Jump to the message](https://discord.com/channels/%s/%s/%s)",
+			m.ChannelID, m.GuildID, m.ChannelID, m.ID),
+		Fields: []*discordgo.MessageEmbedField{
+			createField("Before", beforeContent, false),
+			createField("After", describeContent(m.Message), false),
+		},
+	})
+}
+
+func messageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if m.GuildID == "" {
+		return
+	}
+	deleted := uncacheMessage(m.ChannelID, m.ID)
+	if deleted != nil {
+		snipeDeleted(deleted)
+	}
+	if (deleted != nil && deleted.Author.ID == s.State.User.ID) || !modLogWants(m.GuildID, "deletes") {
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{Title: "🗑️Message Deleted"}
+	if deleted == nil {
+		embed.Description = fmt.Sprintf("**Channel**: <#%s>\nThe message wasn't cached, so what it said is unknown.", m.ChannelID)
+	} else {
+		embed.Description = describeAuthor(deleted) + fmt.Sprintf("**Channel**: <#%s>", m.ChannelID)
+		embed.Fields = []*discordgo.MessageEmbedField{createField("Content", describeContent(deleted), false)}
+	}
+	postModLogEvent(s, m.GuildID, "deletes", embed)
+}
+
+func messageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	if m.GuildID == "" {
+		return
+	}
+	deleted := uncacheMessages(m.ChannelID, m.Messages...)
+	if !modLogWants(m.GuildID, "deletes") {
+		return
+	}
+
+	// oldest first, so it reads like the channel did; snowflakes count up over time
+	sort.Slice(deleted, func(i, j int) bool {
+		first, _ := strconv.ParseUint(deleted[i].ID, 10, 64)
+		second, _ := strconv.ParseUint(deleted[j].ID, 10, 64)
+		return first < second
+	})
+	description := fmt.Sprintf("**Channel**: <#%s>\n", m.ChannelID)
+	for _, message := range deleted {
+		description += fmt.Sprintf("\n**%s**: %s", message.Author.String(), describeContent(message))
+	}
+	if missing := len(m.Messages) - len(deleted); missing > 0 {
+		description += fmt.Sprintf("\n\n%d of the messages weren't cached, so what they said is unknown.", missing)
+	}
+	postModLogEvent(s, m.GuildID, "deletes", &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🗑️%d Messages Deleted", len(m.Messages)),
+		Description: description,
+	})
+}
+
+func channelCreate(s *discordgo.Session, m *discordgo.ChannelCreate) {
+	if m.GuildID == "" || !modLogWants(m.GuildID, "channels") {
+		return
+	}
+	entry := findAuditEntry(s, m.GuildID, m.ID, discordgo.AuditLogActionChannelCreate)
+	postModLogEvent(s, m.GuildID, "channels", &discordgo.MessageEmbed{
+		Title:       "📁Channel Created",
+		Description: fmt.Sprintf("**Channel**: <#%s> (%s)\n", m.ID, m.Name) + describeAuditEntry(s, m.GuildID, entry),
+	})
+}
+
+func channelUpdate(s *discordgo.Session, m *discordgo.ChannelUpdate) {
+	if m.GuildID == "" || !modLogWants(m.GuildID, "channels") {
+		return
+	}
+	entry := findAuditEntry(s, m.GuildID, m.ID, discordgo.AuditLogActionChannelUpdate, discordgo.AuditLogActionChannelOverwriteCreate,
+		discordgo.AuditLogActionChannelOverwriteUpdate, discordgo.AuditLogActionChannelOverwriteDelete)
+	if entry == nil {
+		return
+	}
+
+	changes := describeAuditChanges(entry)
+	if *entry.ActionType != discordgo.AuditLogActionChannelUpdate && entry.Options != nil {
+		target := "<@&" + entry.Options.ID + ">"
+		if entry.Options.Type != nil && *entry.Options.Type == discordgo.AuditLogOptionsTypeMember {
+			target = "<@" + entry.Options.ID + ">"
+		}
+		changes = "**Permissions changed for**: " + target
+	}
+	if changes == "" {
+		return
+	}
+	postModLogEvent(s, m.GuildID, "channels", &discordgo.MessageEmbed{
+		Title:       "📝Channel Updated",
+		Description: fmt.Sprintf("**Channel**: <#%s>\n", m.ID) + describeAuditEntry(s, m.GuildID, entry) + changes,
+	})
+}
+
+func channelDelete(s *discordgo.Session, m *discordgo.ChannelDelete) {
+	if m.GuildID == "" || !modLogWants(m.GuildID, "channels") {
+		return
+	}
+	entry := findAuditEntry(s, m.GuildID, m.ID, discordgo.AuditLogActionChannelDelete)
+	postModLogEvent(s, m.GuildID, "channels", &discordgo.MessageEmbed{
+		Title:       "🗑️Channel Deleted",
+		Description: fmt.Sprintf("**Channel**: #%s\n", m.Name) + describeAuditEntry(s, m.GuildID, entry),
+	})
+}
+
+func guildRoleCreate(s *discordgo.Session, m *discordgo.GuildRoleCreate) {
+	if !modLogWants(m.GuildID, "roles") {
+		return
+	}
+	entry := findAuditEntry(s, m.GuildID, m.Role.ID, discordgo.AuditLogActionRoleCreate)
+	postModLogEvent(s, m.GuildID, "roles", &discordgo.MessageEmbed{
+		Title:       "🏷️Role Created",
+		Description: fmt.Sprintf("**Role**: <@&%s> (%s)\n", m.Role.ID, m.Role.Name) + describeAuditEntry(s, m.GuildID, entry),
+	})
+}
+
+func guildRoleUpdate(s *discordgo.Session, m *discordgo.GuildRoleUpdate) {
+	if !modLogWants(m.GuildID, "roles") {
+		return
+	}
+	// moving roles around updates every role in between, without an audit log entry
+	entry := findAuditEntry(s, m.GuildID, m.Role.ID, discordgo.AuditLogActionRoleUpdate)
+	changes := describeAuditChanges(entry)
+	if changes == "" {
+		return
+	}
+	postModLogEvent(s, m.GuildID, "roles", &discordgo.MessageEmbed{
+		Title:       "📝Role Updated",
+		Description: fmt.Sprintf("**Role**: <@&%s>\n", m.Role.ID) + describeAuditEntry(s, m.GuildID, entry) + changes,
+	})
+}
+
+func guildRoleDelete(s *discordgo.Session, m *discordgo.GuildRoleDelete) {
+	if !modLogWants(m.GuildID, "roles") {
+		return
+	}
+	// the role is gone from the state by now, but the audit log remembers its name
+	entry := findAuditEntry(s, m.GuildID, m.RoleID, discordgo.AuditLogActionRoleDelete)
+	name := m.RoleID
+	if entry != nil {
+		for _, change := range entry.Changes {
+			if change.Key != nil && *change.Key == discordgo.AuditLogChangeKeyName {
+				name = fmt.Sprintf("%v", change.OldValue)
+			}
+		}
+	}
+	postModLogEvent(s, m.GuildID, "roles", &discordgo.MessageEmbed{
+		Title:       "🗑️Role Deleted",
+		Description: fmt.Sprintf("**Role**: %s\n", name) + describeAuditEntry(s, m.GuildID, entry),
+	})
+}
+
+func guildEmojisUpdate(s *discordgo.Session, m *discordgo.GuildEmojisUpdate) {
+	if !modLogWants(m.GuildID, "emojis") {
+		return
+	}
+	// the event only lists the emojis the server has now, so the audit log says what changed
+	entry := findAuditEntry(s, m.GuildID, "", discordgo.AuditLogActionEmojiCreate, discordgo.AuditLogActionEmojiUpdate, discordgo.AuditLogActionEmojiDelete)
+	if entry == nil {
+		return
+	}
+	title := "📝Emoji Updated"
+	switch *entry.ActionType {
+	case discordgo.AuditLogActionEmojiCreate:
+		title = "😀Emoji Added"
+	case discordgo.AuditLogActionEmojiDelete:
+		title = "🗑️Emoji Removed"
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: describeAuditEntry(s, m.GuildID, entry) + describeAuditChanges(entry),
+	}
+	if *entry.ActionType != discordgo.AuditLogActionEmojiDelete {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: discordgo.EndpointEmoji(entry.TargetID)}
+	}
+	postModLogEvent(s, m.GuildID, "emojis", embed)
+}
+
+/**
+Lists the nickname and role changes between two versions of a member, one per
+line. Other changes, like avatars and boosts, aren't logged.
+*/
+func describeMemberChanges(s *discordgo.Session, guildID string, before *discordgo.Member, after *discordgo.Member) string {
+	var lines []string
+	if before.Nick != after.Nick {
+		lines = append(lines, fmt.Sprintf("**%s**: %s → %s", auditChangeLabels["nick"], formatAuditValue(before.Nick), formatAuditValue(after.Nick)))
+	}
+	roleNames := func(ids []string, without []string) string {
+		skip := make(map[string]bool)
+		for _, id := range without {
+			skip[id] = true
+		}
+		var names []string
+		for _, id := range ids {
+			if skip[id] {
+				continue
+			}
+			if role, err := s.State.Role(guildID, id); err == nil {
+				names = append(names, role.Name)
+			} else {
+				names = append(names, "<@&"+id+">")
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	if added := roleNames(after.Roles, before.Roles); added != "" {
+		lines = append(lines, fmt.Sprintf("**%s**: %s", auditChangeLabels["$add"], added))
+	}
+	if removed := roleNames(before.Roles, after.Roles); removed != "" {
+		lines = append(lines, fmt.Sprintf("**%s**: %s", auditChangeLabels["$remove"], removed))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func guildMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	// members that weren't in the state yet can't be compared; they are from now on
+	if m.User == nil || m.BeforeUpdate == nil || !modLogWants(m.GuildID, "members") {
+		return
+	}
+	// only ask the audit log who made the change once there is one worth logging
+	changes := describeMemberChanges(s, m.GuildID, m.BeforeUpdate, m.Member)
+	if changes == "" {
+		return
+	}
+	entry := findAuditEntry(s, m.GuildID, m.User.ID, discordgo.AuditLogActionMemberUpdate, discordgo.AuditLogActionMemberRoleUpdate)
+	postModLogEvent(s, m.GuildID, "members", &discordgo.MessageEmbed{
+		Title:       "👤Member Updated " + m.User.String(),
+		Description: fmt.Sprintf("**Member**: <@%s>\n", m.User.ID) + describeAuditEntry(s, m.GuildID, entry) + changes,
+		Thumbnail:   &discordgo.MessageEmbedThumbnail{URL: m.User.AvatarURL("512")},
+	})
+}
+
+func guildUpdate(s *discordgo.Session, m *discordgo.GuildUpdate) {
+	if !modLogWants(m.ID, "server") {
+		return
+	}
+	entry := findAuditEntry(s, m.ID, m.ID, discordgo.AuditLogActionGuildUpdate)
+	changes := describeAuditChanges(entry)
+	if changes == "" {
+		return
+	}
+	postModLogEvent(s, m.ID, "server", &discordgo.MessageEmbed{
+		Title:       "⚙️Server Updated",
+		Description: describeAuditEntry(s, m.ID, entry) + changes,
+	})
+}
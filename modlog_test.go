@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that message, role and member changes are posted to the mod log, and that
+guilds can turn events off.
+**/
+func TestModLog(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+	auditLogDelay = 0
+	t.Cleanup(func() { auditLogDelay = 2 * time.Second })
+
+	admins := fake.AddRole("admins", discordgo.PermissionManageServer)
+	admin := fake.AddUser("admin", admins.ID)
+	member := fake.AddUser("member")
+	modLog := fake.AddChannel("mod-log")
+	storage.SetModLogChannel(fake.Guild.ID, modLog.ID)
+	lastEntry := func() *discordgo.MessageEmbed {
+		logged := fake.SentTo(modLog.ID)
+		if len(logged) == 0 || len(logged[len(logged)-1].Embeds) != 1 {
+			t.Fatalf("Expected an entry in the mod log, got %+v", logged)
+		}
+		return logged[len(logged)-1].Embeds[0]
+	}
+
+	t.Run("deleted messages are logged with what they said", func(t *testing.T) {
+		message := fake.SendMessage(member, "something regrettable")
+		fake.Inject(&discordgo.MessageDelete{Message: &discordgo.Message{ID: message.ID, ChannelID: message.ChannelID, GuildID: fake.Guild.ID}})
+		embed := lastEntry()
+		if embed.Title != "🗑️Message Deleted" || len(embed.Fields) != 1 || embed.Fields[0].Value != "something regrettable" || !strings.Contains(embed.Description, "<@"+member.ID+">") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+
+		fake.Inject(&discordgo.MessageDelete{Message: &discordgo.Message{ID: "12345", ChannelID: fake.Channel.ID, GuildID: fake.Guild.ID}})
+		if embed := lastEntry(); !strings.Contains(embed.Description, "The message wasn't cached") {
+			t.Logf("Unexpected entry for an uncached message %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("edits show the message before and after", func(t *testing.T) {
+		message := fake.SendMessage(member, "teh first draft")
+		fake.Inject(&discordgo.MessageUpdate{Message: &discordgo.Message{ID: message.ID, ChannelID: message.ChannelID, GuildID: fake.Guild.ID, Author: member, Content: "the first draft"}})
+		embed := lastEntry()
+		if embed.Title != "✏️Message Edited" || len(embed.Fields) != 2 || embed.Fields[0].Value != "teh first draft" || embed.Fields[1].Value != "the first draft" {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("bulk deletes are logged as one transcript", func(t *testing.T) {
+		first := fake.SendMessage(member, "first")
+		second := fake.SendMessage(admin, "second")
+		fake.Inject(&discordgo.MessageDeleteBulk{Messages: []string{second.ID, first.ID, "12345"}, ChannelID: fake.Channel.ID, GuildID: fake.Guild.ID})
+		embed := lastEntry()
+		if embed.Title != "🗑️3 Messages Deleted" || !strings.Contains(embed.Description, "**member#1234**: first\n**admin#1234**: second") || !strings.Contains(embed.Description, "1 of the messages weren't cached") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("role and member changes come from the audit log", func(t *testing.T) {
+		role := fake.AddRole("helpers", 0)
+		update := discordgo.AuditLogActionRoleUpdate
+		name := discordgo.AuditLogChangeKeyName
+		fake.AddAuditLogEntry(&discordgo.AuditLogEntry{TargetID: role.ID, UserID: admin.ID, ActionType: &update, Changes: []*discordgo.AuditLogChange{
+			{Key: &name, OldValue: "helpers", NewValue: "trusted helpers"},
+		}})
+		fake.Inject(&discordgo.GuildRoleUpdate{GuildRole: &discordgo.GuildRole{GuildID: fake.Guild.ID, Role: role}})
+		embed := lastEntry()
+		if embed.Title != "📝Role Updated" || !strings.Contains(embed.Description, "**Actor**: admin#1234") || !strings.Contains(embed.Description, "**Name**: `helpers` → `trusted helpers`") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+
+		// the same entry is never logged twice
+		count := len(fake.SentTo(modLog.ID))
+		fake.Inject(&discordgo.GuildRoleUpdate{GuildRole: &discordgo.GuildRole{GuildID: fake.Guild.ID, Role: role}})
+		if len(fake.SentTo(modLog.ID)) != count {
+			t.Logf("The role update was logged again: %+v", lastEntry())
+			t.Fail()
+		}
+
+		roleUpdate := discordgo.AuditLogActionMemberRoleUpdate
+		add := discordgo.AuditLogChangeKeyRoleAdd
+		fake.AddAuditLogEntry(&discordgo.AuditLogEntry{TargetID: member.ID, UserID: admin.ID, ActionType: &roleUpdate, Changes: []*discordgo.AuditLogChange{
+			{Key: &add, NewValue: []interface{}{map[string]interface{}{"id": role.ID, "name": "trusted helpers"}}},
+		}})
+		fake.Inject(&discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: fake.Guild.ID, User: member, Roles: []string{role.ID}}})
+		embed = lastEntry()
+		if embed.Title != "👤Member Updated member#1234" || !strings.Contains(embed.Description, "**Actor**: admin#1234") || !strings.Contains(embed.Description, "**Roles added**: helpers") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("member changes are found without the audit log", func(t *testing.T) {
+		fake.Inject(&discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: fake.Guild.ID, User: member, Nick: "O'Brien"}})
+		embed := lastEntry()
+		if strings.Contains(embed.Description, "**Actor**") || !strings.Contains(embed.Description, "**Nickname**: none → `O'Brien`") || !strings.Contains(embed.Description, "**Roles removed**: helpers") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+
+		// an avatar change isn't logged, so it leaves the audit log entry for the next change
+		memberUpdate := discordgo.AuditLogActionMemberUpdate
+		fake.AddAuditLogEntry(&discordgo.AuditLogEntry{TargetID: member.ID, UserID: admin.ID, ActionType: &memberUpdate})
+		count := len(fake.SentTo(modLog.ID))
+		fake.Inject(&discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: fake.Guild.ID, User: member, Nick: "O'Brien", Avatar: "a1b2c3"}})
+		if len(fake.SentTo(modLog.ID)) != count {
+			t.Logf("The avatar change shouldn't have been logged: %+v", lastEntry())
+			t.Fail()
+		}
+		fake.Inject(&discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: fake.Guild.ID, User: member}})
+		if embed = lastEntry(); !strings.Contains(embed.Description, "**Actor**: admin#1234") || !strings.Contains(embed.Description, "**Nickname**: `O'Brien` → none") {
+			t.Logf("Unexpected entry %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("events can be turned off", func(t *testing.T) {
+		message := fake.SendMessage(admin, "~modlog disable edits")
+		fake.WaitFor("the success reaction", func() bool {
+			for _, reaction := range fake.Reactions() {
+				if reaction.MessageID == message.ID && reaction.Emoji == "✔️" {
+					return true
+				}
+			}
+			return false
+		})
+		events := fake.Reply(admin, "~modlog events")
+		if len(events.Embeds) != 1 || !strings.Contains(events.Embeds[0].Description, "❌ **edits**") || !strings.Contains(events.Embeds[0].Description, "✅ **deletes**") {
+			t.Logf("Unexpected event list %+v", events.Embeds)
+			t.Fail()
+		}
+
+		count := len(fake.SentTo(modLog.ID))
+		edited := fake.SendMessage(member, "before")
+		fake.Inject(&discordgo.MessageUpdate{Message: &discordgo.Message{ID: edited.ID, ChannelID: edited.ChannelID, GuildID: fake.Guild.ID, Author: member, Content: "after"}})
+		if len(fake.SentTo(modLog.ID)) != count {
+			t.Logf("The edit shouldn't have been logged: %+v", lastEntry())
+			t.Fail()
+		}
+		fake.Inject(&discordgo.MessageDelete{Message: &discordgo.Message{ID: edited.ID, ChannelID: edited.ChannelID, GuildID: fake.Guild.ID}})
+		if embed := lastEntry(); len(embed.Fields) != 1 || embed.Fields[0].Value != "after" {
+			t.Logf("The delete should still be logged with the edited text, got %+v", embed)
+			t.Fail()
+		}
+	})
+}
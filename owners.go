@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// IDs of the users who run this bot, from BOT_OWNERS and/or the file at OWNERS_FILE
+var owners = make(map[string]bool)
+var ownersLock sync.RWMutex
+
+/**
+Reads the owner IDs from the environment and the owners file. IDs may be
+separated by commas or whitespace.
+*/
+func loadOwners() {
+	raw := os.Getenv("BOT_OWNERS")
+	if ownersFile := os.Getenv("OWNERS_FILE"); ownersFile != "" {
+		contents, err := ioutil.ReadFile(ownersFile)
+		if err != nil {
+			logError("Unable to read the owners file! " + err.Error())
+		} else {
+			raw += "\n" + string(contents)
+		}
+	}
+
+	loaded := make(map[string]bool)
+	for _, id := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		if !snowflakeRegex.MatchString(id) {
+			logWarning("Ignoring invalid owner ID '" + id + "'")
+			continue
+		}
+		loaded[id] = true
+	}
+	if len(loaded) == 0 {
+		logWarning("No bot owners are configured, so owner commands are disabled")
+	}
+
+	ownersLock.Lock()
+	owners = loaded
+	ownersLock.Unlock()
+	logInfo(fmt.Sprintf("Loaded %d bot owners", len(loaded)))
+}
+
+/**
+Returns whether the user is one of the bot's owners.
+*/
+func isOwner(userID string) bool {
+	ownersLock.RLock()
+	defer ownersLock.RUnlock()
+	return owners[userID]
+}
+
+/**
+Reloads the config file and the owners, and forgets every cached guild setting
+so they are read from the database again. If the config file is invalid, the
+settings in use are kept and the error is returned; the rest is still reloaded.
+*/
+func reloadConfig() error {
+	err := reloadConfigFile()
+	loadOwners()
+
+	guildPrefixesLock.Lock()
+	guildPrefixes = make(map[string]string)
+	guildPrefixesLock.Unlock()
+
+	commandRulesLock.Lock()
+	commandRules = make(map[string][]CommandRule)
+	commandRulesLock.Unlock()
+
+	permissionOverridesLock.Lock()
+	permissionOverrides = make(map[string][]PermissionOverride)
+	permissionOverridesLock.Unlock()
+	return err
+}
+
+/**
+Replaces the running process with a fresh copy of itself.
+*/
+func restartBot(s *discordgo.Session) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	s.Close()
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
+
+/**
+Sends a message to the mod log channel of every guild that has one. Returns how
+many channels it was sent to.
+*/
+func broadcastToModLogs(s *discordgo.Session, message string) (int, error) {
+	channels, err := storage.GetModLogChannels()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, modLogData := range channels {
+		_, err = sendToChannel(s, modLogData.ChannelID, &discordgo.MessageSend{Content: message})
+		if err != nil {
+			logWarning("Failed to broadcast to " + modLogData.GuildID + "; " + err.Error())
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+/**
+Commands for the people running the bot: shutting it down or restarting it,
+reloading its configuration and managing the guilds it is in.
+**/
+func handleOwner(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	switch args.Subcommand {
+	case "shutdown":
+		logWarning("Shutdown requested by " + m.Author.ID)
+		sendSuccess(s, m, "Shutting down.")
+		s.Close()
+		os.Exit(0)
+	case "restart":
+		logWarning("Restart requested by " + m.Author.ID)
+		sendSuccess(s, m, "Restarting.")
+		err := restartBot(s)
+		if err != nil {
+			logError("Failed to restart! " + err.Error())
+			os.Exit(1)
+		}
+	case "reload":
+		err := reloadConfig()
+		if err != nil {
+			logError("Failed to reload the configuration! " + err.Error())
+			attemptSendMsg(s, m, "The config file has problems, so I kept the previous settings:\n```\n"+err.Error()+"\n```")
+			return
+		}
+		sendSuccess(s, m, "Reloaded the configuration and owner list and cleared cached guild settings.")
+	case "leave":
+		guildID := args.Values["guild"]
+		guild, err := s.Guild(guildID)
+		if err != nil {
+			logError("Failed to find guild " + guildID + "! " + err.Error())
+			attemptSendMsg(s, m, "I'm not in a guild with that ID.")
+			return
+		}
+		err = s.GuildLeave(guildID)
+		if err != nil {
+			logError("Failed to leave guild " + guildID + "! " + err.Error())
+			sendError(s, m, "owner", Discord)
+			return
+		}
+		sendSuccess(s, m, fmt.Sprintf("Left **%s**.", guild.Name))
+	case "guilds":
+		s.State.RLock()
+		guilds := append([]*discordgo.Guild{}, s.State.Guilds...)
+		s.State.RUnlock()
+		sort.Slice(guilds, func(i, j int) bool {
+			return guilds[i].MemberCount > guilds[j].MemberCount
+		})
+
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = fmt.Sprintf("In %d Guilds", len(guilds))
+		for _, guild := range guilds {
+			line := fmt.Sprintf("**%s** (`%s`) - %d members\n", guild.Name, guild.ID, guild.MemberCount)
+			if len(embed.Description)+len(line) > 4000 {
+				embed.Description += "..."
+				break
+			}
+			embed.Description += line
+		}
+		_, err := sendEmbed(s, m, &embed)
+		if err != nil {
+			logError("Failed to send guild list! " + err.Error())
+		}
+	case "broadcast":
+		sent, err := broadcastToModLogs(s, args.Values["message"])
+		if err != nil {
+			logError("Failed to read mod log channels! " + err.Error())
+			sendError(s, m, "owner", Database)
+			return
+		}
+		sendSuccess(s, m, fmt.Sprintf("Sent to %d mod log channels.", sent))
+	}
+}
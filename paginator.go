@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var paginatorTable string
+
+// how long a paginated message can be flipped through after it is sent
+const paginatorLifetime = 30 * time.Minute
+
+// custom IDs of the paginator components start with this
+const paginatorPrefix = "paginator:"
+
+// a select menu can hold at most this many options
+const maxPageOptions = 25
+
+/**
+A message whose embed can be flipped through with buttons and a page menu. The
+pages are saved with it, so paginators keep working after the bot restarts.
+Only the person who asked for it, or a moderator, can turn the pages.
+*/
+type Paginator struct {
+	MessageID string
+	ChannelID string
+	GuildID   string
+	AuthorID  string
+	Pages     []*discordgo.MessageEmbed
+	Page      int
+	ExpiresAt time.Time
+}
+
+var (
+	// active paginators by message ID; every change is also saved to storage
+	paginators = make(map[string]*Paginator)
+	// held while a paginator is looked up or changed, including the message edit
+	paginatorLock sync.Mutex
+)
+
+/**
+Loads the paginators that were active when the bot last stopped.
+*/
+func loadPaginators() error {
+	paginatorLock.Lock()
+	defer paginatorLock.Unlock()
+	saved, err := storage.GetPaginators()
+	if err != nil {
+		return err
+	}
+	paginators = make(map[string]*Paginator)
+	for i := range saved {
+		paginators[saved[i].MessageID] = &saved[i]
+	}
+	logInfo(fmt.Sprintf("Loaded %d paginators", len(saved)))
+	return nil
+}
+
+/**
+Sends the first page back to wherever the command was invoked. If there is more
+than one page, the message gets buttons and a menu to flip through them.
+*/
+func sendPaginated(s *discordgo.Session, m *Invocation, pages []*discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if len(pages) == 1 {
+		return respond(s, m, &discordgo.MessageSend{Embeds: pages})
+	}
+
+	paginator := &Paginator{
+		ChannelID: m.ChannelID,
+		GuildID:   m.GuildID,
+		AuthorID:  m.Author.ID,
+		Pages:     pages,
+		ExpiresAt: time.Now().Add(paginatorLifetime),
+	}
+	message, err := respond(s, m, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{pages[0]},
+		Components: paginator.components(),
+	})
+	if err != nil {
+		return message, err
+	}
+
+	paginator.MessageID = message.ID
+	paginator.ChannelID = message.ChannelID
+	err = storage.AddPaginator(*paginator)
+	if err != nil {
+		// the first page was still sent, it just can't be flipped
+		logError("Unable to save paginator! " + err.Error())
+		removeControls(s, paginator)
+		return message, nil
+	}
+	paginatorLock.Lock()
+	paginators[message.ID] = paginator
+	paginatorLock.Unlock()
+	return message, nil
+}
+
+/**
+Returns the buttons and page menu for the paginator's current page.
+*/
+func (paginator *Paginator) components() []discordgo.MessageComponent {
+	first := paginator.Page == 0
+	last := paginator.Page == len(paginator.Pages)-1
+	buttons := discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Emoji: discordgo.ComponentEmoji{Name: "⏮️"}, Style: discordgo.SecondaryButton, CustomID: paginatorPrefix + "first", Disabled: first},
+		discordgo.Button{Emoji: discordgo.ComponentEmoji{Name: "◀️"}, Style: discordgo.PrimaryButton, CustomID: paginatorPrefix + "previous", Disabled: first},
+		discordgo.Button{Emoji: discordgo.ComponentEmoji{Name: "▶️"}, Style: discordgo.PrimaryButton, CustomID: paginatorPrefix + "next", Disabled: last},
+		discordgo.Button{Emoji: discordgo.ComponentEmoji{Name: "⏭️"}, Style: discordgo.SecondaryButton, CustomID: paginatorPrefix + "last", Disabled: last},
+		discordgo.Button{Emoji: discordgo.ComponentEmoji{Name: "⏹️"}, Style: discordgo.DangerButton, CustomID: paginatorPrefix + "stop"},
+	}}
+
+	// with too many pages for one menu, offer the ones around the current page
+	from := paginator.Page - maxPageOptions/2
+	if from > len(paginator.Pages)-maxPageOptions {
+		from = len(paginator.Pages) - maxPageOptions
+	}
+	if from < 0 {
+		from = 0
+	}
+	var options []discordgo.SelectMenuOption
+	for page := from; page < len(paginator.Pages) && len(options) < maxPageOptions; page++ {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:   fmt.Sprintf("Page %d of %d", page+1, len(paginator.Pages)),
+			Value:   strconv.Itoa(page),
+			Default: page == paginator.Page,
+		})
+	}
+	menu := discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.SelectMenu{CustomID: paginatorPrefix + "page", Placeholder: "Jump to a page", Options: options},
+	}}
+	return []discordgo.MessageComponent{buttons, menu}
+}
+
+/**
+Returns the page a control leads to from the current one. The page menu sends
+the page it was set to as its value.
+*/
+func (paginator *Paginator) pageFor(control string, values []string) int {
+	switch control {
+	case "first":
+		return 0
+	case "previous":
+		if paginator.Page > 0 {
+			return paginator.Page - 1
+		}
+	case "next":
+		if paginator.Page < len(paginator.Pages)-1 {
+			return paginator.Page + 1
+		}
+	case "last":
+		return len(paginator.Pages) - 1
+	case "page":
+		if len(values) == 1 {
+			page, err := strconv.Atoi(values[0])
+			if err == nil && page >= 0 && page < len(paginator.Pages) {
+				return page
+			}
+		}
+	}
+	return paginator.Page
+}
+
+/**
+Whether the user who clicked a control may turn the paginator's pages: the
+person who asked for it can, and so can anyone who can manage messages there.
+*/
+func (paginator *Paginator) canTurn(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil {
+		return i.User != nil && i.User.ID == paginator.AuthorID
+	}
+	return i.Member.User.ID == paginator.AuthorID || permissionsInclude(i.Member.Permissions, discordgo.PermissionManageMessages)
+}
+
+/**
+Forgets a paginator so its controls no longer do anything. The caller must hold
+paginatorLock.
+*/
+func forgetPaginator(paginator *Paginator) {
+	delete(paginators, paginator.MessageID)
+	err := storage.RemovePaginator(paginator.MessageID)
+	if err != nil {
+		logError("Unable to remove paginator! " + err.Error())
+	}
+}
+
+/**
+Removes the buttons and page menu from a paginated message, leaving the page it
+is on.
+*/
+func removeControls(s *discordgo.Session, paginator *Paginator) {
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         paginator.MessageID,
+		Channel:    paginator.ChannelID,
+		Embeds:     []*discordgo.MessageEmbed{paginator.Pages[paginator.Page]},
+		Components: []discordgo.MessageComponent{},
+	})
+	if err != nil {
+		logError("Failed to remove the controls from a paginated message! " + err.Error())
+	}
+}
+
+/**
+Handles clicks on the paginator controls. The message is updated in the response
+to the interaction, so nothing is left for the user to clean up.
+*/
+func navigatePaginator(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	paginatorLock.Lock()
+	defer paginatorLock.Unlock()
+	paginator, ok := paginators[i.Message.ID]
+	if !ok {
+		// the paginator expired or was stopped, so its controls are stale
+		respondToComponent(s, i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{Embeds: i.Message.Embeds, Components: []discordgo.MessageComponent{}},
+		})
+		return
+	}
+	if !paginator.canTurn(i) {
+		respondToComponent(s, i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Only the person who asked for this, or a moderator, can turn its pages.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	control := strings.TrimPrefix(data.CustomID, paginatorPrefix)
+	if control == "stop" {
+		forgetPaginator(paginator)
+		respondToComponent(s, i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{paginator.Pages[paginator.Page]}, Components: []discordgo.MessageComponent{}},
+		})
+		logSuccess("Stopped paginator " + paginator.MessageID)
+		return
+	}
+
+	page := paginator.pageFor(control, data.Values)
+	previous := paginator.Page
+	paginator.Page = page
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{paginator.Pages[page]}, Components: paginator.components()},
+	})
+	if err != nil {
+		paginator.Page = previous
+		logError("Failed to update paginated message! " + err.Error())
+		return
+	}
+	if page == previous {
+		return
+	}
+	err = storage.SetPaginatorPage(paginator.MessageID, page)
+	if err != nil {
+		logError("Unable to save paginator page! " + err.Error())
+	}
+	logDebug(fmt.Sprintf("Paginator %s is on page %d", paginator.MessageID, page+1))
+}
+
+/**
+Responds to a component interaction, logging if that fails.
+*/
+func respondToComponent(s *discordgo.Session, i *discordgo.InteractionCreate, response *discordgo.InteractionResponse) {
+	err := s.InteractionRespond(i.Interaction, response)
+	if err != nil {
+		logError("Failed to respond to component interaction! " + err.Error())
+	}
+}
+
+/**
+Stops every paginator that has expired by the given time.
+*/
+func expirePaginators(s *discordgo.Session, now time.Time) {
+	paginatorLock.Lock()
+	defer paginatorLock.Unlock()
+	for _, paginator := range paginators {
+		if now.After(paginator.ExpiresAt) {
+			forgetPaginator(paginator)
+			removeControls(s, paginator)
+			logInfo("Paginator " + paginator.MessageID + " expired")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that paginated messages can be flipped through by the right people,
+survive a restart and stop once they expire.
+**/
+func TestPaginator(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	reader := fake.AddUser("reader")
+
+	var pages []*discordgo.MessageEmbed
+	for i := 1; i <= 5; i++ {
+		pages = append(pages, &discordgo.MessageEmbed{Title: fmt.Sprintf("Page %d", i)})
+	}
+	invocation := &Invocation{ChannelID: fake.Channel.ID, GuildID: fake.Guild.ID, Author: reader}
+	message, err := sendPaginated(fake.Session, invocation, pages)
+	if err != nil {
+		t.Fatalf("Unable to send the paginated message: %s", err.Error())
+	}
+	waitForPage := func(title string) {
+		t.Helper()
+		fake.WaitFor(title, func() bool {
+			embeds := fake.Message(message.ChannelID, message.ID).Embeds
+			return len(embeds) == 1 && embeds[0].Title == title
+		})
+	}
+
+	t.Run("buttons flip through the pages", func(t *testing.T) {
+		if components := fake.Message(message.ChannelID, message.ID).Components; len(components) != 2 {
+			t.Logf("Expected a row of buttons and a page menu, got %+v", components)
+			t.Fail()
+		}
+		fake.Click(reader, message, "paginator:next")
+		waitForPage("Page 2")
+		fake.Click(reader, message, "paginator:last")
+		waitForPage("Page 5")
+		fake.Click(reader, message, "paginator:previous")
+		waitForPage("Page 4")
+		fake.Click(reader, message, "paginator:first")
+		waitForPage("Page 1")
+	})
+
+	t.Run("the page menu jumps to a page", func(t *testing.T) {
+		fake.Click(reader, message, "paginator:page", "2")
+		waitForPage("Page 3")
+	})
+
+	t.Run("only the reader or a moderator can turn the pages", func(t *testing.T) {
+		stranger := fake.AddUser("stranger")
+		fake.Click(stranger, message, "paginator:next")
+		refusal := fake.WaitForMessage(2)
+		if refusal.Flags&discordgo.MessageFlagsEphemeral == 0 || !strings.HasPrefix(refusal.Content, "Only the person who asked") {
+			t.Logf("Expected an ephemeral refusal, got %+v", refusal)
+			t.Fail()
+		}
+		waitForPage("Page 3")
+
+		moderators := fake.AddRole("moderators", discordgo.PermissionManageMessages)
+		fake.Click(fake.AddUser("mod", moderators.ID), message, "paginator:next")
+		waitForPage("Page 4")
+	})
+
+	t.Run("paginators survive a restart", func(t *testing.T) {
+		// loading replaces the paginators in memory with the saved ones
+		if err := loadPaginators(); err != nil {
+			t.Fatalf("Unable to load the paginators: %s", err.Error())
+		}
+		fake.Click(reader, message, "paginator:previous")
+		waitForPage("Page 3")
+	})
+
+	t.Run("expired paginators lose their controls", func(t *testing.T) {
+		expirePaginators(fake.Session, time.Now().Add(paginatorLifetime+time.Minute))
+		if saved, _ := storage.GetPaginators(); len(saved) != 0 {
+			t.Logf("The paginator should have been removed, got %+v", saved)
+			t.Fail()
+		}
+		if components := fake.Message(message.ChannelID, message.ID).Components; len(components) != 0 {
+			t.Logf("The controls should have been removed, got %+v", components)
+			t.Fail()
+		}
+	})
+
+	t.Run("the stop button removes the controls", func(t *testing.T) {
+		stopped, err := sendPaginated(fake.Session, invocation, pages)
+		if err != nil {
+			t.Fatalf("Unable to send the paginated message: %s", err.Error())
+		}
+		fake.Click(reader, stopped, "paginator:stop")
+		fake.WaitFor("the controls to be removed", func() bool {
+			return len(fake.Message(stopped.ChannelID, stopped.ID).Components) == 0
+		})
+		if saved, _ := storage.GetPaginators(); len(saved) != 0 {
+			t.Logf("The paginator should have been removed, got %+v", saved)
+			t.Fail()
+		}
+	})
+
+	t.Run("a single page isn't paginated", func(t *testing.T) {
+		single, _ := sendPaginated(fake.Session, invocation, pages[:1])
+		if saved, _ := storage.GetPaginators(); len(saved) != 0 || len(fake.Message(single.ChannelID, single.ID).Components) != 0 {
+			t.Logf("A single page shouldn't be paginated, got %+v", saved)
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var permissionOverrideTable string
+
+// overrides are checked on every command, so keep them in memory once loaded
+var permissionOverrides = make(map[string][]PermissionOverride)
+var permissionOverridesLock sync.RWMutex
+
+/**
+Allows or denies a role or a single member the use of a command in a guild,
+regardless of their Discord permissions.
+*/
+type PermissionOverride struct {
+	GuildID  string `json:"guild_id"`
+	Command  string `json:"command"`
+	TargetID string `json:"target_id"`
+	IsRole   bool   `json:"is_role"`
+	Allow    bool   `json:"allow"`
+}
+
+/**
+Everything needed to decide which commands a member may use.
+*/
+type commandAccess struct {
+	owner     bool
+	perms     int64
+	userID    string
+	roles     []string
+	overrides []PermissionOverride
+}
+
+/**
+Returns the permission overrides for the guild.
+*/
+func getPermissionOverrides(guildID string) []PermissionOverride {
+	permissionOverridesLock.RLock()
+	overrides, ok := permissionOverrides[guildID]
+	permissionOverridesLock.RUnlock()
+	if ok {
+		return overrides
+	}
+
+	overrides, err := storage.GetPermissionOverrides(guildID)
+	if err != nil {
+		// don't cache the failure so the next command tries again
+		logError("Unable to read permission overrides, only using Discord permissions: " + err.Error())
+		return nil
+	}
+
+	permissionOverridesLock.Lock()
+	permissionOverrides[guildID] = overrides
+	permissionOverridesLock.Unlock()
+	return overrides
+}
+
+/**
+Forgets the cached overrides of the guild so they are reloaded on the next command.
+*/
+func invalidatePermissionOverrides(guildID string) {
+	permissionOverridesLock.Lock()
+	delete(permissionOverrides, guildID)
+	permissionOverridesLock.Unlock()
+}
+
+/**
+Gathers the invoking member's permissions, roles and the guild's overrides. If
+Discord can't tell us the member's permissions, they are treated as having none.
+*/
+func accessFor(s *discordgo.Session, m *Invocation) commandAccess {
+	access := commandAccess{owner: isOwner(m.Author.ID), userID: m.Author.ID, overrides: getPermissionOverrides(m.GuildID)}
+
+	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		logWarning("Failed to acquire user permissions! " + err.Error())
+	} else {
+		access.perms = perms
+	}
+
+	member := m.Member
+	if member == nil {
+		member, err = s.GuildMember(m.GuildID, m.Author.ID)
+		if err != nil {
+			logWarning("Failed to acquire member roles! " + err.Error())
+		}
+	}
+	if member != nil {
+		access.roles = append(access.roles, member.Roles...)
+	}
+	// everyone has the @everyone role, whose ID is the guild's, though Discord doesn't list it
+	access.roles = append(access.roles, m.GuildID)
+	return access
+}
+
+/**
+Decides whether the member may use the command. Owner commands are only for
+the bot's owners, and administrators can use everything else. Otherwise an
+override for the member wins, then an override for any of their roles
+(allowing beats denying), and finally the command's Discord permission is checked.
+*/
+func (access commandAccess) canUse(name string) bool {
+	return access.allows(name, commandList[name].permission)
+}
+
+/**
+Decides like canUse, but with the Discord permission a particular use of the
+command needs, e.g. one of its subcommands.
+*/
+func (access commandAccess) allows(name string, permission int64) bool {
+	if commandList[name].ownerOnly {
+		return access.owner
+	}
+	if access.perms&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+
+	roleAllowed := false
+	roleDenied := false
+	for _, override := range access.overrides {
+		if override.Command != name {
+			continue
+		}
+		if !override.IsRole {
+			if override.TargetID == access.userID {
+				return override.Allow
+			}
+			continue
+		}
+		for _, role := range access.roles {
+			if override.TargetID == role {
+				if override.Allow {
+					roleAllowed = true
+				} else {
+					roleDenied = true
+				}
+			}
+		}
+	}
+	if roleAllowed {
+		return true
+	}
+	if roleDenied {
+		return false
+	}
+
+	return permission == 0 || permissionsInclude(access.perms, permission)
+}
+
+/**
+Describes the overrides for each command in the guild, for ~perms list.
+*/
+func describePermissionOverrides(overrides []PermissionOverride, command string) []*discordgo.MessageEmbedField {
+	lines := make(map[string][]string)
+	var names []string
+	for _, override := range overrides {
+		if command != "" && override.Command != command {
+			continue
+		}
+		if _, ok := lines[override.Command]; !ok {
+			names = append(names, override.Command)
+		}
+		target := "<@" + override.TargetID + ">"
+		if override.IsRole {
+			target = "<@&" + override.TargetID + ">"
+		}
+		state := "Denied"
+		if override.Allow {
+			state = "Allowed"
+		}
+		lines[override.Command] = append(lines[override.Command], state+": "+target)
+	}
+	sort.Strings(names)
+
+	var contents []*discordgo.MessageEmbedField
+	for _, name := range names {
+		contents = append(contents, createField(name, strings.Join(lines[name], "\n"), false))
+	}
+	return contents
+}
+
+/**
+Returns whether the ID belongs to a role in the guild rather than a member.
+*/
+func isGuildRole(s *discordgo.Session, guildID string, targetID string) (bool, error) {
+	if _, err := s.State.Role(guildID, targetID); err == nil {
+		return true, nil
+	}
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role.ID == targetID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/**
+Lets roles or members use commands they otherwise couldn't, or stops them from
+using commands they otherwise could.
+**/
+func handlePerms(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+
+	command := ""
+	if requested, ok := args.Values["command"]; ok {
+		command = resolveCommand(requested)
+		if cmd, exists := commandList[command]; !exists || cmd.ownerOnly {
+			attemptSendMsg(s, m, fmt.Sprintf("There is no command called `%s`.", requested))
+			return
+		}
+	}
+
+	switch args.Subcommand {
+	case "list":
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = "Permission Overrides"
+		embed.Fields = describePermissionOverrides(getPermissionOverrides(m.GuildID), command)
+		if len(embed.Fields) == 0 {
+			embed.Description = "Commands only require their usual Discord permissions."
+		}
+		_, err := sendEmbed(s, m, &embed)
+		if err != nil {
+			logError("Failed to send permission overrides embed! " + err.Error())
+		}
+	case "allow", "deny":
+		targetID := args.Values["target"]
+		isRole, err := isGuildRole(s, m.GuildID, targetID)
+		if err != nil {
+			logError("Failed to retrieve guild roles! " + err.Error())
+			sendError(s, m, "perms", Discord)
+			return
+		}
+		err = storage.SetPermissionOverride(PermissionOverride{GuildID: m.GuildID, Command: command, TargetID: targetID, IsRole: isRole, Allow: args.Subcommand == "allow"})
+		invalidatePermissionOverrides(m.GuildID)
+		if err != nil {
+			logError("Couldn't update permission override! " + err.Error())
+			sendError(s, m, "perms", Database)
+			return
+		}
+		logSuccess("Updated permission override")
+		sendSuccess(s, m, "")
+	case "reset":
+		var err error
+		if targetID, hasTarget := args.Values["target"]; hasTarget {
+			err = storage.RemovePermissionOverride(m.GuildID, command, targetID)
+		} else {
+			err = storage.RemovePermissionOverrides(m.GuildID, command)
+		}
+		invalidatePermissionOverrides(m.GuildID)
+		if err != nil {
+			logError("Couldn't remove permission overrides! " + err.Error())
+			sendError(s, m, "perms", Database)
+			return
+		}
+		logSuccess("Removed permission overrides")
+		sendSuccess(s, m, "")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that permission overrides are evaluated before the usual Discord
+permission checks.
+**/
+func TestPermissionOverrides(t *testing.T) {
+	initCommandInfo()
+	overrides := []PermissionOverride{
+		{GuildID: "1", Command: "purge", TargetID: "helpers", IsRole: true, Allow: true},
+		{GuildID: "1", Command: "purge", TargetID: "muted", IsRole: true, Allow: false},
+		{GuildID: "1", Command: "image", TargetID: "spammer", IsRole: false, Allow: false},
+		{GuildID: "1", Command: "kick", TargetID: "mods", IsRole: true, Allow: false},
+	}
+
+	t.Run("without overrides the Discord permission is required", func(t *testing.T) {
+		access := commandAccess{userID: "a", overrides: overrides}
+		if access.canUse("purge") || !access.canUse("wiki") {
+			t.Logf("Expected purge to be denied and wiki to be allowed")
+			t.Fail()
+		}
+		access.perms = discordgo.PermissionManageMessages
+		if !access.canUse("purge") {
+			t.Logf("Manage Messages should allow purge")
+			t.Fail()
+		}
+	})
+
+	t.Run("a role can be allowed a command", func(t *testing.T) {
+		access := commandAccess{userID: "a", roles: []string{"helpers"}, overrides: overrides}
+		if !access.canUse("purge") {
+			t.Logf("Helpers should be able to purge")
+			t.Fail()
+		}
+		access.roles = append(access.roles, "muted")
+		if !access.canUse("purge") {
+			t.Logf("An allowed role should win over a denied role")
+			t.Fail()
+		}
+	})
+
+	t.Run("a role or user can be denied a command", func(t *testing.T) {
+		access := commandAccess{userID: "a", roles: []string{"mods"}, perms: discordgo.PermissionKickMembers, overrides: overrides}
+		if access.canUse("kick") {
+			t.Logf("Mods were denied kick")
+			t.Fail()
+		}
+		access = commandAccess{userID: "spammer", overrides: overrides}
+		if access.canUse("image") {
+			t.Logf("The spammer was denied image")
+			t.Fail()
+		}
+	})
+
+	t.Run("owner commands are only for owners", func(t *testing.T) {
+		os.Setenv("BOT_OWNERS", "123456789012345678, 234567890123456789")
+		loadOwners()
+		if !isOwner("234567890123456789") || isOwner("spammer") {
+			t.Logf("Owners were not loaded from BOT_OWNERS")
+			t.Fail()
+		}
+		access := commandAccess{userID: "a", perms: discordgo.PermissionAdministrator}
+		if access.canUse("owner") {
+			t.Logf("Administrators should not be able to use owner commands")
+			t.Fail()
+		}
+		access.owner = true
+		if !access.canUse("owner") {
+			t.Logf("Owners should be able to use owner commands")
+			t.Fail()
+		}
+	})
+
+	t.Run("administrators ignore overrides", func(t *testing.T) {
+		access := commandAccess{userID: "spammer", perms: discordgo.PermissionAdministrator, overrides: overrides}
+		if !access.canUse("image") || !access.canUse("purge") {
+			t.Logf("Administrators should be able to use every command")
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var jobStatusTable string
+
+/**
+How a background job last went, saved so it survives restarts.
+*/
+type JobStatus struct {
+	Name        string
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+	Failures    int // failed runs in a row
+	Paused      bool
+}
+
+/**
+When a job runs: either every so often, or whenever a cron expression matches.
+*/
+type schedule interface {
+	// the first time after the given one that the job should run
+	next(after time.Time) time.Time
+	String() string
+}
+
+type interval time.Duration
+
+/**
+Runs a job every d, counted from when its last scheduled run started.
+*/
+func every(d time.Duration) schedule {
+	return interval(d)
+}
+
+func (d interval) next(after time.Time) time.Time {
+	return after.Add(time.Duration(d))
+}
+
+func (d interval) String() string {
+	return "every " + time.Duration(d).String()
+}
+
+/**
+A background task run by the scheduler. Each job runs in its own goroutine, so
+a slow job never holds up the others.
+*/
+type job struct {
+	name       string
+	schedule   schedule
+	jitter     time.Duration // up to this much is added to every scheduled run
+	retries    int           // how many times a failed run is retried
+	backoff    time.Duration // wait before the first retry; it doubles after each one
+	runAtStart bool          // run as soon as the bot starts rather than when the schedule says
+	run        func() error
+
+	// the rest is guarded by jobsLock
+	status  JobStatus
+	running bool
+	nextRun time.Time
+	trigger chan struct{}
+	changed chan struct{} // the schedule changed, so the wait for the next run starts over
+	stop    chan struct{}
+}
+
+var errNoSuchJob = errors.New("there is no job with that name")
+
+var (
+	// every scheduled job by name
+	jobs     = make(map[string]*job)
+	jobsLock sync.Mutex
+)
+
+/**
+Starts running a job on its schedule. The job picks up where it left off: it
+isn't run early just because the bot restarted, and stays paused if it was.
+*/
+func scheduleJob(j *job) {
+	statuses, err := storage.GetJobStatuses()
+	if err != nil {
+		logError("Unable to read the status of job " + j.name + "! " + err.Error())
+	}
+	j.status = JobStatus{Name: j.name}
+	for _, status := range statuses {
+		if status.Name == j.name {
+			j.status = status
+		}
+	}
+
+	now := time.Now()
+	j.nextRun = now
+	if !j.runAtStart && !j.status.LastRun.IsZero() {
+		j.nextRun = j.schedule.next(j.status.LastRun)
+		if j.nextRun.IsZero() {
+			j.warnNeverDue()
+		}
+	}
+	j.trigger = make(chan struct{}, 1)
+	j.changed = make(chan struct{}, 1)
+	j.stop = make(chan struct{})
+
+	jobsLock.Lock()
+	if previous, ok := jobs[j.name]; ok {
+		close(previous.stop)
+	}
+	jobs[j.name] = j
+	jobsLock.Unlock()
+	logInfo("Scheduled job " + j.name + " to run " + j.schedule.String())
+	go j.loop()
+}
+
+/**
+Stops running a job. A run that already started is allowed to finish.
+*/
+func unscheduleJob(name string) {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	if j, ok := jobs[name]; ok {
+		close(j.stop)
+		delete(jobs, name)
+	}
+}
+
+/**
+Changes when a job runs. The next run is worked out from the last one, so a
+shorter interval can make the job run right away. Does nothing if the schedule
+is the same.
+*/
+func rescheduleJob(name string, newSchedule schedule) error {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	j, ok := jobs[name]
+	if !ok {
+		return errNoSuchJob
+	}
+	if j.schedule.String() == newSchedule.String() {
+		return nil
+	}
+	j.schedule = newSchedule
+	after := j.status.LastRun
+	if after.IsZero() {
+		after = time.Now()
+	}
+	j.nextRun = j.scheduleAfter(after)
+	select {
+	case j.changed <- struct{}{}:
+	default:
+	}
+	logInfo("Rescheduled job " + name + " to run " + newSchedule.String())
+	return nil
+}
+
+/**
+Returns when the job should run next after the given time, with jitter, or the
+zero time if its schedule never matches.
+*/
+func (j *job) scheduleAfter(now time.Time) time.Time {
+	next := j.schedule.next(now)
+	if next.IsZero() {
+		j.warnNeverDue()
+		return next
+	}
+	if j.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+	}
+	return next
+}
+
+/**
+Warns that the job's schedule never matches, so it only runs when triggered.
+*/
+func (j *job) warnNeverDue() {
+	logWarning("Job " + j.name + " is scheduled to run " + j.schedule.String() + ", which never happens; it will only run with ~jobs run until it is rescheduled")
+}
+
+/**
+Waits for each run of the job, whether scheduled or triggered with ~jobs run.
+Paused jobs skip their scheduled runs but can still be triggered, and so can
+jobs whose schedule never matches.
+*/
+func (j *job) loop() {
+	for {
+		// a nil channel never fires, so a job without a next run waits to be triggered
+		var timer *time.Timer
+		var due <-chan time.Time
+		jobsLock.Lock()
+		if !j.nextRun.IsZero() {
+			timer = time.NewTimer(time.Until(j.nextRun))
+			due = timer.C
+		}
+		jobsLock.Unlock()
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		triggered := false
+		select {
+		case <-due:
+		case <-j.trigger:
+			stopTimer()
+			triggered = true
+		case <-j.changed:
+			stopTimer()
+			continue
+		case <-j.stop:
+			stopTimer()
+			return
+		}
+
+		jobsLock.Lock()
+		paused := j.status.Paused
+		if !triggered {
+			j.nextRun = j.scheduleAfter(time.Now())
+		}
+		jobsLock.Unlock()
+		if paused && !triggered {
+			logDebug("Skipping paused job " + j.name)
+			continue
+		}
+		runJob(j)
+	}
+}
+
+/**
+Runs the job once, retrying with backoff if it fails, then saves how it went.
+*/
+func runJob(j *job) error {
+	jobsLock.Lock()
+	j.running = true
+	jobsLock.Unlock()
+
+	logDebug("Running job " + j.name)
+	started := time.Now()
+	err := j.attempt()
+	wait := j.backoff
+	for retry := 1; err != nil && retry <= j.retries; retry++ {
+		logWarning(fmt.Sprintf("Job %s failed, retrying in %s: %s", j.name, wait, err.Error()))
+		time.Sleep(wait)
+		wait *= 2
+		err = j.attempt()
+	}
+	recordJobRun(j.name, started, err == nil)
+
+	jobsLock.Lock()
+	j.running = false
+	j.status.Name = j.name
+	j.status.LastRun = started
+	if err == nil {
+		j.status.LastSuccess = started
+		j.status.LastError = ""
+		j.status.Failures = 0
+	} else {
+		j.status.LastError = err.Error()
+		j.status.Failures++
+		logError("Job " + j.name + " failed! " + err.Error())
+	}
+	status := j.status
+	jobsLock.Unlock()
+
+	saveErr := storage.SetJobStatus(status)
+	if saveErr != nil {
+		logError("Unable to save the status of job " + j.name + "! " + saveErr.Error())
+	}
+	return err
+}
+
+/**
+Runs the job's function a single time. A panic fails the run instead of
+crashing the bot.
+*/
+func (j *job) attempt() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logError(fmt.Sprintf("Job %s panicked! %v\n%s", j.name, r, debug.Stack()))
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+	return j.run()
+}
+
+/**
+Runs a job now, outside of its schedule.
+*/
+func triggerJob(name string) error {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	j, ok := jobs[name]
+	if !ok {
+		return errNoSuchJob
+	}
+	if j.running {
+		return errors.New(name + " is already running")
+	}
+	select {
+	case j.trigger <- struct{}{}:
+		return nil
+	default:
+		return errors.New(name + " is already about to run")
+	}
+}
+
+/**
+Pauses or resumes a job's scheduled runs.
+*/
+func setJobPaused(name string, paused bool) error {
+	jobsLock.Lock()
+	j, ok := jobs[name]
+	if !ok {
+		jobsLock.Unlock()
+		return errNoSuchJob
+	}
+	j.status.Paused = paused
+	status := j.status
+	jobsLock.Unlock()
+	return storage.SetJobStatus(status)
+}
+
+/****
+CRON EXPRESSIONS
+****/
+
+/**
+A cron expression with the usual five fields: minute, hour, day of the month,
+month and day of the week (0 is Sunday). Fields can be *, a number, a range
+like 1-5, a step like 0-59/15 or 1-30/2, or a comma separated list of those.
+Times are matched in UTC.
+*/
+type cronSchedule struct {
+	expression string
+	minutes    []bool
+	hours      []bool
+	days       []bool
+	months     []bool
+	weekdays   []bool
+	// when both day fields are restricted, either one matching is enough
+	anyDay     bool
+	anyWeekday bool
+}
+
+/**
+Parses a cron expression.
+*/
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in '%s', got %d", expression, len(fields))
+	}
+	cron := &cronSchedule{expression: expression, anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	for i, target := range []*[]bool{&cron.minutes, &cron.hours, &cron.days, &cron.months, &cron.weekdays} {
+		min, max := cronBounds[i][0], cronBounds[i][1]
+		*target, err = parseCronField(fields[i], min, max)
+		if err != nil {
+			return nil, fmt.Errorf("field %d of '%s': %w", i+1, expression, err)
+		}
+	}
+	return cron, nil
+}
+
+/**
+Parses a cron expression that is known to be valid, e.g. one in the source.
+*/
+func mustParseCron(expression string) *cronSchedule {
+	cron, err := parseCron(expression)
+	if err != nil {
+		panic(err)
+	}
+	return cron
+}
+
+// the smallest and largest value of each cron field
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+/**
+Returns which values from 0 to max a cron field matches.
+*/
+func parseCronField(field string, min int, max int) ([]bool, error) {
+	matches := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:slash]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s'", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value '%s'", part)
+				}
+			} else if step > 1 {
+				// 5/15 means every 15 starting at 5
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("'%s' is outside %d-%d", part, min, max)
+		}
+		for value := from; value <= to; value += step {
+			matches[value] = true
+		}
+	}
+	return matches, nil
+}
+
+func (cron *cronSchedule) dayMatches(t time.Time) bool {
+	day, weekday := cron.days[t.Day()], cron.weekdays[int(t.Weekday())]
+	if !cron.anyDay && !cron.anyWeekday {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+func (cron *cronSchedule) next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// a valid expression matches within a few years, e.g. Feb 29 on a Sunday
+	limit := t.AddDate(8, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !cron.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !cron.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !cron.hours[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !cron.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (cron *cronSchedule) String() string {
+	return "at '" + cron.expression + "' (UTC)"
+}
+
+/****
+~JOBS
+****/
+
+/**
+Describes when something happened relative to now, e.g. "5m ago" or "in 2h".
+*/
+func describeWhen(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
+
+/**
+Lists the bot's background jobs, runs one now, or pauses and resumes them.
+*/
+func handleJobs(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	name := args.Values["job"]
+	switch args.Subcommand {
+	case "list":
+		jobsLock.Lock()
+		var names []string
+		for jobName := range jobs {
+			names = append(names, jobName)
+		}
+		sort.Strings(names)
+
+		now := time.Now()
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = fmt.Sprintf("%d Jobs", len(names))
+		for _, jobName := range names {
+			j := jobs[jobName]
+			state := "Runs " + j.schedule.String() + ", next " + describeWhen(j.nextRun, now)
+			if j.running {
+				state = "Running now"
+			} else if j.status.Paused {
+				state = "Paused"
+			}
+			result := ":white_check_mark: Last run " + describeWhen(j.status.LastRun, now)
+			if j.status.LastError != "" {
+				result = fmt.Sprintf(":x: Last run %s failed (%d in a row): %s", describeWhen(j.status.LastRun, now), j.status.Failures, j.status.LastError)
+			} else if j.status.LastRun.IsZero() {
+				result = "Hasn't run yet"
+			}
+			embed.Fields = append(embed.Fields, createField(jobName, state+"\n"+result, false))
+		}
+		jobsLock.Unlock()
+		_, err := sendEmbed(s, m, &embed)
+		if err != nil {
+			logError("Failed to send job list! " + err.Error())
+		}
+	case "run":
+		err := triggerJob(name)
+		if err != nil {
+			attemptSendMsg(s, m, "Unable to run it: "+err.Error()+".")
+			return
+		}
+		sendSuccess(s, m, "Started "+name+".")
+	case "pause", "resume":
+		paused := args.Subcommand == "pause"
+		err := setJobPaused(name, paused)
+		if err == errNoSuchJob {
+			attemptSendMsg(s, m, "There is no job named "+name+".")
+			return
+		} else if err != nil {
+			logError("Failed to save the status of job " + name + "! " + err.Error())
+			sendError(s, m, "jobs", Database)
+			return
+		}
+		if paused {
+			sendSuccess(s, m, "Paused "+name+". It can still be run with `"+getGuildPrefix(m.GuildID)+"jobs run "+name+"`.")
+		} else {
+			sendSuccess(s, m, "Resumed "+name+".")
+		}
+	}
+}
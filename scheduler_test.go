@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+Test that cron expressions are parsed and matched like cron does.
+**/
+func TestCron(t *testing.T) {
+	t.Run("next matching times", func(t *testing.T) {
+		cases := []struct {
+			expression string
+			after      time.Time
+			want       time.Time
+		}{
+			{"0 0-23/6 * * *", time.Date(2022, 8, 20, 7, 30, 0, 0, time.UTC), time.Date(2022, 8, 20, 12, 0, 0, 0, time.UTC)},
+			{"15,45 * * * *", time.Date(2022, 8, 20, 7, 15, 0, 0, time.UTC), time.Date(2022, 8, 20, 7, 45, 0, 0, time.UTC)},
+			{"30 9 * * 1-5", time.Date(2022, 8, 19, 10, 0, 0, 0, time.UTC), time.Date(2022, 8, 22, 9, 30, 0, 0, time.UTC)},
+			{"0 0 29 2 *", time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+			// either day field matching is enough when both are given
+			{"0 0 1 * 0", time.Date(2022, 8, 20, 0, 0, 0, 0, time.UTC), time.Date(2022, 8, 21, 0, 0, 0, 0, time.UTC)},
+			{"0 12 * 12 *", time.Date(2022, 12, 31, 12, 0, 0, 0, time.UTC), time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)},
+		}
+		for _, c := range cases {
+			cron, err := parseCron(c.expression)
+			if err != nil {
+				t.Logf("Unable to parse '%s': %s", c.expression, err.Error())
+				t.Fail()
+				continue
+			}
+			if next := cron.next(c.after); !next.Equal(c.want) {
+				t.Logf("'%s' after %s: expected %s, got %s", c.expression, c.after, c.want, next)
+				t.Fail()
+			}
+		}
+	})
+
+	t.Run("invalid expressions are rejected", func(t *testing.T) {
+		for _, expression := range []string{"* * *", "60 * * * *", "0-59/0 * * * *", "a * * * *", "5-1 * * * *", "* * 0 * *"} {
+			if _, err := parseCron(expression); err == nil {
+				t.Logf("'%s' should not have parsed", expression)
+				t.Fail()
+			}
+		}
+	})
+}
+
+/**
+Test that jobs are retried, recover from panics, remember how they went and can
+be controlled with ~jobs.
+**/
+func TestScheduler(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+
+	savedStatus := func(name string) JobStatus {
+		statuses, _ := storage.GetJobStatuses()
+		for _, status := range statuses {
+			if status.Name == name {
+				return status
+			}
+		}
+		return JobStatus{}
+	}
+
+	t.Run("failed runs are retried with backoff", func(t *testing.T) {
+		attempts := 0
+		flaky := &job{name: "flaky", schedule: every(time.Hour), retries: 2, backoff: time.Millisecond, run: func() error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		}}
+		if err := runJob(flaky); err != nil || attempts != 3 {
+			t.Logf("Expected success on the third attempt, got %v after %d", err, attempts)
+			t.Fail()
+		}
+		if status := savedStatus("flaky"); status.LastSuccess.IsZero() || status.Failures != 0 || status.LastError != "" {
+			t.Logf("Unexpected status %+v", status)
+			t.Fail()
+		}
+	})
+
+	t.Run("panics fail the run instead of crashing", func(t *testing.T) {
+		broken := &job{name: "broken", schedule: every(time.Hour), run: func() error {
+			var guilds map[string]int
+			guilds["1"]++
+			return nil
+		}}
+		runJob(broken)
+		err := runJob(broken)
+		if err == nil || !strings.Contains(err.Error(), "panicked") {
+			t.Logf("Expected a panic error, got %v", err)
+			t.Fail()
+		}
+		if status := savedStatus("broken"); status.Failures != 2 || !strings.Contains(status.LastError, "panicked") || !status.LastSuccess.IsZero() {
+			t.Logf("Unexpected status %+v", status)
+			t.Fail()
+		}
+	})
+
+	t.Run("scheduled jobs pick up where they left off", func(t *testing.T) {
+		lastRun := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		storage.SetJobStatus(JobStatus{Name: "hourly", LastRun: lastRun, Paused: true})
+		hourly := &job{name: "hourly", schedule: every(time.Hour), run: func() error { return nil }}
+		scheduleJob(hourly)
+		defer unscheduleJob("hourly")
+
+		jobsLock.Lock()
+		defer jobsLock.Unlock()
+		if !hourly.nextRun.Equal(lastRun.Add(time.Hour)) || !hourly.status.Paused {
+			t.Logf("Expected the next run an hour after the last one and still paused, got %s %+v", hourly.nextRun, hourly.status)
+			t.Fail()
+		}
+	})
+
+	t.Run("jobs whose schedule never matches wait to be triggered", func(t *testing.T) {
+		never, err := parseCron("0 0 31 2 *")
+		if err != nil {
+			t.Fatalf("Unable to parse the expression: %s", err.Error())
+		}
+		runs := make(chan struct{}, 10)
+		parked := &job{name: "parked", schedule: never, run: func() error { runs <- struct{}{}; return nil }}
+		storage.SetJobStatus(JobStatus{Name: "parked", LastRun: time.Now().Add(-time.Minute)})
+		scheduleJob(parked)
+		defer unscheduleJob("parked")
+
+		time.Sleep(50 * time.Millisecond)
+		if len(runs) != 0 {
+			t.Fatalf("The job ran %d times without being due", len(runs))
+		}
+		if err := triggerJob("parked"); err != nil {
+			t.Fatalf("Unable to trigger the job: %s", err.Error())
+		}
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Logf("The triggered job didn't run")
+			t.Fail()
+		}
+	})
+
+	t.Run("the auto-kick job checks every guild even if one can't be read", func(t *testing.T) {
+		fake := newFakeDiscord(t)
+		idle := fake.AddUser("idle")
+		storage.SetAutoKick("broken", 7)
+		storage.SetAutoKick(fake.Guild.ID, 7)
+		storage.AddMemberActivity(fake.Guild.ID, idle.ID, idle.String(), time.Now().AddDate(0, 0, -30), "Wrote a message")
+		previous := storage
+		storage = failingActivityStorage{Storage: previous, guildID: "broken"}
+		defer func() { storage = previous }()
+
+		err := autoKick(fake.Session)
+		if err == nil || !strings.Contains(err.Error(), "broken: table is locked") {
+			t.Logf("Expected the unreadable guild to fail the job, got %v", err)
+			t.Fail()
+		}
+		if kicks := fake.Kicks(); len(kicks) != 1 || kicks[0].UserID != idle.ID {
+			t.Logf("Expected the other guild's idle member to be kicked, got %+v", kicks)
+			t.Fail()
+		}
+	})
+
+	t.Run("owners can run, pause and list jobs", func(t *testing.T) {
+		fake := newFakeDiscord(t)
+		owner := fake.AddUser("owner")
+		ownersLock.Lock()
+		previous := owners
+		owners = map[string]bool{owner.ID: true}
+		ownersLock.Unlock()
+		defer func() {
+			ownersLock.Lock()
+			owners = previous
+			ownersLock.Unlock()
+		}()
+
+		runs := make(chan bool, 1)
+		storage.SetJobStatus(JobStatus{Name: "counter", LastRun: time.Now()})
+		scheduleJob(&job{name: "counter", schedule: every(time.Hour), run: func() error {
+			runs <- true
+			return nil
+		}})
+		defer unscheduleJob("counter")
+
+		if response := fake.Reply(owner, "~jobs run counter").Content; response != "Started counter." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		select {
+		case <-runs:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("The job wasn't run")
+		}
+
+		if response := fake.Reply(owner, "~jobs pause counter").Content; response != "Paused counter. It can still be run with `~jobs run counter`." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		if !savedStatus("counter").Paused {
+			t.Logf("The pause should have been saved")
+			t.Fail()
+		}
+		list := fake.Reply(owner, "~jobs list")
+		if len(list.Embeds) != 1 || len(list.Embeds[0].Fields) != 1 || !strings.HasPrefix(list.Embeds[0].Fields[0].Value, "Paused") {
+			t.Logf("Expected the job to be listed as paused, got %+v", list.Embeds)
+			t.Fail()
+		}
+
+		if response := fake.Reply(owner, "~jobs run nothing").Content; response != "Unable to run it: there is no job with that name." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+}
+
+/**
+Storage whose member activity can't be read for one guild.
+**/
+type failingActivityStorage struct {
+	Storage
+	guildID string
+}
+
+func (store failingActivityStorage) GetKickableActivity(guildID string) ([]MemberActivity, error) {
+	if guildID == store.guildID {
+		return nil, errors.New("table is locked")
+	}
+	return store.Storage.GetKickableActivity(guildID)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord's limits on what a message can hold, in characters
+const (
+	maxMessageLength    = 2000
+	maxEmbedTitle       = 256
+	maxEmbedDescription = 4096
+	maxEmbedFields      = 25
+	maxFieldName        = 256
+	maxFieldValue       = 1024
+	maxFooterText       = 2048
+	maxAuthorName       = 256
+	maxEmbedTotal       = 6000
+)
+
+// how many times a send that failed for a passing reason is retried, and how
+// long to wait before the first retry; the wait doubles after each one
+var (
+	sendRetries    = 3
+	sendRetryDelay = 500 * time.Millisecond
+)
+
+/****
+SPLITTING AND TRUNCATING
+****/
+
+/**
+Splits text into chunks of at most limit characters, breaking at the last line
+break that fits, or the last space if a single line is too long.
+*/
+func splitText(text string, limit int) []string {
+	var chunks []string
+	runes := []rune(text)
+	for len(runes) > limit {
+		// the break itself is dropped, so it may sit just past the limit
+		cut := lastIndexRune(runes[:limit+1], '\n')
+		if cut <= 0 {
+			cut = lastIndexRune(runes[:limit+1], ' ')
+		}
+		if cut <= 0 {
+			chunks = append(chunks, string(runes[:limit]))
+			runes = runes[limit:]
+			continue
+		}
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut+1:]
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+func lastIndexRune(runes []rune, r rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+/**
+Shortens text to at most limit characters, ending it with an ellipsis if
+anything was cut.
+*/
+func truncateText(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+	return string([]rune(text)[:limit-1]) + "…"
+}
+
+/**
+Counts the characters of an embed the way Discord does for its total limit.
+*/
+func embedLength(embed *discordgo.MessageEmbed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+	return length
+}
+
+/**
+Returns a copy of the embed cut down to fit Discord's limits. Fields past the
+limit are dropped, and so are the last fields if the embed is too long overall.
+*/
+func truncateEmbed(embed *discordgo.MessageEmbed) *discordgo.MessageEmbed {
+	fitted := *embed
+	fitted.Title = truncateText(embed.Title, maxEmbedTitle)
+	fitted.Description = truncateText(embed.Description, maxEmbedDescription)
+	if embed.Footer != nil {
+		footer := *embed.Footer
+		footer.Text = truncateText(footer.Text, maxFooterText)
+		fitted.Footer = &footer
+	}
+	if embed.Author != nil {
+		author := *embed.Author
+		author.Name = truncateText(author.Name, maxAuthorName)
+		fitted.Author = &author
+	}
+	fitted.Fields = nil
+	for i, field := range embed.Fields {
+		if i == maxEmbedFields {
+			break
+		}
+		fitted.Fields = append(fitted.Fields, createField(truncateText(field.Name, maxFieldName), truncateText(field.Value, maxFieldValue), field.Inline))
+	}
+
+	for embedLength(&fitted) > maxEmbedTotal && len(fitted.Fields) > 0 {
+		fitted.Fields = fitted.Fields[:len(fitted.Fields)-1]
+	}
+	if excess := embedLength(&fitted) - maxEmbedTotal; excess > 0 {
+		fitted.Description = truncateText(fitted.Description, utf8.RuneCountInString(fitted.Description)-excess)
+	}
+	return &fitted
+}
+
+/**
+Splits an embed that is too big for one message into pages. A long description
+continues on the next page, long field values continue in another field, and
+fields that don't fit move to the next page. Every page keeps the title,
+author and footer, and the footer says which page it is.
+*/
+func splitEmbed(embed *discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+	var fields []*discordgo.MessageEmbedField
+	for _, field := range embed.Fields {
+		for i, value := range splitText(field.Value, maxFieldValue) {
+			name := field.Name
+			if i > 0 {
+				name = truncateText(field.Name, maxFieldName-len(" (continued)")) + " (continued)"
+			}
+			fields = append(fields, createField(name, value, field.Inline))
+		}
+	}
+
+	base := *embed
+	base.Description = ""
+	base.Fields = nil
+	// leave room for the page number in the footer
+	room := maxEmbedTotal - embedLength(truncateEmbed(&base)) - len(" • Page 00 of 00")
+
+	var pages []*discordgo.MessageEmbed
+	newPage := func() *discordgo.MessageEmbed {
+		page := base
+		if len(pages) > 0 {
+			page.Image = nil
+		}
+		pages = append(pages, &page)
+		return &page
+	}
+	page := newPage()
+	for i, description := range splitText(embed.Description, maxEmbedDescription) {
+		if i > 0 {
+			page = newPage()
+		}
+		page.Description = description
+	}
+	for _, field := range fields {
+		length := utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if len(page.Fields) == maxEmbedFields || embedLength(page)-embedLength(&base)+length > room {
+			page = newPage()
+		}
+		page.Fields = append(page.Fields, field)
+	}
+
+	if len(pages) == 1 {
+		return []*discordgo.MessageEmbed{truncateEmbed(pages[0])}
+	}
+	for i, page := range pages {
+		pageNumber := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		footer := discordgo.MessageEmbedFooter{Text: pageNumber}
+		if embed.Footer != nil {
+			footer = *embed.Footer
+			footer.Text = truncateText(footer.Text, maxFooterText-len(pageNumber)-3) + " • " + pageNumber
+		}
+		page.Footer = &footer
+		pages[i] = truncateEmbed(page)
+	}
+	return pages
+}
+
+/**
+Splits a message into ones that fit Discord's limits. The first message has
+the embeds, components and files; the rest hold any text that didn't fit.
+*/
+func splitMessage(data *discordgo.MessageSend) []*discordgo.MessageSend {
+	first := *data
+	first.Embeds = nil
+	for _, embed := range data.Embeds {
+		first.Embeds = append(first.Embeds, truncateEmbed(embed))
+	}
+	chunks := splitText(data.Content, maxMessageLength)
+	if len(chunks) == 0 {
+		return []*discordgo.MessageSend{&first}
+	}
+
+	first.Content = chunks[0]
+	parts := []*discordgo.MessageSend{&first}
+	for _, chunk := range chunks[1:] {
+		parts = append(parts, &discordgo.MessageSend{Content: chunk, AllowedMentions: data.AllowedMentions})
+	}
+	return parts
+}
+
+/****
+SENDING
+****/
+
+/**
+Whether a failed request might work if it is tried again: Discord had a server
+error or is rate limiting us, or the connection failed.
+*/
+func isTransient(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		return restErr.Response.StatusCode == 429 || restErr.Response.StatusCode >= 500
+	}
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// discordgo gives up on repeated 502s with a plain error
+	return strings.HasPrefix(err.Error(), "Exceeded Max retries")
+}
+
+/**
+Sends a message, retrying with backoff if it fails for a reason that might pass.
+*/
+func withRetries(send func() (*discordgo.Message, error)) (*discordgo.Message, error) {
+	wait := sendRetryDelay
+	message, err := send()
+	for retry := 1; err != nil && retry <= sendRetries && isTransient(err); retry++ {
+		logWarning(fmt.Sprintf("Sending failed, retrying in %s: %s", wait, err.Error()))
+		time.Sleep(wait)
+		wait *= 2
+		message, err = send()
+	}
+	return message, err
+}
+
+/**
+Sends a message to a channel, splitting text that is too long and retrying
+failures that might pass. Returns the first message that was sent.
+*/
+func sendToChannel(s *discordgo.Session, channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	var first *discordgo.Message
+	for i, part := range splitMessage(data) {
+		message, err := withRetries(func() (*discordgo.Message, error) {
+			return s.ChannelMessageSendComplex(channelID, part)
+		})
+		if err != nil {
+			return first, err
+		}
+		if i == 0 {
+			first = message
+		}
+	}
+	return first, nil
+}
+
+/**
+Sends an embed to a channel. An embed too big for one message is sent as
+several. Returns the first message that was sent.
+*/
+func sendEmbedToChannel(s *discordgo.Session, channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	var first *discordgo.Message
+	for i, page := range splitEmbed(embed) {
+		message, err := sendToChannel(s, channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{page}})
+		if err != nil {
+			return first, err
+		}
+		if i == 0 {
+			first = message
+		}
+	}
+	return first, nil
+}
+
+/**
+Lets the user know their command worked but the reply couldn't be sent. This
+makes a single attempt, since Discord may be refusing everything.
+*/
+func reportSendFailure(s *discordgo.Session, m *Invocation, err error) {
+	m.setOutcome(Discord.String())
+	logWith(LevelError, invocationFields(m, ""), "Failed to send a reply: "+err.Error())
+
+	notice := ":bangbang: Discord wouldn't take my reply. Please try again in a bit."
+	if m.Interaction != nil {
+		_, err = s.FollowupMessageCreate(m.Interaction, true, &discordgo.WebhookParams{Content: notice, Flags: discordgo.MessageFlagsEphemeral})
+	} else {
+		reactToInvocation(s, m, "❌")
+		_, err = s.ChannelMessageSend(m.ChannelID, notice)
+	}
+	if err != nil {
+		logWarning("Unable to report the failed reply; " + err.Error())
+	}
+}
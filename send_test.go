@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that text and embeds are split or cut down to fit Discord's limits.
+**/
+func TestSplitting(t *testing.T) {
+	t.Run("text splits on line breaks, then spaces", func(t *testing.T) {
+		chunks := splitText("first line\nsecond line", 15)
+		if len(chunks) != 2 || chunks[0] != "first line" || chunks[1] != "second line" {
+			t.Logf("Unexpected chunks %q", chunks)
+			t.Fail()
+		}
+		chunks = splitText("one two three", 8)
+		if len(chunks) != 2 || chunks[0] != "one two" || chunks[1] != "three" {
+			t.Logf("Unexpected chunks %q", chunks)
+			t.Fail()
+		}
+		chunks = splitText(strings.Repeat("é", 5), 2)
+		if len(chunks) != 3 || chunks[2] != "é" {
+			t.Logf("Unexpected chunks %q", chunks)
+			t.Fail()
+		}
+	})
+
+	t.Run("long messages become several", func(t *testing.T) {
+		line := strings.Repeat("a", 99) + "\n"
+		parts := splitMessage(&discordgo.MessageSend{Content: strings.Repeat(line, 30)})
+		if len(parts) != 2 || len(parts[0].Content) > maxMessageLength || !strings.HasSuffix(parts[0].Content, "a") {
+			t.Logf("Expected two messages split at a line break, got %d", len(parts))
+			t.Fail()
+		}
+	})
+
+	t.Run("embeds are cut down to the limits", func(t *testing.T) {
+		embed := &discordgo.MessageEmbed{Title: strings.Repeat("t", 300), Description: strings.Repeat("d", 5000)}
+		for i := 0; i < 30; i++ {
+			embed.Fields = append(embed.Fields, createField("field", strings.Repeat("v", 1000), false))
+		}
+		fitted := truncateEmbed(embed)
+		if utf8.RuneCountInString(fitted.Title) != maxEmbedTitle || len(fitted.Fields) > maxEmbedFields || embedLength(fitted) > maxEmbedTotal {
+			t.Logf("The embed doesn't fit: title %d, %d fields, %d total", len(fitted.Title), len(fitted.Fields), embedLength(fitted))
+			t.Fail()
+		}
+		if len(embed.Fields) != 30 {
+			t.Logf("The original embed shouldn't change")
+			t.Fail()
+		}
+	})
+
+	t.Run("big embeds are split into pages", func(t *testing.T) {
+		embed := &discordgo.MessageEmbed{
+			Title:       "Definitions",
+			Description: strings.Repeat("A line of the description.\n", 200),
+			Fields:      []*discordgo.MessageEmbedField{createField("noun", strings.Repeat("A sense of the word.\n", 100), false)},
+			Footer:      &discordgo.MessageEmbedFooter{Text: "Fetched from Wiktionary"},
+		}
+		pages := splitEmbed(embed)
+		if len(pages) < 2 {
+			t.Fatalf("Expected several pages, got %d", len(pages))
+		}
+		fields := 0
+		for i, page := range pages {
+			if embedLength(page) > maxEmbedTotal || utf8.RuneCountInString(page.Description) > maxEmbedDescription {
+				t.Logf("Page %d is too long", i+1)
+				t.Fail()
+			}
+			if page.Title != "Definitions" || page.Footer.Text != fmt.Sprintf("Fetched from Wiktionary • Page %d of %d", i+1, len(pages)) {
+				t.Logf("Unexpected title or footer on page %d: %s, %s", i+1, page.Title, page.Footer.Text)
+				t.Fail()
+			}
+			for _, field := range page.Fields {
+				if utf8.RuneCountInString(field.Value) > maxFieldValue {
+					t.Logf("A field on page %d is too long", i+1)
+					t.Fail()
+				}
+				fields++
+			}
+		}
+		if fields != 3 || pages[len(pages)-1].Fields[len(pages[len(pages)-1].Fields)-1].Name != "noun (continued)" {
+			t.Logf("Expected the field to continue in two more, got %d fields", fields)
+			t.Fail()
+		}
+
+		if small := splitEmbed(&discordgo.MessageEmbed{Title: "Small"}); len(small) != 1 || small[0].Footer != nil {
+			t.Logf("A small embed shouldn't be split or numbered, got %+v", small)
+			t.Fail()
+		}
+	})
+}
+
+/**
+Test that failed sends are retried when they might pass, and that the user
+hears about it when a reply can't be sent.
+**/
+func TestSendRetries(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	member := fake.AddUser("member")
+	start = time.Now()
+
+	previousDelay := sendRetryDelay
+	sendRetryDelay = time.Millisecond
+	defer func() { sendRetryDelay = previousDelay }()
+
+	t.Run("server errors are retried", func(t *testing.T) {
+		fake.FailSends(2, http.StatusInternalServerError)
+		if response := fake.Reply(member, "~uptime").Content; !strings.HasPrefix(response, ":robot: Uptime") {
+			t.Logf("Expected the reply after retrying, got `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("the user is told when a reply can't be sent", func(t *testing.T) {
+		fake.FailSends(sendRetries+1, http.StatusServiceUnavailable)
+		if response := fake.Reply(member, "~uptime").Content; !strings.HasPrefix(response, ":bangbang: Discord wouldn't take my reply") {
+			t.Logf("Expected a failure notice, got `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("rejected messages aren't retried", func(t *testing.T) {
+		fake.FailSends(1, http.StatusBadRequest)
+		if response := fake.Reply(member, "~uptime").Content; !strings.HasPrefix(response, ":bangbang: Discord wouldn't take my reply") {
+			t.Logf("Expected a failure notice, got `%s`", response)
+			t.Fail()
+		}
+	})
+}
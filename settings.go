@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const defaultPrefix = "~"
+
+var guildSettingsTable string
+
+// prefixes are checked on every message, so keep them in memory once loaded
+var guildPrefixes = make(map[string]string)
+var guildPrefixesLock sync.RWMutex
+
+/**
+Returns the command prefix for the guild, falling back to the default prefix
+if the guild has not set one.
+*/
+func getGuildPrefix(guildID string) string {
+	guildPrefixesLock.RLock()
+	guildPrefix, ok := guildPrefixes[guildID]
+	guildPrefixesLock.RUnlock()
+	if ok {
+		return guildPrefix
+	}
+
+	guildPrefix, err := storage.GetGuildPrefix(guildID)
+	if err != nil {
+		// don't cache the fallback so the next message tries again
+		logError("Unable to read the guild prefix, using the default prefix: " + err.Error())
+		return defaultPrefix
+	}
+	if guildPrefix == "" {
+		guildPrefix = defaultPrefix
+	}
+
+	guildPrefixesLock.Lock()
+	guildPrefixes[guildID] = guildPrefix
+	guildPrefixesLock.Unlock()
+	return guildPrefix
+}
+
+/**
+Stores the guild's new prefix, or clears it if newPrefix is empty.
+*/
+func setGuildPrefix(guildID string, newPrefix string) bool {
+	err := storage.SetGuildPrefix(guildID, newPrefix)
+	if err != nil {
+		logError("Couldn't update guild prefix! " + err.Error())
+		return false
+	}
+	logSuccess("Updated guild prefix")
+
+	guildPrefixesLock.Lock()
+	delete(guildPrefixes, guildID)
+	guildPrefixesLock.Unlock()
+	return true
+}
+
+/**
+Returns the usage string of a command written with the guild's prefix.
+*/
+func usageFor(guildID string, name string) string {
+	return strings.ReplaceAll(commandList[name].usage, defaultPrefix, getGuildPrefix(guildID))
+}
+
+/**
+Changes or resets the prefix the bot listens for in this guild.
+**/
+func handlePrefix(s *discordgo.Session, m *Invocation, args *Args) {
+	logInfo(m.Content)
+	switch args.Subcommand {
+	case "":
+		attemptSendMsg(s, m, fmt.Sprintf("The current prefix is `%s`.", getGuildPrefix(m.GuildID)))
+	case "set":
+		newPrefix := args.Values["prefix"]
+		if length := utf8.RuneCountInString(newPrefix); length == 0 || length > 5 {
+			attemptSendMsg(s, m, "Please choose a prefix between 1 and 5 characters long.")
+			return
+		}
+		// commands are split on spaces, so a prefix with one in it could never match
+		if strings.IndexFunc(newPrefix, unicode.IsSpace) >= 0 || strings.Contains(newPrefix, "`") {
+			attemptSendMsg(s, m, "Prefixes can't contain spaces or backticks.")
+			return
+		}
+		if !setGuildPrefix(m.GuildID, newPrefix) {
+			sendError(s, m, "prefix", Database)
+			return
+		}
+		sendSuccess(s, m, fmt.Sprintf("Commands now start with `%s`.", newPrefix))
+	case "reset":
+		if !setGuildPrefix(m.GuildID, "") {
+			sendError(s, m, "prefix", Database)
+			return
+		}
+		sendSuccess(s, m, fmt.Sprintf("Commands now start with `%s`.", defaultPrefix))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that guilds can change their prefix, but not to one that could never be typed.
+**/
+func TestPrefix(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	admins := fake.AddRole("admins", discordgo.PermissionManageServer)
+	admin := fake.AddUser("admin", admins.ID)
+	t.Cleanup(func() { setGuildPrefix(fake.Guild.ID, "") })
+
+	t.Run("prefixes with spaces or backticks are rejected", func(t *testing.T) {
+		for _, prefix := range []string{`"a b"`, "a`", "\"\t!\""} {
+			if response := fake.Reply(admin, "~prefix set "+prefix).Content; response != "Prefixes can't contain spaces or backticks." {
+				t.Logf("Unexpected response to %s: `%s`", prefix, response)
+				t.Fail()
+			}
+		}
+		if prefix := getGuildPrefix(fake.Guild.ID); prefix != defaultPrefix {
+			t.Logf("The prefix shouldn't have changed, got %s", prefix)
+			t.Fail()
+		}
+	})
+
+	t.Run("the length is counted in characters", func(t *testing.T) {
+		fake.Reply(admin, "~prefix set 🎉🎉")
+		if prefix := getGuildPrefix(fake.Guild.ID); prefix != "🎉🎉" {
+			t.Logf("Expected the emoji prefix, got %s", prefix)
+			t.Fail()
+		}
+		if response := fake.Reply(admin, "🎉🎉prefix set abcdef").Content; response != "Please choose a prefix between 1 and 5 characters long." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+/**
+Everything the bot persists. The bot talks to this instead of a particular
+database, so it can run against MySQL/MariaDB or an embedded SQLite file.
+*/
+type Storage interface {
+	// member activity, for ~activity and auto-kicking
+	AddMemberActivity(guildID string, memberID string, memberName string, lastActive time.Time, description string) error
+	UpdateMemberActivity(guildID string, memberID string, memberName string, lastActive time.Time, description string) error
+	GetMemberActivity(guildID string, memberID string) (*MemberActivity, error)
+	GetGuildActivity(guildID string) ([]MemberActivity, error)
+	GetKickableActivity(guildID string) ([]MemberActivity, error)
+	SetWhitelisted(guildID string, memberID string, whitelisted bool) error
+	RemoveMemberActivity(guildID string, memberID string) error
+	RemoveGuildActivity(guildID string) error
+
+	// leaderboard points
+	GetLeaderboard(guildID string) ([]LeaderboardEntry, error)
+	GetLeaderboardEntry(guildID string, memberID string) (*LeaderboardEntry, error)
+	AddLeaderboardEntry(entry LeaderboardEntry) error
+	UpdateLeaderboardEntry(entry LeaderboardEntry) error
+
+	// join and leave messages
+	GetGreeterMessages(guildID string) ([]GreeterMessage, error)
+	GetGreeterMessage(guildID string, messageType string) (*GreeterMessage, error)
+	SetGreeterMessage(message GreeterMessage) error
+	RemoveGreeterMessage(guildID string, messageType string) error
+
+	// auto-kick delays
+	GetAutoKicks() ([]AutoKickData, error)
+	GetAutoKick(guildID string) (*AutoKickData, error)
+	SetAutoKick(guildID string, daysUntilKick int) error
+	RemoveAutoKick(guildID string) error
+
+	// mod log channels
+	GetModLogChannels() ([]ModLogData, error)
+	GetModLogChannel(guildID string) (string, error)
+	SetModLogChannel(guildID string, channelID string) error
+	RemoveModLogChannel(guildID string) error
+	GetDisabledModLogEvents(guildID string) ([]string, error)
+	SetModLogEventEnabled(guildID string, event string, enabled bool) error
+
+	// channels the new shrine is posted in
+	GetAutoshrineChannels() ([]ModLogData, error)
+	SetAutoshrineChannel(guildID string, channelID string) error
+	RemoveAutoshrineChannel(guildID string) error
+
+	// guild settings, command configuration and permission overrides
+	GetGuildPrefix(guildID string) (string, error)
+	SetGuildPrefix(guildID string, prefix string) error
+	GetCommandRules(guildID string) ([]CommandRule, error)
+	SetCommandRule(rule CommandRule) error
+	RemoveCommandRule(guildID string, command string, channelID string) error
+	RemoveCommandRules(guildID string, command string) error
+	GetPermissionOverrides(guildID string) ([]PermissionOverride, error)
+	SetPermissionOverride(override PermissionOverride) error
+	RemovePermissionOverride(guildID string, command string, targetID string) error
+	RemovePermissionOverrides(guildID string, command string) error
+
+	// paginated messages
+	GetPaginators() ([]Paginator, error)
+	AddPaginator(paginator Paginator) error
+	SetPaginatorPage(messageID string, page int) error
+	RemovePaginator(messageID string) error
+
+	// background job status
+	GetJobStatuses() ([]JobStatus, error)
+	SetJobStatus(status JobStatus) error
+
+	// warnings and the punishments they escalate to
+	AddWarning(warning Warning) (int64, error)
+	GetWarnings(guildID string, userID string) ([]Warning, error)
+	GetWarning(guildID string, id int64) (*Warning, error)
+	RemoveWarning(guildID string, id int64) error
+	ClearWarnings(guildID string, userID string) error
+	GetEscalationSteps(guildID string) ([]EscalationStep, error)
+	SetEscalationStep(step EscalationStep) error
+	RemoveEscalationStep(guildID string, warnings int) error
+
+	// temporary bans waiting to be lifted
+	AddTempBan(ban TempBan) error
+	GetTempBan(guildID string, userID string) (*TempBan, error)
+	GetExpiredTempBans(now time.Time) ([]TempBan, error)
+	RemoveTempBan(guildID string, userID string) error
+
+	// numbered moderation cases
+	AddCase(modCase ModCase) (int, error)
+	GetCase(guildID string, number int) (*ModCase, error)
+	GetCases(guildID string, userID string) ([]ModCase, error)
+	SetCaseReason(guildID string, number int, reason string) error
+	SetCaseMessage(guildID string, number int, channelID string, messageID string) error
+
+	// recent messages, when the message cache is persisted
+	GetCachedMessages(since time.Time) ([]CachedMessage, error)
+	CacheMessage(cached CachedMessage) error
+	UncacheMessages(channelID string, messageIDs ...string) error
+	RemoveCachedMessagesBefore(cutoff time.Time) error
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var storage Storage
+
+/**
+Returns the environment variable, or the fallback if it isn't set.
+*/
+func envOr(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+/**
+Reads the table names from the configuration. They are only read at startup.
+*/
+func loadTableNames() {
+	tables := currentConfig().Tables
+	activityTable = tables.Activity
+	leaderboardTable = tables.Leaderboard
+	joinLeaveTable = tables.JoinLeave
+	autokickTable = tables.Autokick
+	modLogTable = tables.ModLog
+	autoshrineTable = tables.Autoshrine
+	guildSettingsTable = tables.GuildSettings
+	commandConfigTable = tables.CommandConfig
+	permissionOverrideTable = tables.PermissionOverride
+	paginatorTable = tables.Paginator
+	jobStatusTable = tables.JobStatus
+	warningsTable = tables.Warnings
+	escalationTable = tables.Escalation
+	tempBanTable = tables.TempBans
+	caseTable = tables.Cases
+	modLogEventTable = tables.ModLogEvents
+	messageCacheTable = tables.MessageCache
+	schemaVersionTable = tables.SchemaVersion
+}
+
+/**
+Opens the configured storage backend, either "mysql" (the default) or "sqlite",
+and migrates its schema to MIGRATE_TO or the latest version. The database
+password is only read from DB_PASSWORD.
+*/
+func openStorage() (Storage, error) {
+	settings := currentConfig().Storage
+	var store *sqlStorage
+	var err error
+	switch strings.ToLower(settings.Driver) {
+	case "mysql":
+		store, err = openMySQLStorage(settings.Username, os.Getenv("DB_PASSWORD"), settings.Host, settings.Database)
+	case "sqlite":
+		store, err = openSQLiteStorage(settings.SQLitePath)
+	default:
+		return nil, errors.New("unknown storage driver '" + settings.Driver + "', expected mysql or sqlite")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := targetSchemaVersion()
+	if err == nil {
+		err = store.migrate(target)
+	}
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
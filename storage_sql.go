@@ -0,0 +1,950 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+/**
+The parts of the SQL that differ between MySQL and SQLite.
+*/
+type sqlDialect struct {
+	name string
+	// column definition of an auto-incrementing integer primary key
+	autoIncrementKey string
+	// turns a plain INSERT into an upsert on the given key columns
+	upsert func(keys []string, columns []string) string
+	// changes the type of a column; empty if the database doesn't enforce column types
+	modifyColumn func(table string, column string, definition string) string
+	// whether an error is a primary key or unique index violation
+	duplicateKey func(err error) bool
+}
+
+var mysqlDialect = sqlDialect{
+	name:             "mysql",
+	autoIncrementKey: "int(11) NOT NULL AUTO_INCREMENT PRIMARY KEY",
+	upsert: func(keys []string, columns []string) string {
+		var updates []string
+		for _, column := range columns {
+			updates = append(updates, column+" = VALUES("+column+")")
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	},
+	modifyColumn: func(table string, column string, definition string) string {
+		return "ALTER TABLE " + table + " MODIFY " + column + " " + definition + ";"
+	},
+	duplicateKey: func(err error) bool {
+		var mysqlErr *mysql.MySQLError
+		return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+	},
+}
+
+var sqliteDialect = sqlDialect{
+	name:             "sqlite",
+	autoIncrementKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	upsert: func(keys []string, columns []string) string {
+		var updates []string
+		for _, column := range columns {
+			updates = append(updates, column+" = excluded."+column)
+		}
+		return " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ")
+	},
+	modifyColumn: func(table string, column string, definition string) string {
+		return ""
+	},
+	duplicateKey: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+	},
+}
+
+/**
+Storage backed by a SQL database.
+*/
+type sqlStorage struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// these columns are listed explicitly so the scans don't depend on the column order
+const activityColumns = "entry, guild_id, member_id, member_name, last_active, description, COALESCE(whitelist, 0)"
+const leaderboardColumns = "entry, guild_id, member_id, member_name, points, last_awarded"
+const greeterColumns = "entry, guild_id, channel_id, message_type, image_link, message"
+
+/**
+Connects to MySQL/MariaDB, waiting up to 90 seconds for the server to come up.
+*/
+func openMySQLStorage(username string, password string, host string, name string) (*sqlStorage, error) {
+	// parseTime lets DATETIME columns be read as time.Time, in UTC
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?parseTime=true", username, password, host, name))
+	if err != nil {
+		return nil, err
+	}
+	logInfo("Connecting to " + username + "@" + host + "/" + name)
+	for retry := 90; retry > 0; retry-- {
+		err = db.Ping()
+		if err == nil {
+			break
+		}
+		logWarning("Database isn't reachable yet! " + err.Error())
+		time.Sleep(1 * time.Second)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	logInfo("Connected to database.")
+	return &sqlStorage{db: db, dialect: mysqlDialect}, nil
+}
+
+/**
+Opens (or creates) the SQLite database at the path. ":memory:" gives a
+throwaway database, which is handy for tests.
+*/
+func openSQLiteStorage(path string) (*sqlStorage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time, and each connection to :memory: is its own database
+	db.SetMaxOpenConns(1)
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	logInfo("Opened SQLite database " + path)
+	return &sqlStorage{db: db, dialect: sqliteDialect}, nil
+}
+
+/**
+Runs a query, recording how long it took and whether it failed.
+*/
+func (store *sqlStorage) query(statement string, args ...interface{}) (*sql.Rows, error) {
+	operation := queryOperation(statement)
+	started := time.Now()
+	rows, err := store.db.Query(statement, args...)
+	dbQueryDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(operation).Inc()
+	}
+	return rows, err
+}
+
+/**
+Runs a statement that doesn't return rows, recording how long it took and
+whether it failed.
+*/
+func (store *sqlStorage) exec(statement string, args ...interface{}) error {
+	operation := queryOperation(statement)
+	started := time.Now()
+	_, err := store.db.Exec(statement, args...)
+	dbQueryDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+/**
+Inserts a row into a table with an auto-incrementing key, returning the key it got.
+*/
+func (store *sqlStorage) insert(statement string, args ...interface{}) (int64, error) {
+	operation := queryOperation(statement)
+	started := time.Now()
+	result, err := store.db.Exec(statement, args...)
+	dbQueryDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(operation).Inc()
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+/**
+Inserts a row, or updates the given columns if a row with the same key exists.
+*/
+func (store *sqlStorage) upsert(table string, keys []string, columns []string, args ...interface{}) error {
+	all := append(append([]string{}, keys...), columns...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(all)), ", ")
+	return store.exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(all, ", "), placeholders)+
+		store.dialect.upsert(keys, columns)+";", args...)
+}
+
+func (store *sqlStorage) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+func (store *sqlStorage) Close() error {
+	return store.db.Close()
+}
+
+/**
+Returns the time stored in a nullable DATETIME column, or the zero time if it
+is NULL.
+*/
+func fromDatetime(value sql.NullTime) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+	return value.Time.UTC()
+}
+
+/**
+Returns the value to store a time in a nullable DATETIME column as: NULL for
+the zero time, which MySQL can't store.
+*/
+func toDatetime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+/****
+MEMBER ACTIVITY
+****/
+func scanMemberActivities(rows *sql.Rows) ([]MemberActivity, error) {
+	defer rows.Close()
+	var activities []MemberActivity
+	for rows.Next() {
+		var memberActivity MemberActivity
+		var lastActive sql.NullTime
+		err := rows.Scan(&memberActivity.ID, &memberActivity.GuildID, &memberActivity.MemberID, &memberActivity.MemberName, &lastActive, &memberActivity.Description, &memberActivity.Whitelisted)
+		if err != nil {
+			return nil, err
+		}
+		memberActivity.LastActive = fromDatetime(lastActive)
+		activities = append(activities, memberActivity)
+	}
+	return activities, rows.Err()
+}
+
+func (store *sqlStorage) AddMemberActivity(guildID string, memberID string, memberName string, lastActive time.Time, description string) error {
+	return store.exec(fmt.Sprintf("INSERT INTO %s (guild_id, member_id, member_name, last_active, description, whitelist) VALUES (?, ?, ?, ?, ?, false);", activityTable),
+		guildID, memberID, memberName, lastActive.UTC(), description)
+}
+
+func (store *sqlStorage) UpdateMemberActivity(guildID string, memberID string, memberName string, lastActive time.Time, description string) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET last_active = ?, description = ?, member_name = ? WHERE (guild_id = ? AND member_id = ?);", activityTable),
+		lastActive.UTC(), description, memberName, guildID, memberID)
+}
+
+func (store *sqlStorage) GetMemberActivity(guildID string, memberID string) (*MemberActivity, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ? AND member_id = ?);", activityColumns, activityTable), guildID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	activities, err := scanMemberActivities(rows)
+	if err != nil || len(activities) == 0 {
+		return nil, err
+	}
+	return &activities[0], nil
+}
+
+func (store *sqlStorage) GetGuildActivity(guildID string) ([]MemberActivity, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ?);", activityColumns, activityTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemberActivities(rows)
+}
+
+func (store *sqlStorage) GetKickableActivity(guildID string) ([]MemberActivity, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ? AND (whitelist = false OR whitelist IS NULL));", activityColumns, activityTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMemberActivities(rows)
+}
+
+func (store *sqlStorage) SetWhitelisted(guildID string, memberID string, whitelisted bool) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET whitelist = ? WHERE (guild_id = ? AND member_id = ?);", activityTable),
+		whitelisted, guildID, memberID)
+}
+
+func (store *sqlStorage) RemoveMemberActivity(guildID string, memberID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND member_id = ?);", activityTable), guildID, memberID)
+}
+
+func (store *sqlStorage) RemoveGuildActivity(guildID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ?);", activityTable), guildID)
+}
+
+/****
+LEADERBOARD
+****/
+func scanLeaderboardEntries(rows *sql.Rows) ([]LeaderboardEntry, error) {
+	defer rows.Close()
+	var entries []LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var lastAwarded sql.NullTime
+		err := rows.Scan(&entry.ID, &entry.GuildID, &entry.MemberID, &entry.MemberName, &entry.Points, &lastAwarded)
+		if err != nil {
+			return nil, err
+		}
+		entry.LastAwarded = fromDatetime(lastAwarded)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (store *sqlStorage) GetLeaderboard(guildID string) ([]LeaderboardEntry, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ?);", leaderboardColumns, leaderboardTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLeaderboardEntries(rows)
+}
+
+func (store *sqlStorage) GetLeaderboardEntry(guildID string, memberID string) (*LeaderboardEntry, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ? AND member_id = ?);", leaderboardColumns, leaderboardTable), guildID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanLeaderboardEntries(rows)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (store *sqlStorage) AddLeaderboardEntry(entry LeaderboardEntry) error {
+	return store.exec(fmt.Sprintf("INSERT INTO %s (guild_id, member_id, member_name, points, last_awarded) VALUES (?, ?, ?, ?, ?);", leaderboardTable),
+		entry.GuildID, entry.MemberID, entry.MemberName, entry.Points, entry.LastAwarded.UTC())
+}
+
+func (store *sqlStorage) UpdateLeaderboardEntry(entry LeaderboardEntry) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET last_awarded = ?, points = ?, member_name = ? WHERE (guild_id = ? AND member_id = ?);", leaderboardTable),
+		entry.LastAwarded.UTC(), entry.Points, entry.MemberName, entry.GuildID, entry.MemberID)
+}
+
+/****
+GREETER
+****/
+func scanGreeterMessages(rows *sql.Rows) ([]GreeterMessage, error) {
+	defer rows.Close()
+	var messages []GreeterMessage
+	for rows.Next() {
+		var greeterMessage GreeterMessage
+		err := rows.Scan(&greeterMessage.ID, &greeterMessage.GuildID, &greeterMessage.ChannelID, &greeterMessage.MessageType, &greeterMessage.ImageLink, &greeterMessage.Message)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, greeterMessage)
+	}
+	return messages, rows.Err()
+}
+
+func (store *sqlStorage) GetGreeterMessages(guildID string) ([]GreeterMessage, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ?);", greeterColumns, joinLeaveTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	return scanGreeterMessages(rows)
+}
+
+func (store *sqlStorage) GetGreeterMessage(guildID string, messageType string) (*GreeterMessage, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE (guild_id = ? AND message_type = ?);", greeterColumns, joinLeaveTable), guildID, messageType)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := scanGreeterMessages(rows)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+/**
+Replaces the guild's message of the same type, if there is one.
+*/
+func (store *sqlStorage) SetGreeterMessage(message GreeterMessage) error {
+	err := store.RemoveGreeterMessage(message.GuildID, message.MessageType)
+	if err != nil {
+		return err
+	}
+	return store.exec(fmt.Sprintf("INSERT INTO %s (guild_id, channel_id, message_type, image_link, message) VALUES (?, ?, ?, ?, ?);", joinLeaveTable),
+		message.GuildID, message.ChannelID, message.MessageType, message.ImageLink, message.Message)
+}
+
+func (store *sqlStorage) RemoveGreeterMessage(guildID string, messageType string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND message_type = ?);", joinLeaveTable), guildID, messageType)
+}
+
+/****
+AUTO-KICK
+****/
+func (store *sqlStorage) getAutoKicks(where string, args ...interface{}) ([]AutoKickData, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, days_until_kick FROM %s%s;", autokickTable, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var autokicks []AutoKickData
+	for rows.Next() {
+		var autokickData AutoKickData
+		err = rows.Scan(&autokickData.GuildID, &autokickData.DaysUntilKick)
+		if err != nil {
+			return nil, err
+		}
+		autokicks = append(autokicks, autokickData)
+	}
+	return autokicks, rows.Err()
+}
+
+func (store *sqlStorage) GetAutoKicks() ([]AutoKickData, error) {
+	return store.getAutoKicks("")
+}
+
+func (store *sqlStorage) GetAutoKick(guildID string) (*AutoKickData, error) {
+	autokicks, err := store.getAutoKicks(" WHERE (guild_id = ?)", guildID)
+	if err != nil || len(autokicks) == 0 {
+		return nil, err
+	}
+	return &autokicks[0], nil
+}
+
+func (store *sqlStorage) SetAutoKick(guildID string, daysUntilKick int) error {
+	return store.upsert(autokickTable, []string{"guild_id"}, []string{"days_until_kick"}, guildID, daysUntilKick)
+}
+
+func (store *sqlStorage) RemoveAutoKick(guildID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ?);", autokickTable), guildID)
+}
+
+/****
+MOD LOG AND AUTOSHRINE CHANNELS
+****/
+func (store *sqlStorage) getChannels(table string, where string, args ...interface{}) ([]ModLogData, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, channel_id FROM %s%s;", table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var channels []ModLogData
+	for rows.Next() {
+		var channel ModLogData
+		err = rows.Scan(&channel.GuildID, &channel.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+func (store *sqlStorage) GetModLogChannels() ([]ModLogData, error) {
+	return store.getChannels(modLogTable, "")
+}
+
+func (store *sqlStorage) GetModLogChannel(guildID string) (string, error) {
+	channels, err := store.getChannels(modLogTable, " WHERE (guild_id = ?)", guildID)
+	if err != nil || len(channels) == 0 {
+		return "", err
+	}
+	return channels[0].ChannelID, nil
+}
+
+func (store *sqlStorage) SetModLogChannel(guildID string, channelID string) error {
+	return store.upsert(modLogTable, []string{"guild_id"}, []string{"channel_id"}, guildID, channelID)
+}
+
+func (store *sqlStorage) RemoveModLogChannel(guildID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ?);", modLogTable), guildID)
+}
+
+func (store *sqlStorage) GetDisabledModLogEvents(guildID string) ([]string, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT event FROM %s WHERE (guild_id = ?) ORDER BY event;", modLogEventTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []string
+	for rows.Next() {
+		var event string
+		err = rows.Scan(&event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/**
+Only disabled events are stored, so every event is logged until a guild turns it off.
+*/
+func (store *sqlStorage) SetModLogEventEnabled(guildID string, event string, enabled bool) error {
+	err := store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND event = ?);", modLogEventTable), guildID, event)
+	if err != nil || enabled {
+		return err
+	}
+	return store.exec(fmt.Sprintf("INSERT INTO %s (guild_id, event) VALUES (?, ?);", modLogEventTable), guildID, event)
+}
+
+func (store *sqlStorage) GetAutoshrineChannels() ([]ModLogData, error) {
+	return store.getChannels(autoshrineTable, "")
+}
+
+func (store *sqlStorage) SetAutoshrineChannel(guildID string, channelID string) error {
+	return store.upsert(autoshrineTable, []string{"guild_id"}, []string{"channel_id"}, guildID, channelID)
+}
+
+func (store *sqlStorage) RemoveAutoshrineChannel(guildID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ?);", autoshrineTable), guildID)
+}
+
+/****
+GUILD CONFIGURATION
+****/
+func (store *sqlStorage) GetGuildPrefix(guildID string) (string, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT prefix FROM %s WHERE (guild_id = ?);", guildSettingsTable), guildID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var prefix sql.NullString
+	for rows.Next() {
+		err = rows.Scan(&prefix)
+		if err != nil {
+			return "", err
+		}
+	}
+	return prefix.String, rows.Err()
+}
+
+/**
+Stores the guild's prefix, or clears it if the prefix is empty.
+*/
+func (store *sqlStorage) SetGuildPrefix(guildID string, prefix string) error {
+	var stored interface{}
+	if prefix != "" {
+		stored = prefix
+	}
+	return store.upsert(guildSettingsTable, []string{"guild_id"}, []string{"prefix"}, guildID, stored)
+}
+
+func (store *sqlStorage) GetCommandRules(guildID string) ([]CommandRule, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, command, channel_id, enabled FROM %s WHERE (guild_id = ?);", commandConfigTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []CommandRule{}
+	for rows.Next() {
+		var rule CommandRule
+		err = rows.Scan(&rule.GuildID, &rule.Command, &rule.ChannelID, &rule.Enabled)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (store *sqlStorage) SetCommandRule(rule CommandRule) error {
+	return store.upsert(commandConfigTable, []string{"guild_id", "command", "channel_id"}, []string{"enabled"},
+		rule.GuildID, rule.Command, rule.ChannelID, rule.Enabled)
+}
+
+func (store *sqlStorage) RemoveCommandRule(guildID string, command string, channelID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND command = ? AND channel_id = ?);", commandConfigTable), guildID, command, channelID)
+}
+
+func (store *sqlStorage) RemoveCommandRules(guildID string, command string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND command = ?);", commandConfigTable), guildID, command)
+}
+
+func (store *sqlStorage) GetPermissionOverrides(guildID string) ([]PermissionOverride, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, command, target_id, is_role, allow FROM %s WHERE (guild_id = ?);", permissionOverrideTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	overrides := []PermissionOverride{}
+	for rows.Next() {
+		var override PermissionOverride
+		err = rows.Scan(&override.GuildID, &override.Command, &override.TargetID, &override.IsRole, &override.Allow)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+func (store *sqlStorage) SetPermissionOverride(override PermissionOverride) error {
+	return store.upsert(permissionOverrideTable, []string{"guild_id", "command", "target_id"}, []string{"is_role", "allow"},
+		override.GuildID, override.Command, override.TargetID, override.IsRole, override.Allow)
+}
+
+func (store *sqlStorage) RemovePermissionOverride(guildID string, command string, targetID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND command = ? AND target_id = ?);", permissionOverrideTable), guildID, command, targetID)
+}
+
+func (store *sqlStorage) RemovePermissionOverrides(guildID string, command string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (guild_id = ? AND command = ?);", permissionOverrideTable), guildID, command)
+}
+
+/****
+PAGINATORS
+****/
+func (store *sqlStorage) GetPaginators() ([]Paginator, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT message_id, channel_id, guild_id, author_id, pages, page, expires_at FROM %s;", paginatorTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paginators []Paginator
+	for rows.Next() {
+		var paginator Paginator
+		var authorID sql.NullString
+		var pages string
+		var expiresAt sql.NullTime
+		err = rows.Scan(&paginator.MessageID, &paginator.ChannelID, &paginator.GuildID, &authorID, &pages, &paginator.Page, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(pages), &paginator.Pages)
+		if err != nil {
+			return nil, fmt.Errorf("pages of paginator %s: %w", paginator.MessageID, err)
+		}
+		paginator.AuthorID = authorID.String
+		paginator.ExpiresAt = fromDatetime(expiresAt)
+		paginators = append(paginators, paginator)
+	}
+	return paginators, rows.Err()
+}
+
+func (store *sqlStorage) AddPaginator(paginator Paginator) error {
+	pages, err := json.Marshal(paginator.Pages)
+	if err != nil {
+		return err
+	}
+	return store.exec(fmt.Sprintf("INSERT INTO %s (message_id, channel_id, guild_id, author_id, pages, page, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?);", paginatorTable),
+		paginator.MessageID, paginator.ChannelID, paginator.GuildID, paginator.AuthorID, string(pages), paginator.Page, paginator.ExpiresAt.UTC())
+}
+
+func (store *sqlStorage) SetPaginatorPage(messageID string, page int) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET page = ? WHERE (message_id = ?);", paginatorTable), page, messageID)
+}
+
+func (store *sqlStorage) RemovePaginator(messageID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE (message_id = ?);", paginatorTable), messageID)
+}
+
+/****
+JOB STATUS
+****/
+
+func (store *sqlStorage) GetJobStatuses() ([]JobStatus, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT name, last_run, last_success, last_error, failures, paused FROM %s;", jobStatusTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var statuses []JobStatus
+	for rows.Next() {
+		var status JobStatus
+		var lastRun, lastSuccess sql.NullTime
+		var lastError sql.NullString
+		err = rows.Scan(&status.Name, &lastRun, &lastSuccess, &lastError, &status.Failures, &status.Paused)
+		if err != nil {
+			return nil, err
+		}
+		status.LastRun = fromDatetime(lastRun)
+		status.LastSuccess = fromDatetime(lastSuccess)
+		status.LastError = lastError.String
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+func (store *sqlStorage) SetJobStatus(status JobStatus) error {
+	return store.upsert(jobStatusTable, []string{"name"}, []string{"last_run", "last_success", "last_error", "failures", "paused"},
+		status.Name, toDatetime(status.LastRun), toDatetime(status.LastSuccess), status.LastError, status.Failures, status.Paused)
+}
+
+/****
+WARNINGS
+****/
+
+func scanWarnings(rows *sql.Rows) ([]Warning, error) {
+	defer rows.Close()
+	var warnings []Warning
+	for rows.Next() {
+		var warning Warning
+		var createdAt sql.NullTime
+		err := rows.Scan(&warning.ID, &warning.GuildID, &warning.UserID, &warning.ModeratorID, &warning.Reason, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		warning.CreatedAt = fromDatetime(createdAt)
+		warnings = append(warnings, warning)
+	}
+	return warnings, rows.Err()
+}
+
+func (store *sqlStorage) AddWarning(warning Warning) (int64, error) {
+	return store.insert(fmt.Sprintf("INSERT INTO %s (guild_id, user_id, moderator_id, reason, created_at) VALUES (?, ?, ?, ?, ?);", warningsTable),
+		warning.GuildID, warning.UserID, warning.ModeratorID, warning.Reason, toDatetime(warning.CreatedAt))
+}
+
+func (store *sqlStorage) GetWarnings(guildID string, userID string) ([]Warning, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT id, guild_id, user_id, moderator_id, reason, created_at FROM %s WHERE guild_id = ? AND user_id = ? ORDER BY id;", warningsTable), guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanWarnings(rows)
+}
+
+func (store *sqlStorage) GetWarning(guildID string, id int64) (*Warning, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT id, guild_id, user_id, moderator_id, reason, created_at FROM %s WHERE guild_id = ? AND id = ?;", warningsTable), guildID, id)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := scanWarnings(rows)
+	if err != nil || len(warnings) == 0 {
+		return nil, err
+	}
+	return &warnings[0], nil
+}
+
+func (store *sqlStorage) RemoveWarning(guildID string, id int64) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE guild_id = ? AND id = ?;", warningsTable), guildID, id)
+}
+
+func (store *sqlStorage) ClearWarnings(guildID string, userID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE guild_id = ? AND user_id = ?;", warningsTable), guildID, userID)
+}
+
+func (store *sqlStorage) GetEscalationSteps(guildID string) ([]EscalationStep, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, warnings, action, duration_seconds FROM %s WHERE guild_id = ? ORDER BY warnings;", escalationTable), guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var steps []EscalationStep
+	for rows.Next() {
+		var step EscalationStep
+		var seconds int64
+		err = rows.Scan(&step.GuildID, &step.Warnings, &step.Action, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		step.Duration = time.Duration(seconds) * time.Second
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+func (store *sqlStorage) SetEscalationStep(step EscalationStep) error {
+	return store.upsert(escalationTable, []string{"guild_id", "warnings"}, []string{"action", "duration_seconds"},
+		step.GuildID, step.Warnings, step.Action, int64(step.Duration/time.Second))
+}
+
+func (store *sqlStorage) RemoveEscalationStep(guildID string, warnings int) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE guild_id = ? AND warnings = ?;", escalationTable), guildID, warnings)
+}
+
+/****
+TEMPORARY BANS
+****/
+
+func scanTempBans(rows *sql.Rows) ([]TempBan, error) {
+	defer rows.Close()
+	var bans []TempBan
+	for rows.Next() {
+		var ban TempBan
+		var expiresAt sql.NullTime
+		err := rows.Scan(&ban.GuildID, &ban.UserID, &ban.ModeratorID, &ban.Reason, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		ban.ExpiresAt = fromDatetime(expiresAt)
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+func (store *sqlStorage) AddTempBan(ban TempBan) error {
+	return store.upsert(tempBanTable, []string{"guild_id", "user_id"}, []string{"moderator_id", "reason", "expires_at"},
+		ban.GuildID, ban.UserID, ban.ModeratorID, ban.Reason, toDatetime(ban.ExpiresAt))
+}
+
+func (store *sqlStorage) GetTempBan(guildID string, userID string) (*TempBan, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, user_id, moderator_id, reason, expires_at FROM %s WHERE guild_id = ? AND user_id = ?;", tempBanTable), guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	bans, err := scanTempBans(rows)
+	if err != nil || len(bans) == 0 {
+		return nil, err
+	}
+	return &bans[0], nil
+}
+
+func (store *sqlStorage) GetExpiredTempBans(now time.Time) ([]TempBan, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT guild_id, user_id, moderator_id, reason, expires_at FROM %s WHERE expires_at <= ? ORDER BY expires_at;", tempBanTable), toDatetime(now))
+	if err != nil {
+		return nil, err
+	}
+	return scanTempBans(rows)
+}
+
+func (store *sqlStorage) RemoveTempBan(guildID string, userID string) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE guild_id = ? AND user_id = ?;", tempBanTable), guildID, userID)
+}
+
+/****
+MODERATION CASES
+****/
+
+const caseColumns = "guild_id, case_number, action, user_id, moderator_id, reason, duration_seconds, details, log_channel_id, log_message_id, created_at"
+
+func scanCases(rows *sql.Rows) ([]ModCase, error) {
+	defer rows.Close()
+	var cases []ModCase
+	for rows.Next() {
+		var modCase ModCase
+		var seconds int64
+		var createdAt sql.NullTime
+		err := rows.Scan(&modCase.GuildID, &modCase.Number, &modCase.Action, &modCase.UserID, &modCase.ModeratorID, &modCase.Reason,
+			&seconds, &modCase.Details, &modCase.LogChannelID, &modCase.LogMessageID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		modCase.Duration = time.Duration(seconds) * time.Second
+		modCase.CreatedAt = fromDatetime(createdAt)
+		cases = append(cases, modCase)
+	}
+	return cases, rows.Err()
+}
+
+/**
+Stores a case under the guild's next case number, which is returned. If
+another case takes the same number first, the unique index rejects the insert
+and it is retried with the number after that.
+*/
+func (store *sqlStorage) AddCase(modCase ModCase) (int, error) {
+	const attempts = 5
+	var id int64
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		id, err = store.insert(fmt.Sprintf("INSERT INTO %s (%s) SELECT ?, COALESCE(MAX(case_number), 0) + 1, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM %s WHERE guild_id = ?;", caseTable, caseColumns, caseTable),
+			modCase.GuildID, modCase.Action, modCase.UserID, modCase.ModeratorID, modCase.Reason, int64(modCase.Duration/time.Second),
+			modCase.Details, modCase.LogChannelID, modCase.LogMessageID, toDatetime(modCase.CreatedAt), modCase.GuildID)
+		if err == nil || !store.dialect.duplicateKey(err) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	rows, err := store.query(fmt.Sprintf("SELECT case_number FROM %s WHERE id = ?;", caseTable), id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	number := 0
+	if rows.Next() {
+		err = rows.Scan(&number)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return number, err
+}
+
+func (store *sqlStorage) GetCase(guildID string, number int) (*ModCase, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE guild_id = ? AND case_number = ?;", caseColumns, caseTable), guildID, number)
+	if err != nil {
+		return nil, err
+	}
+	cases, err := scanCases(rows)
+	if err != nil || len(cases) == 0 {
+		return nil, err
+	}
+	return &cases[0], nil
+}
+
+func (store *sqlStorage) GetCases(guildID string, userID string) ([]ModCase, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT %s FROM %s WHERE guild_id = ? AND user_id = ? ORDER BY case_number;", caseColumns, caseTable), guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCases(rows)
+}
+
+func (store *sqlStorage) SetCaseReason(guildID string, number int, reason string) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET reason = ? WHERE guild_id = ? AND case_number = ?;", caseTable), reason, guildID, number)
+}
+
+func (store *sqlStorage) SetCaseMessage(guildID string, number int, channelID string, messageID string) error {
+	return store.exec(fmt.Sprintf("UPDATE %s SET log_channel_id = ?, log_message_id = ? WHERE guild_id = ? AND case_number = ?;", caseTable), channelID, messageID, guildID, number)
+}
+
+/****
+MESSAGE CACHE
+****/
+
+func (store *sqlStorage) GetCachedMessages(since time.Time) ([]CachedMessage, error) {
+	rows, err := store.query(fmt.Sprintf("SELECT message, cached_at FROM %s WHERE cached_at >= ? ORDER BY cached_at;", messageCacheTable), since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []CachedMessage
+	for rows.Next() {
+		var cached CachedMessage
+		var message string
+		var cachedAt sql.NullTime
+		err = rows.Scan(&message, &cachedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(message), &cached.Message)
+		if err != nil {
+			return nil, fmt.Errorf("cached message: %w", err)
+		}
+		cached.CachedAt = fromDatetime(cachedAt)
+		messages = append(messages, cached)
+	}
+	return messages, rows.Err()
+}
+
+func (store *sqlStorage) CacheMessage(cached CachedMessage) error {
+	message, err := json.Marshal(cached.Message)
+	if err != nil {
+		return err
+	}
+	return store.upsert(messageCacheTable, []string{"channel_id", "message_id"}, []string{"message", "cached_at"},
+		cached.Message.ChannelID, cached.Message.ID, string(message), cached.CachedAt.UTC())
+}
+
+func (store *sqlStorage) UncacheMessages(channelID string, messageIDs ...string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	args := []interface{}{channelID}
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(messageIDs)), ", ")
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE channel_id = ? AND message_id IN (%s);", messageCacheTable, placeholders), args...)
+}
+
+func (store *sqlStorage) RemoveCachedMessagesBefore(cutoff time.Time) error {
+	return store.exec(fmt.Sprintf("DELETE FROM %s WHERE cached_at < ?;", messageCacheTable), cutoff.UTC())
+}
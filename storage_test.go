@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+/**
+Test the SQL storage against an in-memory SQLite database, so no database
+server is needed.
+**/
+func TestStorage(t *testing.T) {
+	loadTableNames()
+	store, err := openSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("Unable to open SQLite: %s", err.Error())
+	}
+	defer store.Close()
+	if err = store.migrate(len(migrations)); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	t.Run("member activity", func(t *testing.T) {
+		joined := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		// written in another zone, it should come back as the same instant in UTC
+		active := time.Date(2026, 10, 17, 14, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+		store.AddMemberActivity("1", "a", "alice#0001", joined, "Joined the server")
+		store.AddMemberActivity("1", "b", "bob#0002", joined, "Joined the server")
+		store.UpdateMemberActivity("1", "a", "alice#0001", active, "Wrote a message")
+		store.SetWhitelisted("1", "b", true)
+
+		activity, err := store.GetMemberActivity("1", "a")
+		if err != nil || activity == nil || !activity.LastActive.Equal(active) || activity.LastActive.Location() != time.UTC || activity.Whitelisted != 0 {
+			t.Logf("Unexpected activity %+v (%v)", activity, err)
+			t.Fail()
+		}
+		kickable, err := store.GetKickableActivity("1")
+		if err != nil || len(kickable) != 1 || kickable[0].MemberID != "a" {
+			t.Logf("Only alice should be kickable, got %+v (%v)", kickable, err)
+			t.Fail()
+		}
+
+		store.RemoveMemberActivity("1", "a")
+		if activity, _ = store.GetMemberActivity("1", "a"); activity != nil {
+			t.Logf("alice should have been removed")
+			t.Fail()
+		}
+		store.RemoveGuildActivity("1")
+		if activities, _ := store.GetGuildActivity("1"); len(activities) != 0 {
+			t.Logf("The guild should have been removed")
+			t.Fail()
+		}
+	})
+
+	t.Run("leaderboard", func(t *testing.T) {
+		store.AddLeaderboardEntry(LeaderboardEntry{GuildID: "1", MemberID: "a", MemberName: "alice#0001", Points: 5, LastAwarded: time.Now()})
+		entry, err := store.GetLeaderboardEntry("1", "a")
+		if err != nil || entry == nil {
+			t.Fatalf("The entry was not stored (%v)", err)
+		}
+		entry.Points += 10
+		store.UpdateLeaderboardEntry(*entry)
+		leaderboard, _ := store.GetLeaderboard("1")
+		if len(leaderboard) != 1 || leaderboard[0].Points != 15 || !leaderboard[0].LastAwarded.Equal(entry.LastAwarded) {
+			t.Logf("Unexpected leaderboard %+v", leaderboard)
+			t.Fail()
+		}
+	})
+
+	t.Run("greeter messages replace each other", func(t *testing.T) {
+		store.SetGreeterMessage(GreeterMessage{GuildID: "1", ChannelID: "c", MessageType: "join", Message: "hi"})
+		store.SetGreeterMessage(GreeterMessage{GuildID: "1", ChannelID: "c", MessageType: "join", Message: "hello"})
+		store.SetGreeterMessage(GreeterMessage{GuildID: "1", ChannelID: "c", MessageType: "leave", Message: "bye"})
+		messages, _ := store.GetGreeterMessages("1")
+		message, _ := store.GetGreeterMessage("1", "join")
+		if len(messages) != 2 || message == nil || message.Message != "hello" {
+			t.Logf("Unexpected greeter messages %+v", messages)
+			t.Fail()
+		}
+		store.RemoveGreeterMessage("1", "join")
+		if message, _ = store.GetGreeterMessage("1", "join"); message != nil {
+			t.Logf("The join message should have been removed")
+			t.Fail()
+		}
+	})
+
+	t.Run("channels and auto-kick are upserted", func(t *testing.T) {
+		store.SetModLogChannel("1", "old")
+		store.SetModLogChannel("1", "new")
+		store.SetAutoshrineChannel("2", "shrine")
+		store.SetAutoKick("1", 30)
+		store.SetAutoKick("1", 7)
+
+		channel, _ := store.GetModLogChannel("1")
+		channels, _ := store.GetModLogChannels()
+		if channel != "new" || len(channels) != 1 {
+			t.Logf("Unexpected mod log channels %+v", channels)
+			t.Fail()
+		}
+		if shrines, _ := store.GetAutoshrineChannels(); len(shrines) != 1 || shrines[0].ChannelID != "shrine" {
+			t.Logf("Unexpected autoshrine channels %+v", shrines)
+			t.Fail()
+		}
+		autokick, _ := store.GetAutoKick("1")
+		if autokick == nil || autokick.DaysUntilKick != 7 {
+			t.Logf("Unexpected auto-kick %+v", autokick)
+			t.Fail()
+		}
+		store.RemoveAutoKick("1")
+		if autokicks, _ := store.GetAutoKicks(); len(autokicks) != 0 {
+			t.Logf("Auto-kick should have been removed")
+			t.Fail()
+		}
+	})
+
+	t.Run("guild configuration", func(t *testing.T) {
+		store.SetGuildPrefix("1", "!")
+		if prefix, _ := store.GetGuildPrefix("1"); prefix != "!" {
+			t.Logf("Expected prefix !, got %s", prefix)
+			t.Fail()
+		}
+		store.SetGuildPrefix("1", "")
+		if prefix, _ := store.GetGuildPrefix("1"); prefix != "" {
+			t.Logf("The prefix should have been cleared, got %s", prefix)
+			t.Fail()
+		}
+
+		store.SetCommandRule(CommandRule{GuildID: "1", Command: "image", ChannelID: "", Enabled: false})
+		store.SetCommandRule(CommandRule{GuildID: "1", Command: "image", ChannelID: "c", Enabled: true})
+		store.SetCommandRule(CommandRule{GuildID: "1", Command: "image", ChannelID: "c", Enabled: false})
+		rules, _ := store.GetCommandRules("1")
+		if len(rules) != 2 || rulesAllow(rules, "image", "c") {
+			t.Logf("Unexpected command rules %+v", rules)
+			t.Fail()
+		}
+		store.RemoveCommandRules("1", "image")
+		if rules, _ = store.GetCommandRules("1"); len(rules) != 0 {
+			t.Logf("The command rules should have been removed")
+			t.Fail()
+		}
+
+		store.SetPermissionOverride(PermissionOverride{GuildID: "1", Command: "purge", TargetID: "r", IsRole: true, Allow: false})
+		store.SetPermissionOverride(PermissionOverride{GuildID: "1", Command: "purge", TargetID: "r", IsRole: true, Allow: true})
+		overrides, _ := store.GetPermissionOverrides("1")
+		if len(overrides) != 1 || !overrides[0].Allow || !overrides[0].IsRole {
+			t.Logf("Unexpected overrides %+v", overrides)
+			t.Fail()
+		}
+		store.RemovePermissionOverride("1", "purge", "r")
+		if overrides, _ = store.GetPermissionOverrides("1"); len(overrides) != 0 {
+			t.Logf("The override should have been removed")
+			t.Fail()
+		}
+	})
+
+	t.Run("a case number can only be taken once", func(t *testing.T) {
+		number, err := store.AddCase(ModCase{GuildID: "2", Action: "warn", UserID: "u", CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("Unable to add the case: %s", err.Error())
+		}
+		err = store.exec(fmt.Sprintf("INSERT INTO %s (guild_id, case_number, action, user_id) VALUES (?, ?, ?, ?);", caseTable), "2", number, "warn", "u")
+		if err == nil || !store.dialect.duplicateKey(err) {
+			t.Logf("Expected the duplicate case number to be rejected, got %v", err)
+			t.Fail()
+		}
+		if err = store.exec("SELECT * FROM missing_table;"); err == nil || store.dialect.duplicateKey(err) {
+			t.Logf("Other errors shouldn't count as duplicates, got %v", err)
+			t.Fail()
+		}
+	})
+}
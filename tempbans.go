@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var tempBanTable string
+
+/**
+A ban made with ~tempban, which is lifted once it expires.
+*/
+type TempBan struct {
+	GuildID     string
+	UserID      string
+	ModeratorID string
+	Reason      string
+	ExpiresAt   time.Time
+}
+
+/**
+Formats a time so Discord shows it in each reader's own time zone.
+*/
+func discordTimestamp(t time.Time) string {
+	return fmt.Sprintf("<t:%d:f>", t.Unix())
+}
+
+/**
+Lifts every temporary ban that has expired. Bans that were already lifted by
+hand are forgotten; the others are kept and retried on the next run if Discord
+refuses.
+*/
+func liftExpiredTempBans(s *discordgo.Session, now time.Time) error {
+	bans, err := storage.GetExpiredTempBans(now)
+	if err != nil {
+		return err
+	}
+	var failed error
+	for _, ban := range bans {
+		err = s.GuildBanDelete(ban.GuildID, ban.UserID)
+		var restErr *discordgo.RESTError
+		if err != nil && !(errors.As(err, &restErr) && restErr.Response.StatusCode == http.StatusNotFound) {
+			logError(fmt.Sprintf("Unable to lift the temporary ban of %s in %s! %s", ban.UserID, ban.GuildID, err.Error()))
+			failed = err
+			continue
+		}
+		logInfo(fmt.Sprintf("Lifted the temporary ban of %s in %s", ban.UserID, ban.GuildID))
+		recordCase(s, ModCase{GuildID: ban.GuildID, Action: "unban", UserID: ban.UserID, ModeratorID: s.State.User.ID, Reason: "Temporary ban expired"})
+		err = storage.RemoveTempBan(ban.GuildID, ban.UserID)
+		if err != nil {
+			return err
+		}
+	}
+	return failed
+}
+
+/**
+Forgets a user's temporary ban, if they have one, so it is never lifted
+automatically. Used when the user is banned for good or unbanned by hand.
+*/
+func forgetTempBan(guildID string, userID string) {
+	err := storage.RemoveTempBan(guildID, userID)
+	if err != nil {
+		logError("Unable to forget the temporary ban! " + err.Error())
+	}
+}
+
+/****
+COMMANDS
+****/
+
+/**
+Times a member out so they can't talk, react or join voice until it runs out.
+**/
+func handleTimeout(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	duration := args.Durations["duration"]
+	reason := args.Values["reason"]
+	if duration > maxTimeout {
+		attemptSendMsg(s, m, "Timeouts can last up to 28 days.")
+		return
+	}
+	member, err := s.GuildMember(m.GuildID, userID)
+	if err != nil {
+		logWarning("Unable to find the member to time out! " + err.Error())
+		attemptSendMsg(s, m, "That user isn't a member of this server.")
+		return
+	}
+
+	until := time.Now().Add(duration)
+	err = s.GuildMemberTimeout(m.GuildID, userID, &until)
+	if err != nil {
+		logError("Failed to time out user! " + err.Error())
+		sendError(s, m, "timeout", Discord)
+		return
+	}
+
+	dm := fmt.Sprintf("You have been timed out in **%s** by %s for %s", guildNameFor(s, m.GuildID), m.Author.String(), describeDuration(duration))
+	response := fmt.Sprintf(":mute: Timed out <@%s> for %s", userID, describeDuration(duration))
+	if reason != "" {
+		dm += " because: " + reason
+		response += fmt.Sprintf(" for the following reason: '%s'", reason)
+	}
+	dmUser(s, userID, dm+"\n")
+
+	recordCase(s, ModCase{GuildID: m.GuildID, Action: "timeout", UserID: member.User.ID, ModeratorID: m.Author.ID, Reason: reason, Duration: duration})
+	sendSuccess(s, m, response+".")
+}
+
+/**
+Bans a user until the duration runs out. The expiry is stored, so the ban is
+lifted even if the bot restarts in between.
+**/
+func handleTempBan(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	duration := args.Durations["duration"]
+	reason := args.Values["reason"]
+
+	// stored before banning, so there is never a ban nothing will lift
+	ban := TempBan{GuildID: m.GuildID, UserID: userID, ModeratorID: m.Author.ID, Reason: reason, ExpiresAt: time.Now().Add(duration)}
+	err := storage.AddTempBan(ban)
+	if err != nil {
+		logError("Unable to save the temporary ban! " + err.Error())
+		sendError(s, m, "tempban", Database)
+		return
+	}
+
+	dm := fmt.Sprintf("You have been banned from **%s** by %s for %s", guildNameFor(s, m.GuildID), m.Author.String(), describeDuration(duration))
+	response := fmt.Sprintf(":hammer: Banned <@%s> for %s", userID, describeDuration(duration))
+	if reason != "" {
+		dm += " because: " + reason
+		response += fmt.Sprintf(" for the following reason: '%s'", reason)
+	}
+	dmUser(s, userID, dm+"\n")
+
+	err = s.GuildBanCreateWithReason(m.GuildID, userID, reason, 0)
+	if err != nil {
+		logError("Failed to ban user! " + err.Error())
+		if err = storage.RemoveTempBan(m.GuildID, userID); err != nil {
+			logError("Unable to forget the failed temporary ban! " + err.Error())
+		}
+		sendError(s, m, "tempban", Discord)
+		return
+	}
+	recordCase(s, ModCase{GuildID: m.GuildID, Action: "ban", UserID: userID, ModeratorID: m.Author.ID, Reason: reason, Duration: duration})
+	sendSuccess(s, m, response+".")
+}
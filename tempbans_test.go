@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that timeouts and temporary bans are applied, logged and lifted.
+**/
+func TestTempBans(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	moderators := fake.AddRole("moderators", discordgo.PermissionModerateMembers|discordgo.PermissionBanMembers)
+	mod := fake.AddUser("mod", moderators.ID)
+	target := fake.AddUser("target")
+	modLog := fake.AddChannel("mod-log")
+	storage.SetModLogChannel(fake.Guild.ID, modLog.ID)
+
+	t.Run("timeouts are applied and logged", func(t *testing.T) {
+		response := fake.Reply(mod, "~timeout <@"+target.ID+"> 30m being rude").Content
+		if response != ":mute: Timed out <@"+target.ID+"> for 30 minutes for the following reason: 'being rude'." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		edits := fake.MemberEdits()
+		if len(edits) != 1 || edits[0].UserID != target.ID {
+			t.Fatalf("Expected a timeout, got %+v", edits)
+		}
+		until, err := time.Parse(time.RFC3339, edits[0].Fields["communication_disabled_until"].(string))
+		if err != nil || until.Sub(time.Now()) < 29*time.Minute || until.Sub(time.Now()) > 30*time.Minute {
+			t.Logf("Unexpected timeout end %v %v", until, err)
+			t.Fail()
+		}
+
+		logged := fake.SentTo(modLog.ID)
+		if len(logged) != 1 || len(logged[0].Embeds) != 1 {
+			t.Fatalf("Expected the timeout in the mod log, got %+v", logged)
+		}
+		embed := logged[0].Embeds[0]
+		if embed.Title != "🔇Timed Out target#1234" || !strings.Contains(embed.Description, "**Duration**: 30 minutes (until <t:") || !strings.Contains(embed.Description, "**Reason**: 'being rude'") {
+			t.Logf("Unexpected mod log embed %+v", embed)
+			t.Fail()
+		}
+
+		if response := fake.Reply(mod, "~timeout <@"+target.ID+"> 5w").Content; response != "Timeouts can last up to 28 days." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+
+	t.Run("temporary bans are stored and logged", func(t *testing.T) {
+		response := fake.Reply(mod, "~tempban <@"+target.ID+"> 2d posting scam links").Content
+		if response != ":hammer: Banned <@"+target.ID+"> for 2 days for the following reason: 'posting scam links'." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		bans := fake.Bans()
+		if len(bans) != 1 || bans[0].UserID != target.ID || bans[0].Reason != "posting scam links" {
+			t.Logf("Expected a ban, got %+v", bans)
+			t.Fail()
+		}
+		tempBan, err := storage.GetTempBan(fake.Guild.ID, target.ID)
+		if err != nil || tempBan == nil || tempBan.ExpiresAt.Sub(time.Now()) < 47*time.Hour {
+			t.Fatalf("Expected the ban to be stored, got %+v %v", tempBan, err)
+		}
+		logged := fake.SentTo(modLog.ID)
+		if embed := logged[len(logged)-1].Embeds[0]; embed.Title != "🚫Banned target#1234" || !strings.Contains(embed.Description, "**Duration**: 2 days (until <t:") {
+			t.Logf("Expected the length of the ban in %+v", embed)
+			t.Fail()
+		}
+	})
+
+	t.Run("expired bans are lifted", func(t *testing.T) {
+		if err := liftExpiredTempBans(fake.Session, time.Now().Add(24*time.Hour)); err != nil || len(fake.Unbans()) != 0 {
+			t.Logf("The ban shouldn't have been lifted yet: %v %v", fake.Unbans(), err)
+			t.Fail()
+		}
+		if err := liftExpiredTempBans(fake.Session, time.Now().Add(72*time.Hour)); err != nil {
+			t.Fatalf("Unable to lift the bans: %s", err.Error())
+		}
+		if unbans := fake.Unbans(); len(unbans) != 1 || unbans[0] != target.ID {
+			t.Logf("Expected the ban to be lifted, got %v", unbans)
+			t.Fail()
+		}
+		if tempBan, _ := storage.GetTempBan(fake.Guild.ID, target.ID); tempBan != nil {
+			t.Logf("The lifted ban should be forgotten, got %+v", tempBan)
+			t.Fail()
+		}
+		cases, _ := storage.GetCases(fake.Guild.ID, target.ID)
+		if len(cases) != 3 || cases[2].Action != "unban" || cases[2].Reason != "Temporary ban expired" {
+			t.Logf("Expected the unban to be recorded, got %+v", cases)
+			t.Fail()
+		}
+	})
+
+	t.Run("a permanent ban replaces a temporary one", func(t *testing.T) {
+		other := fake.AddUser("other")
+		fake.Reply(mod, "~tempban <@"+other.ID+"> 1h")
+		fake.Reply(mod, "~ban <@"+other.ID+"> for good")
+		if tempBan, _ := storage.GetTempBan(fake.Guild.ID, other.ID); tempBan != nil {
+			t.Logf("The permanent ban should never be lifted, got %+v", tempBan)
+			t.Fail()
+		}
+	})
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var warningsTable string
+var escalationTable string
+
+// Discord won't time anyone out for longer than this
+const maxTimeout = 28 * 24 * time.Hour
+
+/**
+A warning a moderator gave a member with ~warn.
+*/
+type Warning struct {
+	ID          int64
+	GuildID     string
+	UserID      string
+	ModeratorID string
+	Reason      string
+	CreatedAt   time.Time
+}
+
+/**
+What happens to a member when they reach a number of warnings in a guild.
+Action is "timeout", "kick" or "ban"; Duration is how long a timeout lasts.
+*/
+type EscalationStep struct {
+	GuildID  string
+	Warnings int
+	Action   string
+	Duration time.Duration
+}
+
+/**
+Describes what the step does to a member, e.g. "timed out for 1 hour".
+*/
+func (step EscalationStep) describe() string {
+	switch step.Action {
+	case "timeout":
+		return "timed out for " + describeDuration(step.Duration)
+	case "kick":
+		return "kicked"
+	default:
+		return "banned"
+	}
+}
+
+/**
+Returns the name of the guild, or a placeholder if it can't be loaded.
+*/
+func guildNameFor(s *discordgo.Session, guildID string) string {
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		logError("Unable to load guild! " + err.Error())
+		return "error: could not retrieve"
+	}
+	return guild.Name
+}
+
+/**
+Returns the escalation step a member reaches with this many warnings, or nil
+if there isn't one.
+*/
+func escalationFor(guildID string, warnings int) (*EscalationStep, error) {
+	steps, err := storage.GetEscalationSteps(guildID)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if step.Warnings == warnings {
+			return &step, nil
+		}
+	}
+	return nil, nil
+}
+
+/**
+Carries out an escalation step against a member.
+*/
+func escalate(s *discordgo.Session, step *EscalationStep, userID string) error {
+	reason := fmt.Sprintf("Reached %d warnings", step.Warnings)
+	switch step.Action {
+	case "timeout":
+		until := time.Now().Add(step.Duration)
+		return s.GuildMemberTimeout(step.GuildID, userID, &until)
+	case "kick":
+		return s.GuildMemberDeleteWithReason(step.GuildID, userID, reason)
+	default:
+		return s.GuildBanCreateWithReason(step.GuildID, userID, reason, 0)
+	}
+}
+
+/**
+Builds the mod log embed for a change to someone's warnings.
+*/
+func warningEmbed(title string, user *discordgo.User, actor *discordgo.User, details string) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Type:        "rich",
+		Title:       fmt.Sprintf("%s %s", title, user.String()),
+		Description: fmt.Sprintf("**Actor**: %s\n%s", actor.String(), details),
+		Thumbnail:   &discordgo.MessageEmbedThumbnail{URL: user.AvatarURL("512")},
+	}
+}
+
+/****
+COMMANDS
+****/
+
+/**
+Warns a member: the warning is stored, the member is DMed, the warning is
+posted to the mod log, and the member is punished if they reached a step of
+the escalation ladder.
+**/
+func handleWarn(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	reason := args.Values["reason"]
+	member, err := s.GuildMember(m.GuildID, userID)
+	if err != nil {
+		logWarning("Unable to find the member to warn! " + err.Error())
+		attemptSendMsg(s, m, "That user isn't a member of this server.")
+		return
+	}
+	if member.User.Bot {
+		attemptSendMsg(s, m, "Bots can't be warned.")
+		return
+	}
+
+	id, err := storage.AddWarning(Warning{GuildID: m.GuildID, UserID: userID, ModeratorID: m.Author.ID, Reason: reason, CreatedAt: time.Now()})
+	if err != nil {
+		logError("Unable to save the warning! " + err.Error())
+		sendError(s, m, "warn", Database)
+		return
+	}
+	warnings, err := storage.GetWarnings(m.GuildID, userID)
+	if err != nil {
+		logError("Unable to count the warnings! " + err.Error())
+		sendError(s, m, "warn", Database)
+		return
+	}
+	count := len(warnings)
+	step, err := escalationFor(m.GuildID, count)
+	if err != nil {
+		logError("Unable to read the escalation ladder! " + err.Error())
+	}
+
+	// the DM goes first, since a kicked or banned member can't be messaged
+	dm := fmt.Sprintf("You have been warned in **%s** by %s because: %s\nYou now have %d warning(s).", guildNameFor(s, m.GuildID), m.Author.String(), reason, count)
+	if step != nil {
+		dm += fmt.Sprintf(" Because of that, you have been %s.", step.describe())
+	}
+	dmUser(s, userID, dm)
+
+	response := fmt.Sprintf(":warning: Warned <@%s> for the following reason: '%s'. They have %d warning(s).", userID, reason, count)
+	details := fmt.Sprintf("**Warning**: #%d, %d in total", id, count)
+	if step != nil {
+		err = escalate(s, step, userID)
+		if err != nil {
+			logError("Failed to escalate the warning! " + err.Error())
+			response += fmt.Sprintf(" I couldn't get them %s, though.", step.describe())
+			details += "\n**Escalation**: failed to get them " + step.describe()
+		} else {
+			response += fmt.Sprintf(" They have been %s.", step.describe())
+		}
+	}
+
+	recordCase(s, ModCase{GuildID: m.GuildID, Action: "warn", UserID: member.User.ID, ModeratorID: m.Author.ID, Reason: reason, Details: details})
+	if step != nil && err == nil {
+		escalation := ModCase{GuildID: m.GuildID, Action: step.Action, UserID: member.User.ID, ModeratorID: m.Author.ID, Reason: fmt.Sprintf("Reached %d warnings", count)}
+		if step.Action == "timeout" {
+			escalation.Duration = step.Duration
+		}
+		recordCase(s, escalation)
+	}
+	sendSuccess(s, m, response)
+}
+
+/**
+Lists a member's warnings.
+**/
+func handleWarnings(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	warnings, err := storage.GetWarnings(m.GuildID, userID)
+	if err != nil {
+		logError("Unable to read the warnings! " + err.Error())
+		sendError(s, m, "warnings", Database)
+		return
+	}
+	if len(warnings) == 0 {
+		attemptSendMsg(s, m, fmt.Sprintf("<@%s> has no warnings.", userID))
+		return
+	}
+
+	var embed discordgo.MessageEmbed
+	embed.Type = "rich"
+	embed.Title = fmt.Sprintf("%d Warning(s)", len(warnings))
+	if user, err := s.User(userID); err == nil {
+		embed.Title += " for " + user.String()
+	}
+	for _, warning := range warnings {
+		embed.Fields = append(embed.Fields, createField(
+			fmt.Sprintf("#%d • %s", warning.ID, warning.CreatedAt.Format("Jan 2, 2006")),
+			fmt.Sprintf("%s\nby <@%s>", warning.Reason, warning.ModeratorID), false))
+	}
+
+	steps, err := storage.GetEscalationSteps(m.GuildID)
+	if err != nil {
+		logError("Unable to read the escalation ladder! " + err.Error())
+	}
+	for _, step := range steps {
+		if step.Warnings > len(warnings) {
+			embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("At %d warnings they will be %s.", step.Warnings, step.describe())}
+			break
+		}
+	}
+
+	_, err = sendEmbed(s, m, &embed)
+	if err != nil {
+		logError("Failed to send the warnings! " + err.Error())
+	}
+}
+
+/**
+Removes a single warning by its number.
+**/
+func handleUnwarn(s *discordgo.Session, m *Invocation, args *Args) {
+	id := int64(args.Ints["id"])
+	warning, err := storage.GetWarning(m.GuildID, id)
+	if err != nil {
+		logError("Unable to read the warning! " + err.Error())
+		sendError(s, m, "unwarn", Database)
+		return
+	}
+	if warning == nil {
+		attemptSendMsg(s, m, fmt.Sprintf("There is no warning #%d on this server.", id))
+		return
+	}
+	err = storage.RemoveWarning(m.GuildID, id)
+	if err != nil {
+		logError("Unable to remove the warning! " + err.Error())
+		sendError(s, m, "unwarn", Database)
+		return
+	}
+
+	if user, err := s.User(warning.UserID); err == nil {
+		_, err = sendToModLog(s, m.GuildID, "moderation", warningEmbed("🧽Warning Removed from", user, m.Author, fmt.Sprintf("**Warning #%d**: '%s'", id, warning.Reason)))
+		if err != nil {
+			logError("Failed to post the removed warning to the mod log! " + err.Error())
+		}
+	}
+	sendSuccess(s, m, fmt.Sprintf("Removed warning #%d from <@%s>.", id, warning.UserID))
+}
+
+/**
+Removes all of a member's warnings.
+**/
+func handleClearWarns(s *discordgo.Session, m *Invocation, args *Args) {
+	userID := args.Values["user"]
+	warnings, err := storage.GetWarnings(m.GuildID, userID)
+	if err == nil {
+		err = storage.ClearWarnings(m.GuildID, userID)
+	}
+	if err != nil {
+		logError("Unable to clear the warnings! " + err.Error())
+		sendError(s, m, "clearwarns", Database)
+		return
+	}
+	if len(warnings) == 0 {
+		attemptSendMsg(s, m, fmt.Sprintf("<@%s> has no warnings.", userID))
+		return
+	}
+
+	if user, err := s.User(userID); err == nil {
+		_, err = sendToModLog(s, m.GuildID, "moderation", warningEmbed("🧽Warnings Cleared for", user, m.Author, fmt.Sprintf("**Warnings removed**: %d", len(warnings))))
+		if err != nil {
+			logError("Failed to post the cleared warnings to the mod log! " + err.Error())
+		}
+	}
+	sendSuccess(s, m, fmt.Sprintf("Cleared %d warning(s) from <@%s>.", len(warnings), userID))
+}
+
+/**
+Shows or changes what members get once they reach a number of warnings.
+**/
+func handleEscalation(s *discordgo.Session, m *Invocation, args *Args) {
+	switch args.Subcommand {
+	case "list":
+		steps, err := storage.GetEscalationSteps(m.GuildID)
+		if err != nil {
+			logError("Unable to read the escalation ladder! " + err.Error())
+			sendError(s, m, "escalation", Database)
+			return
+		}
+		if len(steps) == 0 {
+			attemptSendMsg(s, m, "Warnings don't lead to anything on this server yet. Add a step with `"+getGuildPrefix(m.GuildID)+"escalation set`.")
+			return
+		}
+		var embed discordgo.MessageEmbed
+		embed.Type = "rich"
+		embed.Title = "Escalation Ladder"
+		for _, step := range steps {
+			embed.Description += fmt.Sprintf("**%d warnings**: %s\n", step.Warnings, step.describe())
+		}
+		_, err = sendEmbed(s, m, &embed)
+		if err != nil {
+			logError("Failed to send the escalation ladder! " + err.Error())
+		}
+
+	case "set":
+		step := EscalationStep{GuildID: m.GuildID, Warnings: args.Ints["warnings"], Action: args.Values["action"], Duration: args.Durations["duration"]}
+		if step.Action == "timeout" && (step.Duration == 0 || step.Duration > maxTimeout) {
+			attemptSendMsg(s, m, "Timeouts need a length of up to 28 days, like `1h` or `3d`.")
+			return
+		}
+		if step.Action != "timeout" && step.Duration != 0 {
+			attemptSendMsg(s, m, "Only timeouts have a length.")
+			return
+		}
+		err := storage.SetEscalationStep(step)
+		if err != nil {
+			logError("Unable to save the escalation step! " + err.Error())
+			sendError(s, m, "escalation", Database)
+			return
+		}
+		sendSuccess(s, m, fmt.Sprintf("Members who reach %d warnings will be %s.", step.Warnings, step.describe()))
+
+	case "remove":
+		err := storage.RemoveEscalationStep(m.GuildID, args.Ints["warnings"])
+		if err != nil {
+			logError("Unable to remove the escalation step! " + err.Error())
+			sendError(s, m, "escalation", Database)
+			return
+		}
+		sendSuccess(s, m, "")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+/**
+Test that warnings are stored, reported and escalate along the guild's ladder.
+**/
+func TestWarnings(t *testing.T) {
+	initCommandInfo()
+	useTestStorage(t)
+	fake := newFakeDiscord(t)
+	start = time.Now()
+
+	moderators := fake.AddRole("moderators", discordgo.PermissionModerateMembers|discordgo.PermissionKickMembers|discordgo.PermissionManageServer)
+	mod := fake.AddUser("mod", moderators.ID)
+	target := fake.AddUser("target")
+	modLog := fake.AddChannel("mod-log")
+	storage.SetModLogChannel(fake.Guild.ID, modLog.ID)
+
+	t.Run("the ladder is set per guild", func(t *testing.T) {
+		if response := fake.Reply(mod, "~escalation set 2 timeout 1h").Content; response != "Members who reach 2 warnings will be timed out for 1 hour." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		fake.Reply(mod, "~escalation set 3 kick")
+		if response := fake.Reply(mod, "~escalation set 4 ban 1d").Content; response != "Only timeouts have a length." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		if response := fake.Reply(mod, "~escalation set 4 timeout 30d").Content; !strings.HasPrefix(response, "Timeouts need a length of up to 28 days") {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		list := fake.Reply(mod, "~escalation list")
+		if len(list.Embeds) != 1 || list.Embeds[0].Description != "**2 warnings**: timed out for 1 hour\n**3 warnings**: kicked\n" {
+			t.Logf("Unexpected ladder %+v", list.Embeds)
+			t.Fail()
+		}
+	})
+
+	t.Run("warnings are DMed and logged", func(t *testing.T) {
+		response := fake.Reply(mod, "~warn <@"+target.ID+"> spamming").Content
+		if response != ":warning: Warned <@"+target.ID+"> for the following reason: 'spamming'. They have 1 warning(s)." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		dms := fake.SentTo("dm-" + target.ID)
+		if len(dms) != 1 || !strings.Contains(dms[0].Content, "warned in **Test Server** by mod#1234 because: spamming") {
+			t.Logf("Unexpected DMs %+v", dms)
+			t.Fail()
+		}
+		logged := fake.SentTo(modLog.ID)
+		if len(logged) != 1 || len(logged[0].Embeds) != 1 || logged[0].Embeds[0].Title != "⚠️Warned target#1234" || logged[0].Embeds[0].Footer.Text != "Case #1" || !strings.Contains(logged[0].Embeds[0].Description, "**Warning**: #1, 1 in total") {
+			t.Logf("Unexpected mod log %+v", logged)
+			t.Fail()
+		}
+	})
+
+	t.Run("reaching a step punishes the member", func(t *testing.T) {
+		response := fake.Reply(mod, "~warn <@"+target.ID+"> still spamming").Content
+		if !strings.HasSuffix(response, "They have 2 warning(s). They have been timed out for 1 hour.") {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		edits := fake.MemberEdits()
+		if len(edits) != 1 || edits[0].UserID != target.ID || edits[0].Fields["communication_disabled_until"] == nil {
+			t.Logf("Expected a timeout, got %+v", edits)
+			t.Fail()
+		}
+		dms := fake.SentTo("dm-" + target.ID)
+		if len(dms) != 2 || !strings.HasSuffix(dms[1].Content, "you have been timed out for 1 hour.") {
+			t.Logf("The DM should mention the timeout: %+v", dms)
+			t.Fail()
+		}
+
+		list := fake.Reply(mod, "~warnings <@"+target.ID+">")
+		if len(list.Embeds) != 1 || len(list.Embeds[0].Fields) != 2 || list.Embeds[0].Footer.Text != "At 3 warnings they will be kicked." {
+			t.Logf("Unexpected warnings %+v", list.Embeds)
+			t.Fail()
+		}
+	})
+
+	t.Run("warnings can be removed", func(t *testing.T) {
+		if response := fake.Reply(mod, "~unwarn 1").Content; response != "Removed warning #1 from <@"+target.ID+">." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		if response := fake.Reply(mod, "~unwarn 1").Content; response != "There is no warning #1 on this server." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		if response := fake.Reply(mod, "~clearwarns <@"+target.ID+">").Content; response != "Cleared 1 warning(s) from <@"+target.ID+">." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+		if warnings, _ := storage.GetWarnings(fake.Guild.ID, target.ID); len(warnings) != 0 {
+			t.Logf("Expected no warnings left, got %+v", warnings)
+			t.Fail()
+		}
+	})
+
+	t.Run("the last step kicks", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			fake.Reply(mod, "~warn <@"+target.ID+"> spamming")
+		}
+		kicks := fake.Kicks()
+		if len(kicks) != 1 || kicks[0].UserID != target.ID || kicks[0].Reason != "Reached 3 warnings" {
+			t.Logf("Expected a kick, got %+v", kicks)
+			t.Fail()
+		}
+	})
+
+	t.Run("only moderators can warn", func(t *testing.T) {
+		member := fake.AddUser("member")
+		if response := fake.Reply(member, "~warn <@"+mod.ID+"> abuse").Content; response != ":bangbang: You do not have the permissions to use this command." {
+			t.Logf("Unexpected response `%s`", response)
+			t.Fail()
+		}
+	})
+}